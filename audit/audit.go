@@ -0,0 +1,53 @@
+// Package audit carries who is acting, from which address and in which
+// request through a request's context, so that audit log entries written
+// inside repository transactions can record it.
+package audit
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Actor is who performed an action: a user, a backend service holding an
+// API key, or nobody yet (an anonymous login attempt, or the system).
+type Actor struct {
+	UserID   *int64
+	Role     string
+	APIKeyID *uuid.UUID
+}
+
+// Source describes where an action came from.
+type Source struct {
+	Actor
+	ClientIP  string
+	RequestID string
+}
+
+type contextKey struct{}
+
+// FromContext returns the source recorded on ctx, which is empty outside a
+// request.
+func FromContext(ctx context.Context) Source {
+	s, _ := ctx.Value(contextKey{}).(Source)
+	return s
+}
+
+// WithRequest records the request ID and client IP on ctx.
+func WithRequest(ctx context.Context, requestID, clientIP string) context.Context {
+	s := FromContext(ctx)
+	s.RequestID, s.ClientIP = requestID, clientIP
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// WithActor records who is acting on ctx.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	s := FromContext(ctx)
+	s.Actor = actor
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// WithUser records userID, holding role, as the actor on ctx.
+func WithUser(ctx context.Context, userID int64, role string) context.Context {
+	return WithActor(ctx, Actor{UserID: &userID, Role: role})
+}
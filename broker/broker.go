@@ -0,0 +1,90 @@
+// Package broker executes orders against a market. The application talks to
+// an ExecutionAdapter so that the local simulated broker can be swapped for
+// a real one without touching order booking.
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"stock-reward-api/calendar"
+)
+
+const SideSell = "SELL"
+
+// ErrMarketClosed is returned when an order cannot be executed until the
+// next session. The order should be retried once the market opens.
+var ErrMarketClosed = errors.New("market is closed")
+
+// Order is a request to trade Quantity shares of Symbol.
+type Order struct {
+	ID       string
+	Symbol   string
+	Side     string
+	Quantity float64
+}
+
+// Execution is a fill reported by the broker.
+type Execution struct {
+	Price      float64
+	Quantity   float64
+	ExecutedAt time.Time
+	BrokerRef  string
+}
+
+// ExecutionAdapter executes orders. Implementations must be safe for
+// concurrent use.
+type ExecutionAdapter interface {
+	Execute(ctx context.Context, order Order) (Execution, error)
+}
+
+// PriceFunc returns the current price of symbol.
+type PriceFunc func(ctx context.Context, symbol string) (float64, error)
+
+// Simulated fills market orders in full at the current price, less
+// SlippageBps basis points for sells, while the market calendar is open.
+type Simulated struct {
+	Price       PriceFunc
+	Calendar    *calendar.Calendar
+	SlippageBps float64
+	Now         func() time.Time
+}
+
+func (s *Simulated) Execute(ctx context.Context, order Order) (Execution, error) {
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+	cal := s.Calendar
+	if cal == nil {
+		cal = calendar.Default
+	}
+	if !cal.IsOpen(now) {
+		return Execution{}, ErrMarketClosed
+	}
+	if order.Side != SideSell {
+		return Execution{}, fmt.Errorf("unsupported order side %q", order.Side)
+	}
+
+	price, err := s.Price(ctx, order.Symbol)
+	if err != nil {
+		return Execution{}, err
+	}
+	price = math.Round(price*(1-s.SlippageBps/10000)*100) / 100
+
+	return Execution{
+		Price:      price,
+		Quantity:   order.Quantity,
+		ExecutedAt: now,
+		BrokerRef:  "SIM-" + order.ID,
+	}, nil
+}
+
+// Default is the adapter orders are executed through.
+var Default ExecutionAdapter
+
+// DefaultFees is the fee schedule applied to sell orders.
+var DefaultFees FeeSchedule
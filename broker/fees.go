@@ -0,0 +1,45 @@
+package broker
+
+import "math"
+
+// FeeSchedule describes the charges deducted from sale proceeds.
+type FeeSchedule struct {
+	// BrokerageRate is charged on the gross value, capped at BrokerageMax
+	// per order when BrokerageMax is positive.
+	BrokerageRate float64
+	BrokerageMax  float64
+	// STTRate is the securities transaction tax on the gross value.
+	STTRate float64
+	// ExchangeRate covers exchange transaction charges on the gross value.
+	ExchangeRate float64
+	// GSTRate is levied on brokerage and exchange charges.
+	GSTRate float64
+}
+
+// Fees is the breakdown of charges on one order.
+type Fees struct {
+	BrokerageINR float64 `json:"brokerage_inr"`
+	STTINR       float64 `json:"stt_inr"`
+	ExchangeINR  float64 `json:"exchange_inr"`
+	GSTINR       float64 `json:"gst_inr"`
+	TotalINR     float64 `json:"total_inr"`
+}
+
+// Compute returns the fees on a sale of gross INR.
+func (s FeeSchedule) Compute(gross float64) Fees {
+	var f Fees
+	f.BrokerageINR = gross * s.BrokerageRate
+	if s.BrokerageMax > 0 && f.BrokerageINR > s.BrokerageMax {
+		f.BrokerageINR = s.BrokerageMax
+	}
+	f.BrokerageINR = round2(f.BrokerageINR)
+	f.STTINR = round2(gross * s.STTRate)
+	f.ExchangeINR = round2(gross * s.ExchangeRate)
+	f.GSTINR = round2((f.BrokerageINR + f.ExchangeINR) * s.GSTRate)
+	f.TotalINR = round2(f.BrokerageINR + f.STTINR + f.ExchangeINR + f.GSTINR)
+	return f
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
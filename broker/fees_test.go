@@ -0,0 +1,90 @@
+package broker
+
+import "testing"
+
+func TestFeeScheduleCompute(t *testing.T) {
+	schedule := FeeSchedule{
+		BrokerageRate: 0.0003,
+		BrokerageMax:  20,
+		STTRate:       0.001,
+		ExchangeRate:  0.0000297,
+		GSTRate:       0.18,
+	}
+	uncapped := schedule
+	uncapped.BrokerageMax = 0
+
+	tests := []struct {
+		name     string
+		schedule FeeSchedule
+		gross    float64
+		want     Fees
+	}{
+		{
+			name:     "each charge rounded to paise",
+			schedule: schedule,
+			gross:    10000,
+			want:     Fees{BrokerageINR: 3, STTINR: 10, ExchangeINR: 0.30, GSTINR: 0.59, TotalINR: 13.89},
+		},
+		{
+			name:     "gst on rounded brokerage and exchange charges",
+			schedule: schedule,
+			gross:    5000,
+			want:     Fees{BrokerageINR: 1.50, STTINR: 5, ExchangeINR: 0.15, GSTINR: 0.30, TotalINR: 6.95},
+		},
+		{
+			name:     "fractional gross",
+			schedule: schedule,
+			gross:    1234.56,
+			want:     Fees{BrokerageINR: 0.37, STTINR: 1.23, ExchangeINR: 0.04, GSTINR: 0.07, TotalINR: 1.71},
+		},
+		{
+			name:     "brokerage capped",
+			schedule: schedule,
+			gross:    100000,
+			want:     Fees{BrokerageINR: 20, STTINR: 100, ExchangeINR: 2.97, GSTINR: 4.13, TotalINR: 127.10},
+		},
+		{
+			name:     "no cap when the maximum is zero",
+			schedule: uncapped,
+			gross:    100000,
+			want:     Fees{BrokerageINR: 30, STTINR: 100, ExchangeINR: 2.97, GSTINR: 5.93, TotalINR: 138.90},
+		},
+		{
+			name:     "zero gross",
+			schedule: schedule,
+			gross:    0,
+			want:     Fees{},
+		},
+		{
+			name:     "empty schedule",
+			schedule: FeeSchedule{},
+			gross:    10000,
+			want:     Fees{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Compute(tt.gross); got != tt.want {
+				t.Errorf("Compute(%v) = %+v, want %+v", tt.gross, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRound2(t *testing.T) {
+	tests := []struct {
+		in, want float64
+	}{
+		{0.594, 0.59},
+		{0.595, 0.60},
+		{0.0738, 0.07},
+		{4.1346, 4.13},
+		{12.5, 12.5},
+	}
+	for _, tt := range tests {
+		if got := round2(tt.in); got != tt.want {
+			t.Errorf("round2(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
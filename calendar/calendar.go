@@ -0,0 +1,286 @@
+package calendar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	// Embed the zoneinfo database so exchange timezones resolve on hosts
+	// without one installed.
+	_ "time/tzdata"
+)
+
+const dateLayout = "2006-01-02"
+
+// maxLookahead bounds the search for the next/previous trading day so a
+// misconfigured calendar cannot loop forever.
+const maxLookahead = 60
+
+// Config is the on-disk representation of an exchange calendar.
+type Config struct {
+	Exchange string       `json:"exchange"`
+	Timezone string       `json:"timezone"`
+	Open     string       `json:"open"`
+	Close    string       `json:"close"`
+	Weekdays []string     `json:"weekdays"`
+	Holidays []Holiday    `json:"holidays"`
+	HalfDays []SpecialDay `json:"half_days"`
+}
+
+type Holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+// SpecialDay overrides the regular session for a single date. It is used for
+// early closes and for one-off sessions on otherwise closed days.
+type SpecialDay struct {
+	Date  string `json:"date"`
+	Name  string `json:"name"`
+	Open  string `json:"open,omitempty"`
+	Close string `json:"close"`
+}
+
+type clock struct {
+	hour, minute int
+}
+
+type session struct {
+	open, close clock
+}
+
+// Calendar answers trading-day and market-hours questions for one exchange.
+type Calendar struct {
+	Exchange string
+	Location *time.Location
+
+	regular  session
+	weekdays map[time.Weekday]bool
+	holidays map[string]string
+	special  map[string]session
+}
+
+// Default is the calendar used by the rest of the application. It starts as
+// an NSE-like calendar without holidays and is replaced by Init.
+var Default = mustNew(defaultConfig())
+
+func defaultConfig() Config {
+	return Config{
+		Exchange: "NSE",
+		Timezone: "Asia/Kolkata",
+		Open:     "09:15",
+		Close:    "15:30",
+		Weekdays: []string{"Mon", "Tue", "Wed", "Thu", "Fri"},
+	}
+}
+
+func mustNew(cfg Config) *Calendar {
+	c, err := New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// New validates cfg and builds a Calendar from it.
+func New(cfg Config) (*Calendar, error) {
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("load timezone %q: %w", cfg.Timezone, err)
+	}
+
+	regular, err := parseSession(cfg.Open, cfg.Close)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Calendar{
+		Exchange: cfg.Exchange,
+		Location: loc,
+		regular:  regular,
+		weekdays: make(map[time.Weekday]bool),
+		holidays: make(map[string]string),
+		special:  make(map[string]session),
+	}
+
+	for _, d := range cfg.Weekdays {
+		wd, err := parseWeekday(d)
+		if err != nil {
+			return nil, err
+		}
+		c.weekdays[wd] = true
+	}
+	if len(c.weekdays) == 0 {
+		return nil, fmt.Errorf("calendar has no trading weekdays")
+	}
+
+	for _, h := range cfg.Holidays {
+		if _, err := time.Parse(dateLayout, h.Date); err != nil {
+			return nil, fmt.Errorf("invalid holiday date %q: %w", h.Date, err)
+		}
+		c.holidays[h.Date] = h.Name
+	}
+
+	for _, s := range cfg.HalfDays {
+		if _, err := time.Parse(dateLayout, s.Date); err != nil {
+			return nil, fmt.Errorf("invalid half day date %q: %w", s.Date, err)
+		}
+		open := s.Open
+		if open == "" {
+			open = cfg.Open
+		}
+		sess, err := parseSession(open, s.Close)
+		if err != nil {
+			return nil, fmt.Errorf("half day %s: %w", s.Date, err)
+		}
+		c.special[s.Date] = sess
+	}
+
+	return c, nil
+}
+
+// Load reads a JSON calendar file. Fields missing from the file fall back to
+// the default NSE configuration.
+func Load(path string) (*Calendar, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read calendar file %s: %w", path, err)
+	}
+
+	cfg := defaultConfig()
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parse calendar file %s: %w", path, err)
+	}
+	return New(cfg)
+}
+
+// Date truncates t to midnight of its calendar day in the exchange timezone.
+func (c *Calendar) Date(t time.Time) time.Time {
+	t = t.In(c.Location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.Location)
+}
+
+// OnDate returns midnight in the exchange timezone of d's calendar date. It
+// is used for values read from date columns, which come back as UTC midnight.
+func (c *Calendar) OnDate(d time.Time) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, c.Location)
+}
+
+// Session returns the open and close instants for the day containing t, or
+// ok=false when the exchange does not trade that day.
+func (c *Calendar) Session(t time.Time) (open, close time.Time, ok bool) {
+	day := c.Date(t)
+	key := day.Format(dateLayout)
+
+	sess, special := c.special[key]
+	if !special {
+		if _, holiday := c.holidays[key]; holiday || !c.weekdays[day.Weekday()] {
+			return time.Time{}, time.Time{}, false
+		}
+		sess = c.regular
+	}
+
+	open = day.Add(time.Duration(sess.open.hour)*time.Hour + time.Duration(sess.open.minute)*time.Minute)
+	close = day.Add(time.Duration(sess.close.hour)*time.Hour + time.Duration(sess.close.minute)*time.Minute)
+	return open, close, true
+}
+
+// IsTradingDay reports whether the exchange has a session on t's date.
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	_, _, ok := c.Session(t)
+	return ok
+}
+
+// IsOpen reports whether the market is in session at t.
+func (c *Calendar) IsOpen(t time.Time) bool {
+	open, close, ok := c.Session(t)
+	return ok && !t.Before(open) && t.Before(close)
+}
+
+// HolidayName returns the configured holiday name for t's date, if any.
+func (c *Calendar) HolidayName(t time.Time) (string, bool) {
+	name, ok := c.holidays[c.Date(t).Format(dateLayout)]
+	return name, ok
+}
+
+// TradeDate returns the trading day an event at t is attributed to. Events
+// before the close of a trading day belong to that day; anything after the
+// close or on a closed day rolls forward to the next trading day.
+func (c *Calendar) TradeDate(t time.Time) time.Time {
+	if _, close, ok := c.Session(t); ok && t.Before(close) {
+		return c.Date(t)
+	}
+	return c.NextTradingDay(t)
+}
+
+// NextTradingDay returns the first trading day strictly after t's date.
+func (c *Calendar) NextTradingDay(t time.Time) time.Time {
+	day := c.Date(t)
+	for i := 0; i < maxLookahead; i++ {
+		day = c.Date(day.AddDate(0, 0, 1))
+		if c.IsTradingDay(day) {
+			return day
+		}
+	}
+	return day
+}
+
+// LastCompletedSession returns the most recent trading day whose close is at
+// or before t.
+func (c *Calendar) LastCompletedSession(t time.Time) (time.Time, bool) {
+	day := c.Date(t)
+	for i := 0; i < maxLookahead; i++ {
+		if _, close, ok := c.Session(day); ok && !t.Before(close) {
+			return day, true
+		}
+		day = c.Date(day.AddDate(0, 0, -1))
+	}
+	return time.Time{}, false
+}
+
+// NextOpen returns the next session open at or after t.
+func (c *Calendar) NextOpen(t time.Time) time.Time {
+	if open, close, ok := c.Session(t); ok && t.Before(close) {
+		if t.Before(open) {
+			return open
+		}
+		return t
+	}
+	open, _, _ := c.Session(c.NextTradingDay(t))
+	return open
+}
+
+func parseSession(open, close string) (session, error) {
+	o, err := parseClock(open)
+	if err != nil {
+		return session{}, fmt.Errorf("invalid open time: %w", err)
+	}
+	cl, err := parseClock(close)
+	if err != nil {
+		return session{}, fmt.Errorf("invalid close time: %w", err)
+	}
+	if cl.hour*60+cl.minute <= o.hour*60+o.minute {
+		return session{}, fmt.Errorf("close %s must be after open %s", close, open)
+	}
+	return session{open: o, close: cl}, nil
+}
+
+func parseClock(s string) (clock, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return clock{}, err
+	}
+	return clock{hour: t.Hour(), minute: t.Minute()}, nil
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		name := wd.String()
+		if strings.EqualFold(s, name) || strings.EqualFold(s, name[:3]) {
+			return wd, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", s)
+}
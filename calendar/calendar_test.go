@@ -0,0 +1,112 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func testCalendar(t *testing.T) *Calendar {
+	t.Helper()
+	cfg := defaultConfig()
+	cfg.Holidays = []Holiday{
+		{Date: "2025-08-15", Name: "Independence Day"},
+		{Date: "2025-10-02", Name: "Gandhi Jayanti"},
+		{Date: "2025-10-21", Name: "Diwali Laxmi Pujan"},
+		{Date: "2025-10-22", Name: "Balipratipada"},
+	}
+	cfg.HalfDays = []SpecialDay{
+		{Date: "2025-10-21", Name: "Muhurat Trading", Open: "13:45", Close: "14:45"},
+	}
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// ist parses a wall-clock time on the exchange's calendar.
+func ist(c *Calendar, s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, c.Location)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestNextTradingDay(t *testing.T) {
+	c := testCalendar(t)
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{"weekday to next weekday", ist(c, "2025-08-12 10:00"), "2025-08-13"},
+		{"friday skips the weekend", ist(c, "2025-08-08 16:00"), "2025-08-11"},
+		{"saturday", ist(c, "2025-08-09 10:00"), "2025-08-11"},
+		{"sunday", ist(c, "2025-08-10 23:59"), "2025-08-11"},
+		{"holiday friday and weekend", ist(c, "2025-08-14 12:00"), "2025-08-18"},
+		{"midweek holiday", ist(c, "2025-10-01 12:00"), "2025-10-03"},
+		{"special session on a holiday", ist(c, "2025-10-20 12:00"), "2025-10-21"},
+		{"after special session", ist(c, "2025-10-21 15:00"), "2025-10-23"},
+		{"utc instant already on the next exchange day", time.Date(2025, 8, 7, 20, 0, 0, 0, time.UTC), "2025-08-11"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.NextTradingDay(tt.at)
+			if got.Format(dateLayout) != tt.want {
+				t.Errorf("NextTradingDay(%s) = %s, want %s", tt.at, got.Format(dateLayout), tt.want)
+			}
+			if got.Location() != c.Location || got.Hour() != 0 || got.Minute() != 0 {
+				t.Errorf("NextTradingDay(%s) = %s, want exchange midnight", tt.at, got)
+			}
+		})
+	}
+}
+
+func TestLastCompletedSession(t *testing.T) {
+	c := testCalendar(t)
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{"before the close", ist(c, "2025-08-13 15:29"), "2025-08-12"},
+		{"at the close", ist(c, "2025-08-13 15:30"), "2025-08-13"},
+		{"before the open", ist(c, "2025-08-13 08:00"), "2025-08-12"},
+		{"holiday friday", ist(c, "2025-08-15 16:00"), "2025-08-14"},
+		{"sunday after a holiday friday", ist(c, "2025-08-17 12:00"), "2025-08-14"},
+		{"monday morning after a long weekend", ist(c, "2025-08-18 10:00"), "2025-08-14"},
+		{"during a special session", ist(c, "2025-10-21 14:00"), "2025-10-20"},
+		{"after a special session", ist(c, "2025-10-21 14:45"), "2025-10-21"},
+		{"holiday after a special session", ist(c, "2025-10-22 12:00"), "2025-10-21"},
+		{"utc instant after the exchange close", time.Date(2025, 8, 13, 10, 0, 0, 0, time.UTC), "2025-08-13"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := c.LastCompletedSession(tt.at)
+			if !ok {
+				t.Fatalf("LastCompletedSession(%s) found no session", tt.at)
+			}
+			if got.Format(dateLayout) != tt.want {
+				t.Errorf("LastCompletedSession(%s) = %s, want %s", tt.at, got.Format(dateLayout), tt.want)
+			}
+		})
+	}
+}
+
+func TestLastCompletedSessionNoneInRange(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Weekdays = []string{"Mon"}
+	start := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	for d := start; d.Before(start.AddDate(0, 0, maxLookahead+7)); d = d.AddDate(0, 0, 7) {
+		cfg.Holidays = append(cfg.Holidays, Holiday{Date: d.Format(dateLayout), Name: "closed"})
+	}
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, ok := c.LastCompletedSession(ist(c, "2025-08-01 12:00")); ok {
+		t.Errorf("LastCompletedSession = %s, want none", got)
+	}
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"stock-reward-api/db"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+)
+
+// GetPortfolioAllocation godoc
+// @Summary Get portfolio allocation
+// @Description Groups the user's holdings by sector, industry, market-cap bucket, exchange or symbol and returns each group's value and weight. Holdings missing the attribute are grouped under UNCLASSIFIED.
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param userId path int true "User ID"
+// @Param by query string false "Grouping dimension" Enums(sector, industry, market_cap, exchange, symbol) default(sector)
+// @Success 200 {object} AllocationResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/stocks/portfolio/{userId}/allocation [get]
+func GetPortfolioAllocation(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var existingID int64
+	err = db.Pool.QueryRow(c.Request.Context(), "SELECT id FROM users WHERE id=$1", userId).Scan(&existingID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id does not exist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	allocation, err := repository.GetAllocation(c.Request.Context(), userId, c.DefaultQuery("by", repository.GroupBySector))
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidGrouping) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":    userId,
+		"allocation": allocation,
+	})
+}
+
+// GetRewardLiabilities godoc
+// @Summary Get reward liabilities by group
+// @Description Returns the shares owed to all users, valued at the latest prices and grouped by sector (or another dimension), with each group's share of the total liability.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param by query string false "Grouping dimension" Enums(sector, industry, market_cap, exchange, symbol) default(sector)
+// @Success 200 {object} models.Allocation
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/admin/reward-liabilities [get]
+func GetRewardLiabilities(c *gin.Context) {
+	liabilities, err := repository.GetRewardLiabilities(c.Request.Context(), c.DefaultQuery("by", repository.GroupBySector))
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidGrouping) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, liabilities)
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-reward-api/logger"
+	"stock-reward-api/middleware"
+	"stock-reward-api/models"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var apiKeyScopes = map[string]bool{
+	models.ScopeRewardsWrite:  true,
+	models.ScopePortfolioRead: true,
+}
+
+// newAPIKey returns a random key of the form sk_<prefix>_<secret> and its
+// prefix, which identifies the key in listings without revealing it.
+func newAPIKey() (key, prefix string, err error) {
+	p := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(p); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(p)
+	return "sk_" + prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Creates an API key for a backend service. Services send it in the X-API-Key header to call endpoints their scopes allow: rewards:write for POST /api/stocks/reward and portfolio:read for reading any user's portfolio, today-stocks, historical-inr and stats. The key is only returned in this response; it is stored hashed. allowed_ips takes addresses or CIDR ranges.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param key body APIKeyRequest true "API key"
+// @Success 201 {object} APIKeyCreatedResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/admin/api-keys [post]
+func CreateAPIKey(c *gin.Context) {
+	var req APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if !apiKeyScopes[scope] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown scope " + scope})
+			return
+		}
+	}
+	for _, ip := range req.AllowedIPs {
+		if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid allowed ip " + ip})
+			return
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expires_at"})
+			return
+		}
+		if !t.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+			return
+		}
+		expiresAt = &t
+	}
+
+	key, prefix, err := newAPIKey()
+	if err != nil {
+		logger.Log.Errorf("failed to generate api key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	admin, _ := middleware.CurrentUser(c)
+	created, err := repository.CreateAPIKey(c.Request.Context(), key, prefix, strings.TrimSpace(req.Name), req.Scopes, req.AllowedIPs, expiresAt, admin.ID)
+	if err != nil {
+		logger.Log.Errorf("failed to create api key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	logger.Log.Infof("API key %s (%s) with scopes %v created by %s", created.ID, created.Name, created.Scopes, admin.Email)
+	c.JSON(http.StatusCreated, gin.H{
+		"key":     key,
+		"api_key": created,
+	})
+}
+
+// ListAPIKeys godoc
+// @Summary List API keys
+// @Description Returns every API key with its scopes, restrictions and last use, newest first. Keys themselves are never returned.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} APIKeyListResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/admin/api-keys [get]
+func ListAPIKeys(c *gin.Context) {
+	keys, err := repository.GetAPIKeys(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revokes an API key immediately
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "API key ID"
+// @Success 200 {object} models.APIKey
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/admin/api-keys/{id}/revoke [post]
+func RevokeAPIKey(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid api key id"})
+		return
+	}
+
+	key, err := repository.RevokeAPIKey(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	admin, _ := middleware.CurrentUser(c)
+	logger.Log.Infof("API key %s revoked by %s", key.ID, admin.Email)
+	c.JSON(http.StatusOK, key)
+}
+
+// GetAPIKeyUsage godoc
+// @Summary Get API key usage
+// @Description Returns the most recent requests made with an API key, newest first
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "API key ID"
+// @Param limit query int false "Maximum entries (default 100, at most 1000)"
+// @Success 200 {object} APIKeyUsageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/admin/api-keys/{id}/usage [get]
+func GetAPIKeyUsage(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid api key id"})
+		return
+	}
+
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+	}
+
+	usage, err := repository.GetAPIKeyUsage(c.Request.Context(), id, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"api_key_id": id,
+		"usage":      usage,
+	})
+}
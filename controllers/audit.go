@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stock-reward-api/logger"
+	"stock-reward-api/models"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditFilter reads the audit log filters shared by search and export.
+func auditFilter(c *gin.Context) (repository.AuditFilter, error) {
+	f := repository.AuditFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		RequestID:  c.Query("request_id"),
+	}
+	if v := c.Query("actor_user_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return f, errors.New("actor_user_id must be a user ID")
+		}
+		f.ActorUserID = &id
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		if v := c.Query(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, fmt.Errorf("%s must be an RFC3339 timestamp", p.name)
+			}
+			*p.dst = &t
+		}
+	}
+	return f, nil
+}
+
+// SearchAuditLog godoc
+// @Summary Search the audit log
+// @Description Returns audit log entries matching every given filter, newest first. Each entry records who acted, on what, the state before and after, and the client IP and request ID.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param action query string false "Only this action, e.g. kyc.approve"
+// @Param actor_user_id query int false "Only actions by this user"
+// @Param target_type query string false "Only this target type, e.g. user or withdrawal"
+// @Param target_id query string false "Only this target"
+// @Param request_id query string false "Only actions from this request"
+// @Param from query string false "Earliest time (RFC3339), inclusive"
+// @Param to query string false "Latest time (RFC3339), exclusive"
+// @Param limit query int false "Maximum entries to return (default 100, max 1000)"
+// @Success 200 {object} AuditLogResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/admin/audit-log [get]
+func SearchAuditLog(c *gin.Context) {
+	f, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+	}
+
+	entries, err := repository.SearchAuditLog(c.Request.Context(), f, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// ExportAuditLog godoc
+// @Summary Export the audit log
+// @Description Streams every audit log entry matching the filters as JSON Lines, one entry per line, oldest first.
+// @Tags Admin
+// @Produce application/x-ndjson
+// @Security BearerAuth
+// @Param action query string false "Only this action, e.g. kyc.approve"
+// @Param actor_user_id query int false "Only actions by this user"
+// @Param target_type query string false "Only this target type, e.g. user or withdrawal"
+// @Param target_id query string false "Only this target"
+// @Param request_id query string false "Only actions from this request"
+// @Param from query string false "Earliest time (RFC3339), inclusive"
+// @Param to query string false "Latest time (RFC3339), exclusive"
+// @Success 200 {array} models.AuditEntry
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/admin/audit-log/export [get]
+func ExportAuditLog(c *gin.Context) {
+	f, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="audit-log.jsonl"`)
+	c.Status(http.StatusOK)
+
+	enc := json.NewEncoder(c.Writer)
+	n := 0
+	err = repository.ExportAuditLog(c.Request.Context(), f, func(e models.AuditEntry) error {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		if n++; n%500 == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// The status is already sent; the export ends early.
+		logger.Log.Errorf("audit log export stopped after %d entries: %v", n, err)
+		return
+	}
+	c.Writer.Flush()
+}
@@ -1,33 +1,39 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"stock-reward-api/calendar"
 	"stock-reward-api/db"
+	"stock-reward-api/models"
+	"stock-reward-api/pricecache"
 	"stock-reward-api/repository"
 
 	"stock-reward-api/logger"
 
 	"github.com/gin-gonic/gin"
-	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/jackc/pgx/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // CreateReward godoc
 // @Summary Create stock reward
-// @Description Assign stock reward to a user (idempotent via reward_id)
+// @Description Assign stock reward to a user (idempotent via reward_id). Rewards for users whose KYC is not verified are held (202) and credited when their KYC is approved. Users who have not verified their email are refused (403) unless REQUIRE_VERIFIED_EMAIL_REWARDS is false.
 // @Tags Stocks
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Security ApiKeyAuth
 // @Param reward body RewardRequest true "Reward payload"
 // @Success 200 {object} GenericSuccessResponse
+// @Success 202 {object} GenericSuccessResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 409 {object} ErrorResponse
 // @Router /api/stocks/reward [post]
 func CreateReward(c *gin.Context) {
@@ -43,12 +49,21 @@ func CreateReward(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"status": "failure", "error": "invalid timestamp format"})
 		return
 	}
-	pricePerShare, err := repository.GetStockPrice(c.Request.Context(), req.StockSymbol)
+	pricePerShare, err := pricecache.Default.Get(c.Request.Context(), req.StockSymbol)
 	if err != nil {
 		logger.Log.Errorf("failed to get stock price for %s: %v", req.StockSymbol, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "failure", "error": "stock does not exist"})
 		return
 	} 
+	status, successor, err := repository.GetStockStatus(c.Request.Context(), req.StockSymbol)
+	if err == nil && status != repository.StockStatusActive {
+		msg := "stock is " + strings.ToLower(status)
+		if successor != "" {
+			msg += "; use " + successor
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"status": "failure", "error": msg})
+		return
+	}
 	fee := 10 + float64(time.Now().UnixNano()%90)              
 	logger.Log.Infof("Calculated pricePerShare: %.2f, fee: %.2f", pricePerShare, fee)
 
@@ -63,7 +78,7 @@ func CreateReward(c *gin.Context) {
 		return
 	}
 
-	err = repository.CreateReward(
+	held, err := repository.CreateReward(
 		c.Request.Context(),
 		req.UserID,
 		req.StockSymbol,
@@ -75,6 +90,10 @@ func CreateReward(c *gin.Context) {
 	)
 
 	if err != nil {
+		if errors.Is(err, repository.ErrEmailNotVerified) {
+			c.JSON(http.StatusForbidden, gin.H{"status": "failure", "error": "user's email is not verified"})
+			return
+		}
 		c.JSON(http.StatusConflict, gin.H{
 			"status": "failure",
 			"error":  err.Error(),
@@ -82,6 +101,14 @@ func CreateReward(c *gin.Context) {
 		return
 	}
 
+	if held {
+		c.JSON(http.StatusAccepted, gin.H{
+			"status":  "pending",
+			"message": "Reward held until the user's KYC is verified",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
 		"message": "Reward and ledger entries created successfully",
@@ -90,14 +117,16 @@ func CreateReward(c *gin.Context) {
 
 // GetTodayStocks godoc
 // @Summary Get today’s rewarded stocks
-// @Description Returns stocks rewarded today for a user
+// @Description Returns stocks rewarded for the current trading day. Rewards issued after market close count towards the next trading day.
 // @Tags Stocks
 // @Produce json
 // @Security BearerAuth
+// @Security ApiKeyAuth
 // @Param userId path int true "User ID"
 // @Success 200 {object} TodayStocksResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Router /api/stocks/today-stocks/{userId} [get]
 func GetTodayStocks(c *gin.Context) {
 	userIdStr := c.Param("userId")
@@ -118,28 +147,31 @@ func GetTodayStocks(c *gin.Context) {
 		return
 	}
 
-	rewards, err := repository.GetTodayStocks(c.Request.Context(), userId)
+	tradeDate := calendar.Default.TradeDate(time.Now())
+	rewards, err := repository.GetTodayStocks(c.Request.Context(), userId, tradeDate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"date":    time.Now().Format("2006-01-02"),
+		"date":    tradeDate.Format("2006-01-02"),
 		"rewards": rewards,
 	})
 }
 
 // GetHistoricalINR godoc
 // @Summary Get historical INR valuation
-// @Description Returns historical INR valuation of user rewards
+// @Description Returns the user's end-of-day holdings value in INR for each snapshotted trading day
 // @Tags Stocks
 // @Produce json
 // @Security BearerAuth
+// @Security ApiKeyAuth
 // @Param userId path int true "User ID"
 // @Success 200 {object} HistoricalINRResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Router /api/stocks/historical-inr/{userId} [get]
 func GetHistoricalINR(c *gin.Context) {
 	userIdStr := c.Param("userId")
@@ -173,25 +205,73 @@ func GetHistoricalINR(c *gin.Context) {
 	})
 }
 
+// statsRange resolves a stats period to the first and last trading days it
+// covers. Periods other than today run up to the current trade date.
+func statsRange(period, fromStr, toStr string, now time.Time) (time.Time, time.Time, error) {
+	today := calendar.Default.TradeDate(now)
+	switch period {
+	case "today":
+		return today, today, nil
+	case "week":
+		offset := (int(today.Weekday()) + 6) % 7
+		return today.AddDate(0, 0, -offset), today, nil
+	case "month":
+		return time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location()), today, nil
+	case "ytd":
+		return time.Date(today.Year(), time.January, 1, 0, 0, 0, 0, today.Location()), today, nil
+	case "custom":
+		if fromStr == "" || toStr == "" {
+			return time.Time{}, time.Time{}, errors.New("from and to are required for a custom period")
+		}
+		from, err := time.ParseInLocation("2006-01-02", fromStr, calendar.Default.Location)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("from must be YYYY-MM-DD")
+		}
+		to, err := time.ParseInLocation("2006-01-02", toStr, calendar.Default.Location)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("to must be YYYY-MM-DD")
+		}
+		if to.Before(from) {
+			return time.Time{}, time.Time{}, errors.New("to must not be before from")
+		}
+		return from, to, nil
+	default:
+		return time.Time{}, time.Time{}, errors.New("period must be one of today, week, month, ytd, custom")
+	}
+}
+
 // GetUserStats godoc
 // @Summary Get user stock stats
-// @Description Returns aggregated stock statistics
+// @Description Aggregates the user's rewards by trade date over a period and groups them by symbol, day or ISO week. Each bucket reports the number of rewards, shares (adjusted for splits and bonus issues), current INR value and value at grant.
 // @Tags Stocks
 // @Produce json
 // @Security BearerAuth
+// @Security ApiKeyAuth
 // @Param userId path int true "User ID"
-// @Success 200 {object} UserStatsResponse
+// @Param period query string false "Period" Enums(today, week, month, ytd, custom) default(today)
+// @Param from query string false "Start date for a custom period (YYYY-MM-DD)"
+// @Param to query string false "End date for a custom period (YYYY-MM-DD)"
+// @Param group_by query string false "Grouping" Enums(symbol, day, week) default(symbol)
+// @Success 200 {object} models.UserStats
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Router /api/stocks/stats/{userId} [get]
-func GetUserStats (c *gin.Context) {
+func GetUserStats(c *gin.Context) {
 	userIdStr := c.Param("userId")
 	userId, err := strconv.ParseInt(userIdStr, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
 		return
-	}	
-	
+	}
+
+	period := c.DefaultQuery("period", "today")
+	from, to, err := statsRange(period, c.Query("from"), c.Query("to"), time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var existingID int64
 	err = db.Pool.QueryRow(c.Request.Context(), "SELECT id FROM users WHERE id=$1", userId).Scan(&existingID)
 	if err != nil {
@@ -202,30 +282,34 @@ func GetUserStats (c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	logger.Log.Infof("Fetching user stats for user %d", userId)
+	logger.Log.Infof("Fetching %s user stats for user %d", period, userId)
 
-	rewards, err := repository.GetUserStats(c.Request.Context(), userId)
+	stats, err := repository.GetUserStats(c.Request.Context(), userId, from, to, c.DefaultQuery("group_by", repository.StatsBySymbol))
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidStatsGrouping) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	logger.Log.Infof("User stats for user %d: %+v", userId, rewards)
-	c.JSON(http.StatusOK, gin.H{
-		"user_id": userId,
-		"history": rewards,
-	})
+	stats.Period = period
+
+	c.JSON(http.StatusOK, stats)
 }
 
 // GetPortfolio godoc
 // @Summary Get user portfolio
-// @Description Returns current stock holdings
+// @Description Returns current stock holdings keyed by symbol with cost basis (average cost of the grant prices), current value and unrealized gain, plus portfolio totals. Holdings are valued at the last close while the market is shut.
 // @Tags Stocks
 // @Produce json
 // @Security BearerAuth
+// @Security ApiKeyAuth
 // @Param userId path int true "User ID"
 // @Success 200 {object} PortfolioResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Router /api/stocks/portfolio/{userId} [get]
 func GetPortfolio(c *gin.Context) {
 	userIdStr := c.Param("userId")
@@ -247,21 +331,22 @@ func GetPortfolio(c *gin.Context) {
 	}
 	logger.Log.Infof("Fetching portfolio for user %d", userId)
 
-	rewards, err := repository.GetPortfolio(c.Request.Context(), userId)
+	holdings, totals, err := repository.GetPortfolio(c.Request.Context(), userId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	logger.Log.Infof("Portfolio for user %d: %+v", userId, rewards)
+	logger.Log.Infof("Portfolio for user %d: %+v", userId, holdings)
 	c.JSON(http.StatusOK, gin.H{
 		"user_id": userId,
-		"history": rewards,
+		"history": holdings,
+		"totals":  totals,
 	})
 }
 
 // RegisterUser godoc
 // @Summary Register new user
-// @Description Creates a new user and returns JWT
+// @Description Creates a new user and emails them a verification link. Returns a short-lived access token with a refresh token, unless REQUIRE_VERIFIED_EMAIL_LOGIN is set, in which case the user must verify their email before logging in.
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -300,7 +385,7 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hash, err := hashPassword(req.Password)
 	if err != nil {
 		logger.Log.Errorf("failed to hash password: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
@@ -309,44 +394,58 @@ func RegisterUser(c *gin.Context) {
 
 	var id int64
 	var createdAt time.Time
-	err = db.Pool.QueryRow(c.Request.Context(), "INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id, created_at", req.Name, req.Email, string(hash)).Scan(&id, &createdAt)
+	role := repository.RoleForNewUser(req.Email)
+	err = db.Pool.QueryRow(c.Request.Context(), "INSERT INTO users (name, email, password, role) VALUES ($1, $2, $3, $4) RETURNING id, created_at", req.Name, req.Email, hash, role).Scan(&id, &createdAt)
 	if err != nil {
 		logger.Log.Errorf("failed to create user: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
 
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		logger.Log.Warn("JWT_SECRET not set; using empty secret")
+	if _, err := repository.RecordVerificationSend(c.Request.Context(), id, repository.VerificationResendLimits{}); err != nil {
+		logger.Log.Errorf("failed to record verification email for user %d: %v", id, err)
+	} else {
+		sendVerificationEmail(models.User{ID: id, Name: req.Name, Email: req.Email})
+	}
+
+	resp := gin.H{
+		"id":             id,
+		"name":           req.Name,
+		"email":          req.Email,
+		"created_at":     createdAt,
+		"role":           role,
+		"email_verified": false,
+	}
+	if repository.RequireVerifiedEmailForLogin {
+		resp["message"] = "Check your email to verify your address before logging in"
+		c.JSON(http.StatusCreated, resp)
+		return
 	}
-	claims := jwt.MapClaims{"sub": id, "exp": time.Now().Add(24 * time.Hour).Unix()}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString([]byte(secret))
+
+	session, err := startSession(c.Request.Context(), id, role)
 	if err != nil {
-		logger.Log.Errorf("failed to sign token: %v", err)
+		logger.Log.Errorf("failed to start session: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"id":         id,
-		"name":       req.Name,
-		"email":      req.Email,
-		"created_at": createdAt,
-		"token":      signed,
-	})
+	resp["token"] = session.AccessToken
+	resp["refresh_token"] = session.RefreshToken
+	resp["expires_in"] = session.ExpiresIn
+	c.JSON(http.StatusCreated, resp)
 }
 
 // LoginUser godoc
 // @Summary Login user
-// @Description Authenticates user and returns JWT
+// @Description Authenticates user and returns a short-lived access token with a refresh token. For users with two-factor authentication the response is instead an mfa_token, to be exchanged for tokens at /api/user/login/mfa with a code. Failed logins are throttled per account and per client IP with growing delays and a temporary lockout (429).
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param user body LoginRequest true "Login payload"
 // @Success 200 {object} AuthResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
 // @Router /api/user/login [post]
 func LoginUser(c *gin.Context) {
 	type LoginRequest struct {
@@ -365,12 +464,19 @@ func LoginUser(c *gin.Context) {
 		return
 	}
 
+	if !loginAllowed(c, req.Email) {
+		return
+	}
+
 	var id int64
 	var name string
 	var pwHash string
-	err := db.Pool.QueryRow(c.Request.Context(), "SELECT id, name, password FROM users WHERE email=$1", req.Email).Scan(&id, &name, &pwHash)
+	var role string
+	var emailVerified, mfaEnabled bool
+	err := db.Pool.QueryRow(c.Request.Context(), "SELECT id, name, password, role, email_verified_at IS NOT NULL, totp_enabled_at IS NOT NULL FROM users WHERE email=$1", req.Email).Scan(&id, &name, &pwHash, &role, &emailVerified, &mfaEnabled)
 	if err != nil {
 		if err == pgx.ErrNoRows {
+			loginFailed(c, req.Email, nil)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 			return
 		}
@@ -380,22 +486,61 @@ func LoginUser(c *gin.Context) {
 	}
 
 	if bcrypt.CompareHashAndPassword([]byte(pwHash), []byte(req.Password)) != nil {
+		loginFailed(c, req.Email, &id)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
+	if repository.RequireVerifiedEmailForLogin && !emailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": repository.ErrEmailNotVerified.Error()})
+		return
+	}
 
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		logger.Log.Warn("JWT_SECRET not set; using empty secret")
+	if mfaEnabled {
+		token, ttl, err := startMFAChallenge(c.Request.Context(), id)
+		if err != nil {
+			logger.Log.Errorf("failed to start mfa challenge: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    token,
+			"expires_in":   int64(ttl.Seconds()),
+		})
+		return
 	}
-	claims := jwt.MapClaims{"sub": id, "exp": time.Now().Add(24 * time.Hour).Unix()}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString([]byte(secret))
+
+	// A login with a second factor is only complete, and its failures
+	// cleared, once the code is checked.
+	if err := repository.ClearLoginFailures(c.Request.Context(), req.Email); err != nil {
+		logger.Log.Errorf("failed to clear login failures for user %d: %v", id, err)
+	}
+	session, err := startSession(c.Request.Context(), id, role)
 	if err != nil {
-		logger.Log.Errorf("failed to sign token: %v", err)
+		logger.Log.Errorf("failed to start session: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": signed, "id": id, "name": name})
-}
\ No newline at end of file
+	c.JSON(http.StatusOK, gin.H{
+		"token":         session.AccessToken,
+		"refresh_token": session.RefreshToken,
+		"expires_in":     session.ExpiresIn,
+		"id":             id,
+		"name":           name,
+		"role":           role,
+		"email_verified": emailVerified,
+	})
+}
+// GetPriceCacheStats godoc
+// @Summary Get price cache statistics
+// @Description Returns hit/miss counters and size of the in-process price cache
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} pricecache.Stats
+// @Failure 401 {object} ErrorResponse
+// @Router /api/stocks/price-cache/stats [get]
+func GetPriceCacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, pricecache.Default.Stats())
+}
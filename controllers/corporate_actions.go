@@ -0,0 +1,295 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-reward-api/db"
+	"stock-reward-api/logger"
+	"stock-reward-api/middleware"
+	"stock-reward-api/models"
+	"stock-reward-api/repository"
+	"stock-reward-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// CreateCorporateAction godoc
+// @Summary Record a corporate action
+// @Description Records a corporate action. Split ratios read "ratio_from old shares become ratio_to new shares" and bonus ratios "ratio_to bonus shares for every ratio_from held". SYMBOL_CHANGE moves holdings to new_symbol; MERGER converts ratio_from shares into ratio_to shares of new_symbol and pays fractions at settlement_price; DELISTING freezes the symbol at settlement_price. Actions are applied once the record date has passed and the ex-date is reached.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param action body CorporateActionRequest true "Corporate action"
+// @Success 201 {object} models.CorporateAction
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/admin/corporate-actions [post]
+func CreateCorporateAction(c *gin.Context) {
+	var req CorporateActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateCorporateAction(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	recordDate, err := time.Parse("2006-01-02", req.RecordDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid record_date format"})
+		return
+	}
+	exDate, err := time.Parse("2006-01-02", req.ExDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ex_date format"})
+		return
+	}
+	if exDate.After(recordDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ex_date cannot be after record_date"})
+		return
+	}
+
+	status, _, err := repository.GetStockStatus(c.Request.Context(), req.StockSymbol)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "stock does not exist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if status != repository.StockStatusActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "stock is " + strings.ToLower(status)})
+		return
+	}
+
+	switch req.ActionType {
+	case repository.ActionSymbolChange:
+		if _, _, err := repository.GetStockStatus(c.Request.Context(), req.NewSymbol); err != pgx.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "new_symbol already exists"})
+			return
+		}
+	case repository.ActionMerger:
+		acquirer, _, err := repository.GetStockStatus(c.Request.Context(), req.NewSymbol)
+		if err != nil || acquirer != repository.StockStatusActive {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "new_symbol must be an active stock"})
+			return
+		}
+	}
+
+	user, _ := middleware.CurrentUser(c)
+	action, err := repository.CreateCorporateAction(c.Request.Context(), models.CorporateAction{
+		ActionType:      req.ActionType,
+		StockSymbol:     req.StockSymbol,
+		NewSymbol:       req.NewSymbol,
+		RatioFrom:       req.RatioFrom,
+		RatioTo:         req.RatioTo,
+		SettlementPrice: req.SettlementPrice,
+		RecordDate:      recordDate,
+		ExDate:          exDate,
+		Notes:           req.Notes,
+		CreatedBy:       user.ID,
+	})
+	if err != nil {
+		logger.Log.Errorf("failed to create corporate action: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	logger.Log.Infof("Corporate action %s recorded for %s by user %d", action.ID, action.StockSymbol, user.ID)
+	c.JSON(http.StatusCreated, action)
+}
+
+// ListCorporateActions godoc
+// @Summary List corporate actions
+// @Description Returns recorded corporate actions, optionally filtered by symbol
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param symbol query string false "Stock symbol"
+// @Success 200 {object} CorporateActionListResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/admin/corporate-actions [get]
+func ListCorporateActions(c *gin.Context) {
+	actions, err := repository.GetCorporateActions(c.Request.Context(), c.Query("symbol"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"actions": actions})
+}
+
+// ApplyCorporateAction godoc
+// @Summary Apply a corporate action
+// @Description Posts adjustment entries for every holder as of the record date and rescales historical prices. With dry_run=true the per-holder impact is returned without applying anything.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Corporate action ID"
+// @Param dry_run query bool false "Preview without applying"
+// @Success 200 {object} models.CorporateActionResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/admin/corporate-actions/{id}/apply [post]
+func ApplyCorporateAction(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid corporate action id"})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+	result, err := utils.ApplyCorporateAction(c.Request.Context(), id, dryRun)
+	switch err {
+	case nil:
+	case repository.ErrCorporateActionNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case repository.ErrCorporateActionApplied, repository.ErrCorporateActionNotDue:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	default:
+		logger.Log.Errorf("failed to apply corporate action %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	if !dryRun {
+		logger.Log.Infof("Applied corporate action %s to %d holders", id, len(result.Holders))
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// validateCorporateAction checks the fields each action type depends on and
+// fills in the 1:1 ratio for types that do not use one.
+func validateCorporateAction(req *CorporateActionRequest) string {
+	switch req.ActionType {
+	case repository.ActionSplit, repository.ActionBonus, repository.ActionMerger:
+		if req.RatioFrom <= 0 || req.RatioTo <= 0 {
+			return "ratio_from and ratio_to must be positive"
+		}
+	default:
+		req.RatioFrom, req.RatioTo = 1, 1
+	}
+
+	switch req.ActionType {
+	case repository.ActionSplit:
+		if req.RatioFrom == req.RatioTo {
+			return "split ratio must change the number of shares"
+		}
+	case repository.ActionSymbolChange, repository.ActionMerger:
+		if req.NewSymbol == "" || req.NewSymbol == req.StockSymbol {
+			return "new_symbol must name a different symbol"
+		}
+		if req.ActionType == repository.ActionMerger && req.SettlementPrice <= 0 {
+			return "settlement_price is required to pay out fractional shares"
+		}
+	case repository.ActionDelisting:
+		if req.SettlementPrice <= 0 {
+			return "settlement_price is required as the final valuation"
+		}
+	}
+	return ""
+}
+
+// GetSymbolLineage godoc
+// @Summary Get symbol history
+// @Description Returns every symbol linked to the given one through renames and mergers, with their status and the corporate actions that link them. Retired symbols stay queryable.
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Param symbol path string true "Stock symbol, current or retired"
+// @Success 200 {object} SymbolLineageResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/stocks/symbols/{symbol} [get]
+func GetSymbolLineage(c *gin.Context) {
+	lineage, err := repository.GetSymbolLineage(c.Request.Context(), c.Param("symbol"))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "stock does not exist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, lineage)
+}
+
+// GetUserLedger godoc
+// @Summary Get user ledger
+// @Description Returns the user's ledger entries. When symbol is given, entries for every symbol in its rename/merger lineage are included, so history recorded under old identifiers is returned too.
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Param symbol query string false "Stock symbol, current or retired"
+// @Success 200 {object} LedgerResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/stocks/ledger/{userId} [get]
+func GetUserLedger(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var existingID int64
+	err = db.Pool.QueryRow(c.Request.Context(), "SELECT id FROM users WHERE id=$1", userId).Scan(&existingID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id does not exist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var symbols []string
+	if symbol := c.Query("symbol"); symbol != "" {
+		lineage, err := repository.GetSymbolLineage(c.Request.Context(), symbol)
+		if err != nil && err != pgx.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		symbols = []string{symbol}
+		if lineage != nil {
+			symbols = symbols[:0]
+			for _, s := range lineage.Symbols {
+				symbols = append(symbols, s.StockSymbol)
+			}
+		}
+	}
+
+	entries, err := repository.GetUserLedger(c.Request.Context(), userId, symbols)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id": userId,
+		"symbols": symbols,
+		"entries": entries,
+	})
+}
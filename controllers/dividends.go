@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-reward-api/db"
+	"stock-reward-api/logger"
+	"stock-reward-api/middleware"
+	"stock-reward-api/models"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+)
+
+// DeclareDividend godoc
+// @Summary Declare a cash dividend
+// @Description Declares a per-share cash dividend. Entitlements are computed from ledger holdings once the record date has passed and paid into users' cash balance on the pay date.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param dividend body DividendRequest true "Dividend"
+// @Success 201 {object} models.Dividend
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/admin/dividends [post]
+func DeclareDividend(c *gin.Context) {
+	var req DividendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recordDate, err := time.Parse("2006-01-02", req.RecordDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid record_date format"})
+		return
+	}
+	payDate, err := time.Parse("2006-01-02", req.PayDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pay_date format"})
+		return
+	}
+	if payDate.Before(recordDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pay_date cannot be before record_date"})
+		return
+	}
+
+	status, _, err := repository.GetStockStatus(c.Request.Context(), req.StockSymbol)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "stock does not exist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if status != repository.StockStatusActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "stock is " + strings.ToLower(status)})
+		return
+	}
+
+	user, _ := middleware.CurrentUser(c)
+	dividend, err := repository.CreateDividend(c.Request.Context(), models.Dividend{
+		StockSymbol:    req.StockSymbol,
+		AmountPerShare: req.AmountPerShare,
+		RecordDate:     recordDate,
+		PayDate:        payDate,
+		Notes:          req.Notes,
+		CreatedBy:      user.ID,
+	})
+	if err != nil {
+		logger.Log.Errorf("failed to declare dividend: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	logger.Log.Infof("Dividend %s of %.2f declared for %s by user %d", dividend.ID, dividend.AmountPerShare, dividend.StockSymbol, user.ID)
+	c.JSON(http.StatusCreated, dividend)
+}
+
+// ListDividends godoc
+// @Summary List dividends
+// @Description Returns declared dividends, optionally filtered by symbol
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param symbol query string false "Stock symbol"
+// @Success 200 {object} DividendListResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/admin/dividends [get]
+func ListDividends(c *gin.Context) {
+	dividends, err := repository.GetDividends(c.Request.Context(), c.Query("symbol"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dividends": dividends})
+}
+
+// GetUserDividends godoc
+// @Summary Get dividend history
+// @Description Returns the user's dividend entitlements with gross, TDS and net amounts
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 200 {object} DividendHistoryResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/stocks/dividends/{userId} [get]
+func GetUserDividends(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var existingID int64
+	err = db.Pool.QueryRow(c.Request.Context(), "SELECT id FROM users WHERE id=$1", userId).Scan(&existingID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id does not exist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	dividends, err := repository.GetUserDividends(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":   userId,
+		"dividends": dividends,
+	})
+}
@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"stock-reward-api/jwtkeys"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS godoc
+// @Summary Get the token signing keys
+// @Description Publishes the public keys access tokens are verified with, as a JSON Web Key Set. Tokens name their key in the kid header. Keys scheduled to take over signing appear ahead of time, and replaced keys stay until tokens they signed have expired.
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} jwtkeys.JWKS
+// @Router /.well-known/jwks.json [get]
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwtkeys.Current().JWKS(time.Now()))
+}
@@ -0,0 +1,289 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"stock-reward-api/logger"
+	"stock-reward-api/middleware"
+	"stock-reward-api/models"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+var panPattern = regexp.MustCompile(`^[A-Z]{5}[0-9]{4}[A-Z]$`)
+
+var kycDocumentTypes = map[string]bool{
+	"PAN_CARD":        true,
+	"AADHAAR":         true,
+	"PASSPORT":        true,
+	"DRIVING_LICENCE": true,
+	"VOTER_ID":        true,
+	"BANK_STATEMENT":  true,
+}
+
+// maskPAN keeps the last four characters of a PAN.
+func maskPAN(pan string) string {
+	if len(pan) <= 4 {
+		return pan
+	}
+	return strings.Repeat("X", len(pan)-4) + pan[len(pan)-4:]
+}
+
+// SubmitKYC godoc
+// @Summary Submit KYC details
+// @Description Records the current user's PAN and references to their identity documents and queues them for review. Rewards are held, and sells, withdrawals and share transfers are blocked, until an admin approves the submission. Rejected submissions may be resubmitted.
+// @Tags Stocks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param kyc body KYCSubmissionRequest true "KYC details"
+// @Success 202 {object} models.KYCProfile
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/stocks/kyc [post]
+func SubmitKYC(c *gin.Context) {
+	var req KYCSubmissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.PAN = strings.ToUpper(strings.TrimSpace(req.PAN))
+	if !panPattern.MatchString(req.PAN) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid PAN"})
+		return
+	}
+
+	var docs []models.KYCDocument
+	for _, d := range req.Documents {
+		docType := strings.ToUpper(strings.TrimSpace(d.DocumentType))
+		if !kycDocumentTypes[docType] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported document type " + d.DocumentType})
+			return
+		}
+		docs = append(docs, models.KYCDocument{DocumentType: docType, Reference: strings.TrimSpace(d.Reference)})
+	}
+
+	user, _ := middleware.CurrentUser(c)
+	if err := repository.SubmitKYC(c.Request.Context(), user.ID, req.PAN, docs); err != nil {
+		if errors.Is(err, repository.ErrKYCAlreadyVerified) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Log.Errorf("failed to submit kyc for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	profile, err := repository.GetKYCProfile(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	profile.PAN = maskPAN(profile.PAN)
+
+	logger.Log.Infof("User %d submitted KYC with %d documents", user.ID, len(docs))
+	c.JSON(http.StatusAccepted, profile)
+}
+
+// GetKYCProfile godoc
+// @Summary Get KYC status
+// @Description Returns the user's KYC status, submitted documents and rewards held until verification. The PAN is masked.
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 200 {object} models.KYCProfile
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/stocks/kyc/{userId} [get]
+func GetKYCProfile(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	profile, err := repository.GetKYCProfile(c.Request.Context(), userId)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	profile.PAN = maskPAN(profile.PAN)
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// ListKYCSubmissions godoc
+// @Summary List KYC submissions
+// @Description Returns users in the given KYC status (default PENDING_REVIEW) with their PAN and documents, oldest submission first
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "NOT_SUBMITTED, PENDING_REVIEW, VERIFIED or REJECTED"
+// @Success 200 {object} KYCListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/admin/kyc [get]
+func ListKYCSubmissions(c *gin.Context) {
+	status := strings.ToUpper(c.DefaultQuery("status", repository.KYCPendingReview))
+	switch status {
+	case repository.KYCNotSubmitted, repository.KYCPendingReview, repository.KYCVerified, repository.KYCRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+		return
+	}
+
+	profiles, err := repository.ListKYCProfiles(c.Request.Context(), status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   status,
+		"profiles": profiles,
+	})
+}
+
+// ApproveKYC godoc
+// @Summary Approve a KYC submission
+// @Description Marks the user's KYC verified and credits every reward held for them at the current price. Rewards whose stock has no price are left held and listed in held_rewards.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 200 {object} KYCApprovalResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/admin/kyc/{userId}/approve [post]
+func ApproveKYC(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	admin, _ := middleware.CurrentUser(c)
+	released, held, err := repository.ApproveKYC(c.Request.Context(), userId, admin.ID)
+	if err != nil {
+		writeKYCReviewError(c, err)
+		return
+	}
+
+	logger.Log.Infof("KYC for user %d approved by %s; released %d held rewards, %d still held", userId, admin.Email, len(released), len(held))
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":          userId,
+		"status":           repository.KYCVerified,
+		"released_rewards": released,
+		"held_rewards":     held,
+	})
+}
+
+// ReleaseHeldRewards godoc
+// @Summary Release rewards still held for a verified user
+// @Description Credits rewards left held at KYC approval because their stock had no price then, at the current price. Rewards whose stock still has no price stay held.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 200 {object} KYCApprovalResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/admin/kyc/{userId}/release [post]
+func ReleaseHeldRewards(c *gin.Context) {
+	userId, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	released, held, err := repository.ReleaseHeldRewards(c.Request.Context(), userId)
+	if err != nil {
+		writeKYCReviewError(c, err)
+		return
+	}
+
+	logger.Log.Infof("Released %d held rewards to user %d, %d still held", len(released), userId, len(held))
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":          userId,
+		"status":           repository.KYCVerified,
+		"released_rewards": released,
+		"held_rewards":     held,
+	})
+}
+
+// RejectKYC godoc
+// @Summary Reject a KYC submission
+// @Description Rejects the user's KYC submission with a reason. Held rewards stay held until a resubmission is approved.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Param rejection body KYCRejectionRequest true "Rejection"
+// @Success 200 {object} GenericSuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/admin/kyc/{userId}/reject [post]
+func RejectKYC(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req KYCRejectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	admin, _ := middleware.CurrentUser(c)
+	if err := repository.RejectKYC(c.Request.Context(), userId, admin.ID, strings.TrimSpace(req.Reason)); err != nil {
+		writeKYCReviewError(c, err)
+		return
+	}
+
+	logger.Log.Infof("KYC for user %d rejected by %s", userId, admin.Email)
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "KYC rejected",
+	})
+}
+
+func writeKYCReviewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrKYCNotPending), errors.Is(err, repository.ErrKYCNotVerified):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Log.Errorf("failed to review kyc: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
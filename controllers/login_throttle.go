@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"stock-reward-api/logger"
+	"stock-reward-api/middleware"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginAllowed writes a 429 with Retry-After and returns false while
+// earlier failures for email or the client IP are still being backed off.
+func loginAllowed(c *gin.Context, email string) bool {
+	wait, err := repository.LoginRetryAfter(c.Request.Context(), email, c.ClientIP())
+	if err != nil {
+		logger.Log.Errorf("failed to check login throttle: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return false
+	}
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": repository.ErrLoginThrottled.Error()})
+		return false
+	}
+	return true
+}
+
+// loginFailed counts a failed login against email and the client IP.
+func loginFailed(c *gin.Context, email string, userID *int64) {
+	if err := repository.RecordLoginFailure(c.Request.Context(), email, c.ClientIP(), userID, repository.DefaultLoginThrottle); err != nil {
+		logger.Log.Errorf("failed to record login failure: %v", err)
+	}
+}
+
+// UnlockUser godoc
+// @Summary Unlock a user's login
+// @Description Lifts a lockout or backoff imposed on the user's account after failed logins. Lockouts of client IPs expire on their own.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 200 {object} GenericSuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/admin/users/{userId}/unlock [post]
+func UnlockUser(c *gin.Context) {
+	userId, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	admin, _ := middleware.CurrentUser(c)
+	if err := repository.UnlockAccount(c.Request.Context(), userId, admin.ID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Log.Infof("User %d login unlocked by %s", userId, admin.Email)
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "login unlocked",
+	})
+}
+
+// ListSecurityEvents godoc
+// @Summary List security events
+// @Description Returns recent security events such as account and IP lockouts and unlocks, newest first.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param type query string false "Only events of this type" Enums(ACCOUNT_LOCKED, IP_LOCKED, ACCOUNT_UNLOCKED)
+// @Param limit query int false "Maximum events to return (default 100, max 1000)"
+// @Success 200 {object} SecurityEventListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/admin/security-events [get]
+func ListSecurityEvents(c *gin.Context) {
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+	}
+
+	events, err := repository.ListSecurityEvents(c.Request.Context(), c.Query("type"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
@@ -0,0 +1,359 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"stock-reward-api/logger"
+	"stock-reward-api/middleware"
+	"stock-reward-api/repository"
+	"stock-reward-api/totp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recoveryCodeCount is how many one-time recovery codes a user is given.
+const recoveryCodeCount = 10
+
+// mfaIssuer names the service in authenticator apps, from MFA_ISSUER.
+func mfaIssuer() string {
+	if v := os.Getenv("MFA_ISSUER"); v != "" {
+		return v
+	}
+	return "Stocky"
+}
+
+// newRecoveryCodes returns fresh recovery codes formatted as xxxxx-xxxxx.
+func newRecoveryCodes() ([]string, error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(enc.EncodeToString(b))[:10]
+		codes[i] = s[:5] + "-" + s[5:]
+	}
+	return codes, nil
+}
+
+// startMFAChallenge records a login awaiting its second factor and returns
+// the token that completes it, valid for MFA_CHALLENGE_TTL (default 5m).
+func startMFAChallenge(ctx context.Context, userID int64) (string, time.Duration, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", 0, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	ttl := tokenTTL("MFA_CHALLENGE_TTL", 5*time.Minute)
+	if err := repository.CreateMFAChallenge(ctx, userID, token, time.Now().Add(ttl)); err != nil {
+		return "", 0, err
+	}
+	return token, ttl, nil
+}
+
+// mfaError writes the response for an error from an MFA repository call.
+func mfaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrInvalidMFACode), errors.Is(err, repository.ErrInvalidMFAChallenge):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrMFAAlreadyEnabled), errors.Is(err, repository.ErrMFANotEnabled),
+		errors.Is(err, repository.ErrMFANotEnrolled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrMFARequiredForRole):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		logger.Log.Errorf("mfa request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
+
+// CompleteMFALogin godoc
+// @Summary Complete a two-factor login
+// @Description Finishes a login for a user with two-factor authentication, using the mfa_token returned by login and a code from their authenticator app or an unused recovery code. A token accepts at most 5 wrong codes, and wrong codes count as failed logins. Throttled logins are refused before the code is checked (429).
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body MFALoginRequest true "MFA token and code"
+// @Success 200 {object} AuthResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /api/user/login/mfa [post]
+func CompleteMFALogin(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	email, err := repository.MFAChallengeEmail(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	if !loginAllowed(c, email) {
+		return
+	}
+
+	user, emailVerified, err := repository.CompleteMFAChallenge(c.Request.Context(), req.MFAToken, req.Code)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidMFACode) {
+			logger.Log.Warnf("Invalid MFA code at login for user %d", user.ID)
+			loginFailed(c, user.Email, &user.ID)
+		}
+		mfaError(c, err)
+		return
+	}
+	if err := repository.ClearLoginFailures(c.Request.Context(), email); err != nil {
+		logger.Log.Errorf("failed to clear login failures for user %d: %v", user.ID, err)
+	}
+
+	session, err := startSession(c.Request.Context(), user.ID, user.Role)
+	if err != nil {
+		logger.Log.Errorf("failed to start session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":          session.AccessToken,
+		"refresh_token":  session.RefreshToken,
+		"expires_in":     session.ExpiresIn,
+		"id":             user.ID,
+		"name":           user.Name,
+		"role":           user.Role,
+		"email_verified": emailVerified,
+	})
+}
+
+// GetMFAStatus godoc
+// @Summary Get two-factor status
+// @Description Returns whether the current user has two-factor authentication enabled, whether their role requires it and how many recovery codes remain.
+// @Tags MFA
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} repository.MFAStatus
+// @Failure 401 {object} ErrorResponse
+// @Router /api/user/mfa [get]
+func GetMFAStatus(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+	status, err := repository.GetMFAStatus(c.Request.Context(), user.ID)
+	if err != nil {
+		mfaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// EnrollMFA godoc
+// @Summary Start two-factor enrollment
+// @Description Generates a TOTP secret for the current user and returns it with an otpauth:// URI for authenticator apps. Two-factor authentication is not enabled until the secret is confirmed with a code. Enrolling again before confirming replaces the secret.
+// @Tags MFA
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} MFAEnrollmentResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/user/mfa/enroll [post]
+func EnrollMFA(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		logger.Log.Errorf("failed to generate totp secret: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if err := repository.BeginMFAEnrollment(c.Request.Context(), user.ID, secret); err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(mfaIssuer(), user.Email, secret),
+	})
+}
+
+// ConfirmMFA godoc
+// @Summary Confirm two-factor enrollment
+// @Description Enables two-factor authentication once a code from the authenticator app checks out. Returns one-time recovery codes, which are shown only this once. Every existing session is revoked and a new session is returned.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "Authenticator code"
+// @Success 200 {object} MFAConfirmResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/user/mfa/confirm [post]
+func ConfirmMFA(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		logger.Log.Errorf("failed to generate recovery codes: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if err := repository.ConfirmMFAEnrollment(c.Request.Context(), user.ID, req.Code, codes); err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	session, err := startSession(c.Request.Context(), user.ID, user.Role)
+	if err != nil {
+		logger.Log.Errorf("failed to start session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	logger.Log.Infof("Two-factor authentication enabled for user %d; sessions revoked", user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"recovery_codes": codes,
+		"token":          session.AccessToken,
+		"refresh_token":  session.RefreshToken,
+		"expires_in":     session.ExpiresIn,
+	})
+}
+
+// DisableMFA godoc
+// @Summary Disable two-factor authentication
+// @Description Turns two-factor authentication off after checking a code or recovery code, and discards the recovery codes. Not allowed when the user's role requires it.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "Authenticator or recovery code"
+// @Success 200 {object} GenericSuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/user/mfa/disable [post]
+func DisableMFA(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	if err := repository.DisableMFA(c.Request.Context(), user.ID, req.Code); err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	logger.Log.Infof("Two-factor authentication disabled for user %d", user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replaces the current user's recovery codes after checking a code. Earlier codes stop working. The new codes are shown only this once.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "Authenticator or recovery code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/user/mfa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, _ := middleware.CurrentUser(c)
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		logger.Log.Errorf("failed to generate recovery codes: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if err := repository.RegenerateRecoveryCodes(c.Request.Context(), user.ID, req.Code, codes); err != nil {
+		mfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// GetMFAPolicy godoc
+// @Summary Get roles requiring two-factor authentication
+// @Description Lists the roles whose users must set up two-factor authentication.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} MFAPolicyResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/admin/mfa-policy [get]
+func GetMFAPolicy(c *gin.Context) {
+	roles, err := repository.GetMFARequiredRoles(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"required_roles": roles})
+}
+
+// SetMFAPolicy godoc
+// @Summary Set roles requiring two-factor authentication
+// @Description Replaces the roles whose users must use two-factor authentication. Until they set it up, those users can only reach the two-factor, logout and password endpoints, and they cannot disable it afterwards.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFAPolicyRequest true "Roles"
+// @Success 200 {object} MFAPolicyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/admin/mfa-policy [put]
+func SetMFAPolicy(c *gin.Context) {
+	var req MFAPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	roles := make([]string, 0, len(req.Roles))
+	for _, role := range req.Roles {
+		roles = append(roles, strings.ToLower(strings.TrimSpace(role)))
+	}
+
+	admin, _ := middleware.CurrentUser(c)
+	if err := repository.SetMFARequiredRoles(c.Request.Context(), roles, admin.ID); err != nil {
+		if errors.Is(err, repository.ErrInvalidRole) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Log.Infof("Two-factor authentication required for roles %v by %s", roles, admin.Email)
+	c.JSON(http.StatusOK, gin.H{"required_roles": roles})
+}
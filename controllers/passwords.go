@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"stock-reward-api/logger"
+	"stock-reward-api/mailer"
+	"stock-reward-api/middleware"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptCost reads BCRYPT_COST, defaulting to bcrypt.DefaultCost.
+func bcryptCost() int {
+	v := os.Getenv("BCRYPT_COST")
+	if v == "" {
+		return bcrypt.DefaultCost
+	}
+	cost, err := strconv.Atoi(v)
+	if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		logger.Log.Warnf("invalid BCRYPT_COST %q, using %d", v, bcrypt.DefaultCost)
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost())
+	return string(hash), err
+}
+
+// resetLink builds the link emailed for a reset token from
+// PASSWORD_RESET_URL.
+func resetLink(token string) string {
+	base := os.Getenv("PASSWORD_RESET_URL")
+	if base == "" {
+		base = "http://localhost:8080/reset-password"
+	}
+	return base + "?token=" + url.QueryEscape(token)
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Emails a single-use password reset link, valid for PASSWORD_RESET_TTL (default 30m), to the address if it belongs to a user. The response is the same whether or not it does. Requesting a new link invalidates earlier ones.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Email"
+// @Success 202 {object} GenericSuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /api/user/forgot-password [post]
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		logger.Log.Errorf("failed to generate reset token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	ttl := tokenTTL("PASSWORD_RESET_TTL", 30*time.Minute)
+
+	user, found, err := repository.CreatePasswordReset(c.Request.Context(), req.Email, token, time.Now().Add(ttl))
+	if err != nil {
+		logger.Log.Errorf("failed to create password reset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if found {
+		// Sent in the background so the response time does not reveal
+		// whether the email is registered.
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			err := mailer.Default.Send(ctx, mailer.Message{
+				To:      user.Email,
+				Subject: "Reset your Stocky password",
+				Body: fmt.Sprintf("Hi %s,\n\nUse this link to choose a new password. It expires in %s and can be used once:\n\n%s\n\nIf you did not ask to reset your password, you can ignore this email.\n",
+					user.Name, ttl, resetLink(token)),
+			})
+			if err != nil {
+				logger.Log.Errorf("failed to send password reset email to user %d: %v", user.ID, err)
+			}
+		}()
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "success",
+		"message": "If the email is registered, a reset link has been sent",
+	})
+}
+
+// ResetPassword godoc
+// @Summary Reset a password
+// @Description Sets a new password using a token from a reset email. The token is consumed and every existing session of the user is revoked.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} GenericSuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /api/user/reset-password [post]
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		logger.Log.Errorf("failed to hash password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	userID, err := repository.ResetPassword(c.Request.Context(), req.Token, hash)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Log.Errorf("failed to reset password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	logger.Log.Infof("Password reset for user %d; sessions revoked", userID)
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "password has been reset; log in with the new password",
+	})
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Changes the current user's password after checking the current one. Every existing session, including this one, is revoked and a new session is returned. A wrong current password counts as a failed login, and throttled attempts are refused (429).
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /api/user/change-password [post]
+func ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, _ := middleware.CurrentUser(c)
+	if !loginAllowed(c, user.Email) {
+		return
+	}
+	current, err := repository.GetPasswordHash(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(current), []byte(req.CurrentPassword)) != nil {
+		loginFailed(c, user.Email, &user.ID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "current password is incorrect"})
+		return
+	}
+
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		logger.Log.Errorf("failed to hash password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if err := repository.ChangePassword(c.Request.Context(), user.ID, hash); err != nil {
+		logger.Log.Errorf("failed to change password for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if err := repository.ClearLoginFailures(c.Request.Context(), user.Email); err != nil {
+		logger.Log.Errorf("failed to clear login failures for user %d: %v", user.ID, err)
+	}
+
+	session, err := startSession(c.Request.Context(), user.ID, user.Role)
+	if err != nil {
+		logger.Log.Errorf("failed to start session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	logger.Log.Infof("Password changed for user %d; sessions revoked", user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"token":         session.AccessToken,
+		"refresh_token": session.RefreshToken,
+		"expires_in":    session.ExpiresIn,
+	})
+}
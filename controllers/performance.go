@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"stock-reward-api/calendar"
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+	"stock-reward-api/performance"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+)
+
+// GetPortfolioPerformance godoc
+// @Summary Get portfolio performance
+// @Description Returns the user's daily end-of-day NAV series with the XIRR (annualised money-weighted return) and cumulative time-weighted return over the range. xirr and twr are null when there is not enough history to compute them.
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param userId path int true "User ID"
+// @Param from query string false "Start date (YYYY-MM-DD)"
+// @Param to query string false "End date (YYYY-MM-DD)"
+// @Success 200 {object} models.PortfolioPerformance
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/stocks/portfolio/{userId}/performance [get]
+func GetPortfolioPerformance(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var from, to time.Time
+	if s := c.Query("from"); s != "" {
+		if from, err = time.ParseInLocation("2006-01-02", s, calendar.Default.Location); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+			return
+		}
+	}
+	if s := c.Query("to"); s != "" {
+		if to, err = time.ParseInLocation("2006-01-02", s, calendar.Default.Location); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+			return
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return
+	}
+
+	var existingID int64
+	err = db.Pool.QueryRow(c.Request.Context(), "SELECT id FROM users WHERE id=$1", userId).Scan(&existingID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id does not exist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	snapshots, err := repository.GetPortfolioSnapshots(c.Request.Context(), userId, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := models.PortfolioPerformance{
+		UserID: userId,
+		From:   c.Query("from"),
+		To:     c.Query("to"),
+		Series: snapshots,
+	}
+	if result.Series == nil {
+		result.Series = []models.PortfolioSnapshot{}
+	}
+
+	series := make([]performance.Point, len(snapshots))
+	for i, s := range snapshots {
+		series[i] = performance.Point{Date: s.Date, NAV: s.NAVINR, Flow: s.NetFlowINR}
+	}
+	if len(series) >= 2 {
+		twr := performance.TWR(series)
+		result.TWR = &twr
+		if xirr, err := performance.XIRR(performance.FlowsFromSeries(series)); err == nil {
+			result.XIRR = &xirr
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
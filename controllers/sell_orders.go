@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"stock-reward-api/broker"
+	"stock-reward-api/db"
+	"stock-reward-api/logger"
+	"stock-reward-api/middleware"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// CreateSellOrder godoc
+// @Summary Sell rewarded shares
+// @Description Places an order for the current user to sell shares they hold. The order is executed immediately through the broker while the market is open; otherwise it stays PENDING (202) and executes at the next open. Proceeds less fees are credited to the user's cash wallet. A repeated client_order_id returns the original order.
+// @Tags Stocks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param order body SellOrderRequest true "Sell order"
+// @Success 201 {object} models.SellOrder
+// @Success 202 {object} models.SellOrder
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/stocks/sell [post]
+func CreateSellOrder(c *gin.Context) {
+	var req SellOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, _ := middleware.CurrentUser(c)
+	order, existing, err := repository.CreateSellOrder(c.Request.Context(), user.ID, req.StockSymbol, req.Quantity, req.ClientOrderID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInsufficientShares):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrKYCNotVerified):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrStockNotTradable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			logger.Log.Errorf("failed to create sell order: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+	if existing {
+		c.JSON(http.StatusOK, order)
+		return
+	}
+
+	executed, err := repository.ExecuteSellOrder(c.Request.Context(), order.ID, broker.Default, broker.DefaultFees)
+	if err != nil {
+		if !errors.Is(err, broker.ErrMarketClosed) {
+			logger.Log.Errorf("failed to execute sell order %s: %v", order.ID, err)
+		}
+		c.JSON(http.StatusAccepted, order)
+		return
+	}
+
+	logger.Log.Infof("Sell order %s for %.6f %s by user %d is %s", executed.ID, executed.Quantity, executed.StockSymbol, user.ID, executed.Status)
+	c.JSON(http.StatusCreated, executed)
+}
+
+// ListSellOrders godoc
+// @Summary List sell orders
+// @Description Returns the user's sell orders, newest first
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 200 {object} SellOrderListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/stocks/orders/{userId} [get]
+func ListSellOrders(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	orders, err := repository.GetUserSellOrders(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id": userId,
+		"orders":  orders,
+	})
+}
+
+// CancelSellOrder godoc
+// @Summary Cancel a sell order
+// @Description Cancels one of the current user's pending sell orders
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Sell order ID"
+// @Success 200 {object} models.SellOrder
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/stocks/sell/{id}/cancel [post]
+func CancelSellOrder(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sell order id"})
+		return
+	}
+
+	user, _ := middleware.CurrentUser(c)
+	order, err := repository.CancelSellOrder(c.Request.Context(), user.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrSellOrderNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrSellOrderState):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// GetWallet godoc
+// @Summary Get cash wallet
+// @Description Returns the user's cash balance from sale proceeds, dividends and other cash ledger entries, with a breakdown by source
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 200 {object} models.Wallet
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/stocks/wallet/{userId} [get]
+func GetWallet(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var existingID int64
+	err = db.Pool.QueryRow(c.Request.Context(), "SELECT id FROM users WHERE id=$1", userId).Scan(&existingID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id does not exist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	wallet, err := repository.GetWallet(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, wallet)
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"stock-reward-api/audit"
+	"stock-reward-api/jwtkeys"
+	"stock-reward-api/logger"
+	"stock-reward-api/middleware"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// session is a freshly issued access and refresh token pair.
+type session struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+}
+
+// tokenTTL reads a duration from the environment, falling back to def.
+func tokenTTL(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		logger.Log.Warnf("invalid %s %q, using %s", name, v, def)
+		return def
+	}
+	return d
+}
+
+// newAccessToken picks the ID and expiry of the next access token, valid
+// for ACCESS_TOKEN_TTL (default 15m).
+func newAccessToken() repository.AccessToken {
+	return repository.AccessToken{
+		JTI:       uuid.NewString(),
+		ExpiresAt: time.Now().Add(tokenTTL("ACCESS_TOKEN_TTL", 15*time.Minute)),
+	}
+}
+
+// signAccessToken signs access for the user carrying their role, with the
+// current signing key named in the kid header.
+func signAccessToken(userID int64, role string, access repository.AccessToken) (string, error) {
+	key, err := jwtkeys.Current().Signing(time.Now())
+	if err != nil {
+		return "", err
+	}
+	claims := jwt.MapClaims{
+		"sub":  userID,
+		"role": role,
+		"jti":  access.JTI,
+		"iat":  time.Now().Unix(),
+		"exp":  access.ExpiresAt.Unix(),
+	}
+	token := jwt.NewWithClaims(key.Method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey())
+}
+
+// newRefreshToken returns an opaque random refresh token and its expiry,
+// REFRESH_TOKEN_TTL (default 720h) from now.
+func newRefreshToken() (string, time.Time, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), time.Now().Add(tokenTTL("REFRESH_TOKEN_TTL", 720*time.Hour)), nil
+}
+
+// startSession issues the first token pair of a new session. The user is
+// recorded as the actor of their own login.
+func startSession(ctx context.Context, userID int64, role string) (session, error) {
+	ctx = audit.WithUser(ctx, userID, role)
+	access := newAccessToken()
+	refresh, refreshExpiresAt, err := newRefreshToken()
+	if err != nil {
+		return session{}, err
+	}
+	if err := repository.CreateSession(ctx, userID, refresh, refreshExpiresAt, access); err != nil {
+		return session{}, err
+	}
+	signed, err := signAccessToken(userID, role, access)
+	if err != nil {
+		return session{}, err
+	}
+	return session{AccessToken: signed, RefreshToken: refresh, ExpiresIn: int64(time.Until(access.ExpiresAt).Seconds())}, nil
+}
+
+// RefreshToken godoc
+// @Summary Refresh an access token
+// @Description Exchanges a refresh token for a new access token and a new refresh token. Each refresh token can be used once; presenting one again revokes the whole session.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param refresh body RefreshRequest true "Refresh token"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/user/refresh [post]
+func RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	access := newAccessToken()
+	refresh, refreshExpiresAt, err := newRefreshToken()
+	if err != nil {
+		logger.Log.Errorf("failed to generate refresh token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	userID, role, err := repository.RotateRefreshToken(c.Request.Context(), req.RefreshToken, refresh, refreshExpiresAt, access)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRefreshTokenReused):
+			logger.Log.Warnf("Refresh token reuse for user %d; session revoked", userID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrInvalidRefreshToken):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			logger.Log.Errorf("failed to rotate refresh token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	signed, err := signAccessToken(userID, role, access)
+	if err != nil {
+		logger.Log.Errorf("failed to sign token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         signed,
+		"refresh_token": refresh,
+		"expires_in":    int64(time.Until(access.ExpiresAt).Seconds()),
+	})
+}
+
+// Logout godoc
+// @Summary Log out
+// @Description Revokes the current session: its refresh tokens and every access token issued from it, including the one used for this request. With all=true every session of the user is revoked.
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Param all query bool false "Log out of every session"
+// @Success 200 {object} GenericSuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/user/logout [post]
+func Logout(c *gin.Context) {
+	user, _ := middleware.CurrentUser(c)
+	access, _ := middleware.CurrentAccessToken(c)
+
+	err := repository.RevokeSession(c.Request.Context(), user.ID, access)
+	if err == nil && c.Query("all") == "true" {
+		err = repository.RevokeUserSessions(c.Request.Context(), user.ID)
+	}
+	if err != nil {
+		logger.Log.Errorf("failed to log out user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "logged out",
+	})
+}
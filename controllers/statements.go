@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-reward-api/calendar"
+	"stock-reward-api/db"
+	"stock-reward-api/logger"
+	"stock-reward-api/pdf"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+)
+
+// statementFlushRows is how many CSV rows are buffered before they are
+// flushed to the client.
+const statementFlushRows = 500
+
+type csvStatementWriter struct {
+	w       *csv.Writer
+	flusher http.Flusher
+	pending int
+	started bool
+}
+
+func (s *csvStatementWriter) Section(title string, columns []repository.StatementColumn) error {
+	if s.started {
+		if err := s.w.Write(nil); err != nil {
+			return err
+		}
+	}
+	s.started = true
+
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.Name
+	}
+	if err := s.w.Write([]string{title}); err != nil {
+		return err
+	}
+	return s.Row(header)
+}
+
+func (s *csvStatementWriter) Row(values []string) error {
+	if err := s.w.Write(values); err != nil {
+		return err
+	}
+	s.pending++
+	if s.pending >= statementFlushRows {
+		s.pending = 0
+		s.w.Flush()
+		s.flusher.Flush()
+		return s.w.Error()
+	}
+	return nil
+}
+
+type pdfStatementWriter struct {
+	w       *pdf.Writer
+	columns []repository.StatementColumn
+}
+
+func (s *pdfStatementWriter) Section(title string, columns []repository.StatementColumn) error {
+	s.columns = columns
+	s.w.Heading(title)
+
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.Name
+	}
+	s.w.Text(s.format(header))
+	s.w.Text(strings.Repeat("-", len(s.format(header))))
+	return nil
+}
+
+func (s *pdfStatementWriter) Row(values []string) error {
+	s.w.Text(s.format(values))
+	return nil
+}
+
+// format pads each value to its column's width, truncating values that do
+// not fit.
+func (s *pdfStatementWriter) format(values []string) string {
+	var b strings.Builder
+	for i, v := range values {
+		width := len(v) + 1
+		if i < len(s.columns) {
+			width = s.columns[i].Width
+		}
+		if len(v) >= width {
+			v = v[:width-1]
+		}
+		b.WriteString(v)
+		b.WriteString(strings.Repeat(" ", width-len(v)))
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// GetPortfolioStatement godoc
+// @Summary Download portfolio statement
+// @Description Streams the user's statement for a date range: opening holdings, every reward and ledger movement in the period, and closing holdings with their valuation. Dates are in the exchange timezone; from defaults to the start of the current month and to defaults to today.
+// @Tags Stocks
+// @Produce text/csv
+// @Produce application/pdf
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Param userId path int true "User ID"
+// @Param from query string false "Start date (YYYY-MM-DD)"
+// @Param to query string false "End date (YYYY-MM-DD)"
+// @Param format query string false "File format" Enums(csv, pdf) default(csv)
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/stocks/portfolio/{userId}/statement [get]
+func GetPortfolioStatement(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	today := calendar.Default.Date(time.Now())
+	from := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+	to := today
+	if s := c.Query("from"); s != "" {
+		if from, err = time.ParseInLocation("2006-01-02", s, calendar.Default.Location); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+			return
+		}
+	}
+	if s := c.Query("to"); s != "" {
+		if to, err = time.ParseInLocation("2006-01-02", s, calendar.Default.Location); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+			return
+		}
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or pdf"})
+		return
+	}
+
+	var existingID int64
+	err = db.Pool.QueryRow(c.Request.Context(), "SELECT id FROM users WHERE id=$1", userId).Scan(&existingID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id does not exist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := fmt.Sprintf("statement-%d-%s-%s.%s", userId, from.Format("20060102"), to.Format("20060102"), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+
+	// Headers are sent with the first row, so errors after this point can
+	// only be logged and the download is cut short.
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		w := &csvStatementWriter{w: csv.NewWriter(c.Writer), flusher: c.Writer}
+		err = repository.WriteStatement(c.Request.Context(), userId, from, to, w)
+		w.w.Flush()
+		if err == nil {
+			err = w.w.Error()
+		}
+	case "pdf":
+		c.Header("Content-Type", "application/pdf")
+		c.Status(http.StatusOK)
+		title := fmt.Sprintf("Portfolio statement - user %d - %s to %s", userId, from.Format("2006-01-02"), to.Format("2006-01-02"))
+		w := &pdfStatementWriter{w: pdf.NewWriter(c.Writer, title)}
+		err = repository.WriteStatement(c.Request.Context(), userId, from, to, w)
+		if closeErr := w.w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		logger.Log.Errorf("Failed to write %s statement for user %d: %v", format, userId, err)
+	}
+}
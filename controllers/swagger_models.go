@@ -1,5 +1,7 @@
 package controllers
 
+import "stock-reward-api/models"
+
 type ErrorResponse struct {
 	Error  string `json:"error" example:"invalid user id"`
 	Status string `json:"status,omitempty" example:"failure"`
@@ -28,14 +30,10 @@ type HistoricalINRResponse struct {
 	History interface{} `json:"history"`
 }
 
-type UserStatsResponse struct {
-	UserID  int64       `json:"user_id" example:"1"`
-	History interface{} `json:"history"`
-}
-
 type PortfolioResponse struct {
-	UserID  int64       `json:"user_id" example:"1"`
-	History interface{} `json:"history"`
+	UserID  int64                     `json:"user_id" example:"1"`
+	History map[string]models.Holding `json:"history"`
+	Totals  models.PortfolioTotals    `json:"totals"`
 }
 
 type RegisterRequest struct {
@@ -50,7 +48,230 @@ type LoginRequest struct {
 }
 
 type AuthResponse struct {
-	Token string `json:"token"`
-	ID    int64  `json:"id"`
-	Name  string `json:"name,omitempty"`
+	Token         string `json:"token"`
+	RefreshToken  string `json:"refresh_token"`
+	ExpiresIn     int64  `json:"expires_in" example:"900"`
+	ID            int64  `json:"id"`
+	Name          string `json:"name,omitempty"`
+	Role          string `json:"role" example:"user"`
+	EmailVerified bool   `json:"email_verified"`
+	MFARequired   bool   `json:"mfa_required,omitempty"`
+	MFAToken      string `json:"mfa_token,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in" example:"900"`
+}
+
+type CorporateActionRequest struct {
+	ActionType      string  `json:"action_type" binding:"required,oneof=SPLIT BONUS SYMBOL_CHANGE MERGER DELISTING" example:"SPLIT"`
+	StockSymbol     string  `json:"stock_symbol" binding:"required" example:"AAPL"`
+	NewSymbol       string  `json:"new_symbol" example:"APPL2"`
+	RatioFrom       float64 `json:"ratio_from" binding:"gte=0" example:"1"`
+	RatioTo         float64 `json:"ratio_to" binding:"gte=0" example:"2"`
+	SettlementPrice float64 `json:"settlement_price" binding:"gte=0" example:"0"`
+	RecordDate      string  `json:"record_date" binding:"required" example:"2024-12-20"`
+	ExDate          string  `json:"ex_date" binding:"required" example:"2024-12-20"`
+	Notes           string  `json:"notes" example:"2-for-1 stock split"`
+}
+
+type SymbolLineageResponse struct {
+	Symbol  string                   `json:"symbol" example:"AAPL"`
+	Symbols []models.StockStatus     `json:"symbols"`
+	Actions []models.CorporateAction `json:"actions"`
+}
+
+type LedgerResponse struct {
+	UserID  int64                `json:"user_id" example:"1"`
+	Symbols []string             `json:"symbols,omitempty"`
+	Entries []models.LedgerEntry `json:"entries"`
+}
+
+type CorporateActionListResponse struct {
+	Actions []models.CorporateAction `json:"actions"`
+}
+
+type DividendRequest struct {
+	StockSymbol    string  `json:"stock_symbol" binding:"required" example:"AAPL"`
+	AmountPerShare float64 `json:"amount_per_share" binding:"required,gt=0" example:"12.5"`
+	RecordDate     string  `json:"record_date" binding:"required" example:"2024-12-20"`
+	PayDate        string  `json:"pay_date" binding:"required" example:"2025-01-10"`
+	Notes          string  `json:"notes" example:"Interim dividend"`
+}
+
+type DividendListResponse struct {
+	Dividends []models.Dividend `json:"dividends"`
+}
+
+type DividendHistoryResponse struct {
+	UserID    int64                        `json:"user_id" example:"1"`
+	Dividends []models.DividendEntitlement `json:"dividends"`
+}
+
+type AllocationResponse struct {
+	UserID     int64             `json:"user_id" example:"1"`
+	Allocation models.Allocation `json:"allocation"`
+}
+
+type SellOrderRequest struct {
+	StockSymbol   string  `json:"stock_symbol" binding:"required" example:"AAPL"`
+	Quantity      float64 `json:"quantity" binding:"required,gt=0" example:"1.5"`
+	ClientOrderID string  `json:"client_order_id" example:"sell-2024-12-20-001"`
+}
+
+type SellOrderListResponse struct {
+	UserID int64              `json:"user_id" example:"1"`
+	Orders []models.SellOrder `json:"orders"`
+}
+
+type BankAccountRequest struct {
+	HolderName    string `json:"holder_name" binding:"required" example:"Asha Rao"`
+	IFSC          string `json:"ifsc" binding:"required" example:"HDFC0001234"`
+	AccountNumber string `json:"account_number" binding:"required" example:"50100123456789"`
+}
+
+type BankAccountListResponse struct {
+	UserID       int64                `json:"user_id" example:"1"`
+	BankAccounts []models.BankAccount `json:"bank_accounts"`
+}
+
+type WithdrawalRequest struct {
+	BankAccountID   string  `json:"bank_account_id" binding:"required" example:"7d9f3c1e-2a4b-4c6d-8e0f-1a2b3c4d5e6f"`
+	AmountINR       float64 `json:"amount_inr" binding:"required,gt=0" example:"2500"`
+	ClientRequestID string  `json:"client_request_id" example:"withdraw-2024-12-20-001"`
+}
+
+type WithdrawalListResponse struct {
+	UserID      int64               `json:"user_id" example:"1"`
+	Withdrawals []models.Withdrawal `json:"withdrawals"`
+}
+
+type ShareTransferRequest struct {
+	RecipientEmail     string  `json:"recipient_email" binding:"required,email" example:"friend@example.com"`
+	StockSymbol        string  `json:"stock_symbol" binding:"required" example:"AAPL"`
+	Quantity           float64 `json:"quantity" binding:"required,gt=0" example:"0.5"`
+	RequiresAcceptance *bool   `json:"requires_acceptance" example:"true"`
+	Note               string  `json:"note" binding:"max=200" example:"Happy birthday!"`
+	ClientTransferID   string  `json:"client_transfer_id" example:"gift-2024-12-20-001"`
+}
+
+type ShareTransferListResponse struct {
+	UserID    int64                  `json:"user_id" example:"1"`
+	Transfers []models.ShareTransfer `json:"transfers"`
+}
+
+type KYCDocumentRequest struct {
+	DocumentType string `json:"document_type" binding:"required" example:"PAN_CARD"`
+	Reference    string `json:"reference" binding:"required,max=500" example:"kyc-vault://user-1/pan-card.pdf"`
+}
+
+type KYCSubmissionRequest struct {
+	PAN       string               `json:"pan" binding:"required" example:"ABCDE1234F"`
+	Documents []KYCDocumentRequest `json:"documents" binding:"required,min=1,dive"`
+}
+
+type KYCRejectionRequest struct {
+	Reason string `json:"reason" binding:"required,max=500" example:"PAN card image is unreadable"`
+}
+
+type KYCListResponse struct {
+	Status   string              `json:"status" example:"PENDING_REVIEW"`
+	Profiles []models.KYCProfile `json:"profiles"`
+}
+
+type KYCApprovalResponse struct {
+	UserID          int64                  `json:"user_id" example:"1"`
+	Status          string                 `json:"status" example:"VERIFIED"`
+	ReleasedRewards []models.PendingReward `json:"released_rewards"`
+	HeldRewards     []models.PendingReward `json:"held_rewards"`
+}
+
+type RoleRequest struct {
+	Role string `json:"role" binding:"required" example:"issuer"`
+}
+
+type APIKeyRequest struct {
+	Name       string   `json:"name" binding:"required,max=100" example:"rewards-service"`
+	Scopes     []string `json:"scopes" binding:"required,min=1" example:"rewards:write"`
+	AllowedIPs []string `json:"allowed_ips" example:"10.0.0.0/8"`
+	ExpiresAt  string   `json:"expires_at" example:"2026-12-31T23:59:59Z"`
+}
+
+type APIKeyCreatedResponse struct {
+	Key    string        `json:"key" example:"sk_1a2b3c4d_q8Xz..."`
+	APIKey models.APIKey `json:"api_key"`
+}
+
+type APIKeyListResponse struct {
+	APIKeys []models.APIKey `json:"api_keys"`
+}
+
+type APIKeyUsageResponse struct {
+	APIKeyID string               `json:"api_key_id" example:"7d9f3c1e-2a4b-4c6d-8e0f-1a2b3c4d5e6f"`
+	Usage    []models.APIKeyUsage `json:"usage"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email" example:"mayuresh@gmail.com"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8" example:"new-password123"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required" example:"password123"`
+	NewPassword     string `json:"new_password" binding:"required,min=8" example:"new-password123"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email" example:"mayuresh@gmail.com"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required" example:"123456"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+type MFAEnrollmentResponse struct {
+	Secret     string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	OTPAuthURI string `json:"otpauth_uri" example:"otpauth://totp/Stocky:mayuresh%40gmail.com?algorithm=SHA1&digits=6&issuer=Stocky&period=30&secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+}
+
+type MFAConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"k3p9x-q2m7d"`
+	Token         string   `json:"token"`
+	RefreshToken  string   `json:"refresh_token"`
+	ExpiresIn     int64    `json:"expires_in" example:"900"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"k3p9x-q2m7d"`
+}
+
+type MFAPolicyRequest struct {
+	Roles []string `json:"roles" binding:"required" example:"admin,support"`
+}
+
+type MFAPolicyResponse struct {
+	RequiredRoles []string `json:"required_roles" example:"admin,support"`
+}
+
+type SecurityEventListResponse struct {
+	Events []models.SecurityEvent `json:"events"`
+}
+
+type AuditLogResponse struct {
+	Entries []models.AuditEntry `json:"entries"`
 }
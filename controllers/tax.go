@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"stock-reward-api/calendar"
+	"stock-reward-api/db"
+	"stock-reward-api/logger"
+	"stock-reward-api/repository"
+	"stock-reward-api/tax"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+)
+
+// GetTaxStatement godoc
+// @Summary Get tax statement
+// @Description Returns the user's statement for an Indian financial year: perquisite value of rewards at grant price, realized short- and long-term capital gains matched against FIFO lots, and dividend income. Use format=csv to download it as CSV.
+// @Tags Stocks
+// @Produce json
+// @Produce text/csv
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Param fy query string false "Financial year, e.g. 2025-26 (defaults to the current year)"
+// @Param format query string false "Response format" Enums(json, csv) default(json)
+// @Success 200 {object} tax.Statement
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/stocks/tax-statement/{userId} [get]
+func GetTaxStatement(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	fy := tax.FinancialYearOf(time.Now().In(calendar.Default.Location))
+	if label := c.Query("fy"); label != "" {
+		if fy, err = tax.ParseFinancialYear(label); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+		return
+	}
+
+	var existingID int64
+	err = db.Pool.QueryRow(c.Request.Context(), "SELECT id FROM users WHERE id=$1", userId).Scan(&existingID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id does not exist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	statement, err := repository.GetTaxStatement(c.Request.Context(), userId, fy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, statement)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="tax-statement-%d-%s.csv"`, userId, fy.Label))
+	c.Status(http.StatusOK)
+	if err := tax.WriteCSV(c.Writer, statement); err != nil {
+		logger.Log.Errorf("Failed to write tax statement CSV for user %d: %v", userId, err)
+	}
+}
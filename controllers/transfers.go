@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"stock-reward-api/logger"
+	"stock-reward-api/middleware"
+	"stock-reward-api/models"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateShareTransfer godoc
+// @Summary Gift shares to another user
+// @Description Transfers shares the current user holds to the user registered with recipient_email. When requires_acceptance is true (the default unless configured otherwise) the shares stay reserved until the recipient accepts (202); otherwise the transfer completes immediately (201). Transfers are subject to daily count and value limits. A repeated client_transfer_id returns the original transfer.
+// @Tags Stocks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param transfer body ShareTransferRequest true "Share transfer"
+// @Success 201 {object} models.ShareTransfer
+// @Success 202 {object} models.ShareTransfer
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /api/stocks/transfers [post]
+func CreateShareTransfer(c *gin.Context) {
+	var req ShareTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	requiresAcceptance := repository.RequireTransferAcceptance
+	if req.RequiresAcceptance != nil {
+		requiresAcceptance = *req.RequiresAcceptance
+	}
+
+	user, _ := middleware.CurrentUser(c)
+	transfer, existing, err := repository.CreateShareTransfer(c.Request.Context(), user.ID, strings.TrimSpace(req.RecipientEmail),
+		req.StockSymbol, req.Quantity, requiresAcceptance, strings.TrimSpace(req.Note), req.ClientTransferID, repository.DefaultTransferLimits)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrKYCNotVerified):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrRecipientNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrTransferSelf), errors.Is(err, repository.ErrStockNotTradable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrInsufficientShares):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrTransferLimit):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		default:
+			logger.Log.Errorf("failed to create share transfer: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+	if existing {
+		c.JSON(http.StatusOK, transfer)
+		return
+	}
+
+	logger.Log.Infof("Share transfer %s of %.6f %s from user %d to user %d is %s", transfer.ID, transfer.Quantity, transfer.StockSymbol, transfer.SenderID, transfer.RecipientID, transfer.Status)
+	if transfer.Status == repository.TransferPendingAcceptance {
+		c.JSON(http.StatusAccepted, transfer)
+		return
+	}
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// ListShareTransfers godoc
+// @Summary List share transfers
+// @Description Returns transfers the user sent or received, newest first
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 200 {object} ShareTransferListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/stocks/transfers/{userId} [get]
+func ListShareTransfers(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	transfers, err := repository.GetUserShareTransfers(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":   userId,
+		"transfers": transfers,
+	})
+}
+
+// AcceptShareTransfer godoc
+// @Summary Accept a share transfer
+// @Description Accepts a transfer sent to the current user, moving the shares into their holdings
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} models.ShareTransfer
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/stocks/transfers/{id}/accept [post]
+func AcceptShareTransfer(c *gin.Context) {
+	updateShareTransfer(c, repository.AcceptShareTransfer)
+}
+
+// DeclineShareTransfer godoc
+// @Summary Decline a share transfer
+// @Description Declines a transfer sent to the current user; the shares stay with the sender
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} models.ShareTransfer
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/stocks/transfers/{id}/decline [post]
+func DeclineShareTransfer(c *gin.Context) {
+	updateShareTransfer(c, repository.DeclineShareTransfer)
+}
+
+// CancelShareTransfer godoc
+// @Summary Cancel a share transfer
+// @Description Cancels a transfer the current user sent that has not been accepted yet
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} models.ShareTransfer
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/stocks/transfers/{id}/cancel [post]
+func CancelShareTransfer(c *gin.Context) {
+	updateShareTransfer(c, repository.CancelShareTransfer)
+}
+
+func updateShareTransfer(c *gin.Context, update func(ctx context.Context, userID int64, id uuid.UUID) (models.ShareTransfer, error)) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transfer id"})
+		return
+	}
+
+	user, _ := middleware.CurrentUser(c)
+	transfer, err := update(c.Request.Context(), user.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrKYCNotVerified):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrTransferNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrTransferState), errors.Is(err, repository.ErrInsufficientShares), errors.Is(err, repository.ErrStockNotTradable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	logger.Log.Infof("Share transfer %s is %s", transfer.ID, transfer.Status)
+	c.JSON(http.StatusOK, transfer)
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"stock-reward-api/logger"
+	"stock-reward-api/middleware"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetUserRole godoc
+// @Summary Change a user's role
+// @Description Sets a user's role to user, issuer, support or admin. Tokens issued under the previous role are rejected, so the user must log in again. Admins cannot change their own role.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Param role body RoleRequest true "Role"
+// @Success 200 {object} GenericSuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/admin/users/{userId}/role [put]
+func SetUserRole(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	role := strings.ToLower(strings.TrimSpace(req.Role))
+
+	admin, _ := middleware.CurrentUser(c)
+	if userId == admin.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot change your own role"})
+		return
+	}
+
+	if err := repository.SetUserRole(c.Request.Context(), userId, role); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	logger.Log.Infof("User %d role set to %s by %s", userId, role, admin.Email)
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "role set to " + role,
+	})
+}
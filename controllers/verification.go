@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-reward-api/logger"
+	"stock-reward-api/mailer"
+	"stock-reward-api/models"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+// verificationSecret is EMAIL_VERIFICATION_SECRET.
+func verificationSecret() ([]byte, error) {
+	secret := os.Getenv("EMAIL_VERIFICATION_SECRET")
+	if secret == "" {
+		return nil, errors.New("EMAIL_VERIFICATION_SECRET is not set")
+	}
+	return []byte(secret), nil
+}
+
+// signVerificationToken returns a token binding the user to the email
+// address until exp. Nothing is stored; changing the address invalidates
+// outstanding links.
+func signVerificationToken(userID int64, email string, exp time.Time) (string, error) {
+	secret, err := verificationSecret()
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d|%d|%s", userID, exp.Unix(), email)))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func parseVerificationToken(token string) (userID int64, email string, err error) {
+	secret, err := verificationSecret()
+	if err != nil {
+		return 0, "", err
+	}
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, "", errInvalidVerificationToken
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return 0, "", errInvalidVerificationToken
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return 0, "", errInvalidVerificationToken
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return 0, "", errInvalidVerificationToken
+	}
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) != 3 {
+		return 0, "", errInvalidVerificationToken
+	}
+	userID, err = strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", errInvalidVerificationToken
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return 0, "", errInvalidVerificationToken
+	}
+	return userID, parts[2], nil
+}
+
+// verificationLink builds the link emailed for a verification token from
+// EMAIL_VERIFICATION_URL.
+func verificationLink(token string) string {
+	base := os.Getenv("EMAIL_VERIFICATION_URL")
+	if base == "" {
+		base = "http://localhost:8080/api/user/verify-email"
+	}
+	return base + "?token=" + url.QueryEscape(token)
+}
+
+// sendVerificationEmail emails the user a verification link, valid for
+// EMAIL_VERIFICATION_TTL (default 24h), in the background.
+func sendVerificationEmail(user models.User) {
+	ttl := tokenTTL("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+	token, err := signVerificationToken(user.ID, user.Email, time.Now().Add(ttl))
+	if err != nil {
+		logger.Log.Errorf("failed to sign verification token for user %d: %v", user.ID, err)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		err := mailer.Default.Send(ctx, mailer.Message{
+			To:      user.Email,
+			Subject: "Verify your Stocky email address",
+			Body: fmt.Sprintf("Hi %s,\n\nPlease confirm this is your email address. The link expires in %s:\n\n%s\n\nIf you did not create a Stocky account, you can ignore this email.\n",
+				user.Name, ttl, verificationLink(token)),
+		})
+		if err != nil {
+			logger.Log.Errorf("failed to send verification email to user %d: %v", user.ID, err)
+		}
+	}()
+}
+
+// VerifyEmail godoc
+// @Summary Verify an email address
+// @Description Marks the user's email address verified using the token from a verification email. Links stop working once they expire or the address changes.
+// @Tags Auth
+// @Produce json
+// @Param token query string true "Verification token"
+// @Success 200 {object} GenericSuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /api/user/verify-email [get]
+func VerifyEmail(c *gin.Context) {
+	userID, email, err := parseVerificationToken(c.Query("token"))
+	if err != nil {
+		if errors.Is(err, errInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Log.Errorf("failed to check verification token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	already, err := repository.VerifyEmail(c.Request.Context(), userID, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidVerificationToken.Error()})
+			return
+		}
+		logger.Log.Errorf("failed to verify email for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	message := "email verified"
+	if already {
+		message = "email already verified"
+	} else {
+		logger.Log.Infof("Email verified for user %d", userID)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": message,
+	})
+}
+
+// ResendVerification godoc
+// @Summary Resend the verification email
+// @Description Emails a new verification link if the address belongs to a user who has not verified it. The response is the same whether or not it does. Sends to one user are limited by EMAIL_VERIFICATION_RESEND_INTERVAL (default 1m) and EMAIL_VERIFICATION_DAILY_LIMIT (default 5 per 24h).
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body ResendVerificationRequest true "Email"
+// @Success 202 {object} GenericSuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /api/user/resend-verification [post]
+func ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, verified, err := repository.GetUserByEmail(c.Request.Context(), req.Email)
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		logger.Log.Errorf("failed to query user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if err == nil && !verified {
+		retryAfter, err := repository.RecordVerificationSend(c.Request.Context(), user.ID, repository.DefaultVerificationResendLimits)
+		if err != nil {
+			if errors.Is(err, repository.ErrVerificationLimited) {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+				return
+			}
+			logger.Log.Errorf("failed to record verification email for user %d: %v", user.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		sendVerificationEmail(user)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "success",
+		"message": "If the email is registered and unverified, a verification link has been sent",
+	})
+}
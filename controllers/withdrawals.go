@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"stock-reward-api/logger"
+	"stock-reward-api/middleware"
+	"stock-reward-api/models"
+	"stock-reward-api/payments"
+	"stock-reward-api/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	ifscPattern          = regexp.MustCompile(`^[A-Z]{4}0[A-Z0-9]{6}$`)
+	accountNumberPattern = regexp.MustCompile(`^[0-9]{9,18}$`)
+)
+
+// AddBankAccount godoc
+// @Summary Register a bank account
+// @Description Registers a bank account for the current user's withdrawals. The account is registered with the payment gateway as a beneficiary; only the masked account number is stored.
+// @Tags Stocks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param account body BankAccountRequest true "Bank account"
+// @Success 201 {object} models.BankAccount
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/stocks/bank-accounts [post]
+func AddBankAccount(c *gin.Context) {
+	var req BankAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.IFSC = strings.ToUpper(strings.TrimSpace(req.IFSC))
+	req.AccountNumber = strings.TrimSpace(req.AccountNumber)
+	if !ifscPattern.MatchString(req.IFSC) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid IFSC"})
+		return
+	}
+	if !accountNumberPattern.MatchString(req.AccountNumber) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account number must be 9 to 18 digits"})
+		return
+	}
+
+	user, _ := middleware.CurrentUser(c)
+	hash := payments.HashAccountNumber(payments.AccountHashKey, req.IFSC, req.AccountNumber)
+	exists, err := repository.BankAccountExists(c.Request.Context(), user.ID, hash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": repository.ErrBankAccountExists.Error()})
+		return
+	}
+
+	beneficiaryID, err := payments.Default.AddBeneficiary(c.Request.Context(), payments.BankAccount{
+		HolderName:    req.HolderName,
+		IFSC:          req.IFSC,
+		AccountNumber: req.AccountNumber,
+	})
+	if err != nil {
+		logger.Log.Errorf("failed to register beneficiary for user %d: %v", user.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "payment gateway rejected the account"})
+		return
+	}
+
+	account, err := repository.CreateBankAccount(c.Request.Context(), models.BankAccount{
+		UserID:              user.ID,
+		HolderName:          req.HolderName,
+		IFSC:                req.IFSC,
+		AccountNumberMasked: payments.MaskAccountNumber(req.AccountNumber),
+	}, hash, beneficiaryID)
+	if err != nil {
+		if errors.Is(err, repository.ErrBankAccountExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, account)
+}
+
+// ListBankAccounts godoc
+// @Summary List bank accounts
+// @Description Returns the user's registered bank accounts with masked account numbers
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 200 {object} BankAccountListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/stocks/bank-accounts/{userId} [get]
+func ListBankAccounts(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	accounts, err := repository.GetBankAccounts(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":       userId,
+		"bank_accounts": accounts,
+	})
+}
+
+// CreateWithdrawal godoc
+// @Summary Withdraw cash to a bank account
+// @Description Requests a payout from the current user's cash wallet to one of their bank accounts. The amount is debited straight away and refunded if the payout fails. Amounts are subject to per-request and daily limits. A repeated client_request_id returns the original withdrawal.
+// @Tags Stocks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param withdrawal body WithdrawalRequest true "Withdrawal"
+// @Success 202 {object} models.Withdrawal
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/stocks/withdrawals [post]
+func CreateWithdrawal(c *gin.Context) {
+	var req WithdrawalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	bankAccountID, err := uuid.Parse(req.BankAccountID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bank account id"})
+		return
+	}
+
+	user, _ := middleware.CurrentUser(c)
+	w, existing, err := repository.CreateWithdrawal(c.Request.Context(), user.ID, bankAccountID, req.AmountINR, req.ClientRequestID, repository.DefaultWithdrawalLimits)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrBankAccountNotFound), errors.Is(err, repository.ErrWithdrawalLimit):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrKYCNotVerified):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrInsufficientFunds):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			logger.Log.Errorf("failed to create withdrawal: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+	if existing {
+		c.JSON(http.StatusOK, w)
+		return
+	}
+
+	processed, err := repository.ProcessWithdrawal(c.Request.Context(), w.ID, payments.Default)
+	if err != nil {
+		// The withdrawal processor retries requests that were not sent.
+		logger.Log.Errorf("failed to process withdrawal %s: %v", w.ID, err)
+		c.JSON(http.StatusAccepted, w)
+		return
+	}
+
+	logger.Log.Infof("Withdrawal %s of %.2f by user %d is %s", processed.ID, processed.AmountINR, user.ID, processed.Status)
+	c.JSON(http.StatusAccepted, processed)
+}
+
+// ListWithdrawals godoc
+// @Summary List withdrawals
+// @Description Returns the user's withdrawals, newest first
+// @Tags Stocks
+// @Produce json
+// @Security BearerAuth
+// @Param userId path int true "User ID"
+// @Success 200 {object} WithdrawalListResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/stocks/withdrawals/{userId} [get]
+func ListWithdrawals(c *gin.Context) {
+	userIdStr := c.Param("userId")
+	userId, err := strconv.ParseInt(userIdStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	withdrawals, err := repository.GetUserWithdrawals(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":     userId,
+		"withdrawals": withdrawals,
+	})
+}
+
+// PaymentCallback godoc
+// @Summary Payment gateway callback
+// @Description Receives payout outcomes from the payment gateway. The body must be signed with PAYMENT_CALLBACK_SECRET in the X-Signature header (hex HMAC-SHA256). Redelivered events are acknowledged without being applied again.
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Param X-Signature header string true "HMAC-SHA256 of the body"
+// @Param callback body payments.Callback true "Callback"
+// @Success 200 {object} models.Withdrawal
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/payments/callback [post]
+func PaymentCallback(c *gin.Context) {
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+	if !payments.Verify([]byte(os.Getenv("PAYMENT_CALLBACK_SECRET")), body, c.GetHeader("X-Signature")) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+		return
+	}
+
+	var cb payments.Callback
+	if err := json.Unmarshal(body, &cb); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if cb.EventID == "" || cb.PayoutRef == "" || (cb.Status != payments.CallbackPaid && cb.Status != payments.CallbackFailed) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "event_id, payout_ref and a status of PAID or FAILED are required"})
+		return
+	}
+
+	w, duplicate, err := repository.HandlePayoutCallback(c.Request.Context(), cb)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrWithdrawalNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, repository.ErrWithdrawalState):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	if !duplicate {
+		logger.Log.Infof("Withdrawal %s is %s", w.ID, w.Status)
+	}
+
+	c.JSON(http.StatusOK, w)
+}
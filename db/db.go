@@ -105,6 +105,31 @@ func ensureTables() error {
     }
     logger.Log.Info("users table created")
 
+    if _, err := Pool.Exec(ctx, `ALTER TABLE users ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'user';`); err != nil {
+        return fmt.Errorf("add users.role: %w", err)
+    }
+
+    // Users who registered before email verification existed are treated as
+    // verified: the column is filled when added and has no default after.
+    emailVerified := `ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at timestamptz DEFAULT now();
+    ALTER TABLE users ALTER COLUMN email_verified_at DROP DEFAULT;`
+
+    if _, err := Pool.Exec(ctx, emailVerified); err != nil {
+        return fmt.Errorf("add users.email_verified_at: %w", err)
+    }
+
+    verificationSends := `CREATE TABLE IF NOT EXISTS email_verification_sends (
+        id bigserial PRIMARY KEY,
+        user_id bigint NOT NULL,
+        sent_at timestamptz NOT NULL DEFAULT now()
+    );
+    CREATE INDEX IF NOT EXISTS email_verification_sends_user_idx ON email_verification_sends (user_id, sent_at);`
+
+    if _, err := Pool.Exec(ctx, verificationSends); err != nil {
+        return fmt.Errorf("create email_verification_sends table: %w", err)
+    }
+    logger.Log.Info("email_verification_sends table created")
+
     stocks := `CREATE TABLE IF NOT EXISTS stocks (
         stock_symbol text PRIMARY KEY,
         price double precision NOT NULL,
@@ -116,7 +141,533 @@ func ensureTables() error {
     }
     logger.Log.Info("stocks table created")
 
-    return nil
+    stockCloses := `CREATE TABLE IF NOT EXISTS stock_closes (
+        stock_symbol text NOT NULL,
+        trade_date date NOT NULL,
+        close_price double precision NOT NULL,
+        recorded_at timestamptz NOT NULL DEFAULT now(),
+        PRIMARY KEY (stock_symbol, trade_date)
+    );`
+
+    if _, err := Pool.Exec(ctx, stockCloses); err != nil {
+        return fmt.Errorf("create stock_closes table: %w", err)
+    }
+    logger.Log.Info("stock_closes table created")
+
+    priceHistory := `CREATE TABLE IF NOT EXISTS stock_price_history (
+        id bigserial PRIMARY KEY,
+        stock_symbol text NOT NULL,
+        price double precision NOT NULL,
+        recorded_at timestamptz NOT NULL DEFAULT now()
+    );
+    CREATE INDEX IF NOT EXISTS stock_price_history_symbol_idx
+        ON stock_price_history (stock_symbol, recorded_at);`
+
+    if _, err := Pool.Exec(ctx, priceHistory); err != nil {
+        return fmt.Errorf("create stock_price_history table: %w", err)
+    }
+    logger.Log.Info("stock_price_history table created")
+
+    if _, err := Pool.Exec(ctx, `ALTER TABLE rewards ADD COLUMN IF NOT EXISTS trade_date date;`); err != nil {
+        return fmt.Errorf("add rewards.trade_date: %w", err)
+    }
+
+    // adjustment_factor accumulates splits and bonus issues so that
+    // shares * adjustment_factor is the post-action quantity of a reward and
+    // its per-share cost is divided by the same factor.
+    if _, err := Pool.Exec(ctx, `ALTER TABLE rewards ADD COLUMN IF NOT EXISTS adjustment_factor double precision NOT NULL DEFAULT 1;`); err != nil {
+        return fmt.Errorf("add rewards.adjustment_factor: %w", err)
+    }
+
+    // grant_price is the per-share price a reward was valued at when granted.
+    // Older rewards only carried it implicitly in their CASH ledger entry.
+    grantPrice := `ALTER TABLE rewards ADD COLUMN IF NOT EXISTS grant_price double precision;
+    UPDATE rewards r SET grant_price = l.amount_inr / r.shares
+    FROM ledger_entries l
+    WHERE r.grant_price IS NULL AND r.shares > 0
+        AND l.reference_id = r.id AND l.entry_type = 'CASH';`
+
+    if _, err := Pool.Exec(ctx, grantPrice); err != nil {
+        return fmt.Errorf("add rewards.grant_price: %w", err)
+    }
+
+    // reference_type says what reference_id points at, e.g. REWARD or
+    // CORPORATE_ACTION. Entries written before it existed all came from rewards.
+    referenceType := `ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS reference_type text;
+    UPDATE ledger_entries SET reference_type = 'REWARD' WHERE reference_type IS NULL;`
+
+    if _, err := Pool.Exec(ctx, referenceType); err != nil {
+        return fmt.Errorf("add ledger_entries.reference_type: %w", err)
+    }
+
+    // STOCK DEBIT entries carry the cost of the shares they add in amount_inr.
+    // Reward entries written before that recorded 0; copy the grant value
+    // over from the matching CASH entry.
+    stockCost := `UPDATE ledger_entries s SET amount_inr = c.amount_inr
+    FROM ledger_entries c
+    WHERE s.reference_type = 'REWARD' AND s.entry_type = 'STOCK' AND s.amount_inr = 0
+        AND c.reference_id = s.reference_id AND c.entry_type = 'CASH';`
+
+    if _, err := Pool.Exec(ctx, stockCost); err != nil {
+        return fmt.Errorf("backfill reward cost basis: %w", err)
+    }
+
+    corporateActions := `CREATE TABLE IF NOT EXISTS corporate_actions (
+        id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+        action_type text NOT NULL,
+        stock_symbol text NOT NULL,
+        ratio_from double precision NOT NULL,
+        ratio_to double precision NOT NULL,
+        record_date date NOT NULL,
+        ex_date date NOT NULL,
+        status text NOT NULL DEFAULT 'PENDING',
+        notes text,
+        created_by bigint NOT NULL,
+        created_at timestamptz NOT NULL DEFAULT now(),
+        applied_at timestamptz
+    );`
+
+    if _, err := Pool.Exec(ctx, corporateActions); err != nil {
+        return fmt.Errorf("create corporate_actions table: %w", err)
+    }
+    logger.Log.Info("corporate_actions table created")
+
+    // Renames, mergers and delistings retire a symbol instead of rewriting
+    // the rewards and ledger entries that reference it.
+    lifecycle := `ALTER TABLE stocks ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'ACTIVE';
+    ALTER TABLE stocks ADD COLUMN IF NOT EXISTS successor_symbol text;
+    ALTER TABLE corporate_actions ADD COLUMN IF NOT EXISTS new_symbol text;
+    ALTER TABLE corporate_actions ADD COLUMN IF NOT EXISTS settlement_price double precision;`
+
+    if _, err := Pool.Exec(ctx, lifecycle); err != nil {
+        return fmt.Errorf("add symbol lifecycle columns: %w", err)
+    }
+
+    instruments := `ALTER TABLE stocks ADD COLUMN IF NOT EXISTS name text;
+    ALTER TABLE stocks ADD COLUMN IF NOT EXISTS sector text;
+    ALTER TABLE stocks ADD COLUMN IF NOT EXISTS industry text;
+    ALTER TABLE stocks ADD COLUMN IF NOT EXISTS market_cap_bucket text;
+    ALTER TABLE stocks ADD COLUMN IF NOT EXISTS exchange text;`
+
+    if _, err := Pool.Exec(ctx, instruments); err != nil {
+        return fmt.Errorf("add instrument metadata columns: %w", err)
+    }
+
+    dividends := `CREATE TABLE IF NOT EXISTS dividends (
+        id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+        stock_symbol text NOT NULL,
+        amount_per_share double precision NOT NULL,
+        record_date date NOT NULL,
+        pay_date date NOT NULL,
+        status text NOT NULL DEFAULT 'DECLARED',
+        notes text,
+        created_by bigint NOT NULL,
+        created_at timestamptz NOT NULL DEFAULT now(),
+        entitled_at timestamptz,
+        paid_at timestamptz
+    );`
+
+    if _, err := Pool.Exec(ctx, dividends); err != nil {
+        return fmt.Errorf("create dividends table: %w", err)
+    }
+    logger.Log.Info("dividends table created")
+
+    entitlements := `CREATE TABLE IF NOT EXISTS dividend_entitlements (
+        id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+        dividend_id uuid NOT NULL,
+        user_id bigint NOT NULL,
+        shares double precision NOT NULL,
+        gross_inr double precision NOT NULL,
+        tds_inr double precision NOT NULL,
+        net_inr double precision NOT NULL,
+        status text NOT NULL DEFAULT 'ENTITLED',
+        created_at timestamptz NOT NULL DEFAULT now(),
+        paid_at timestamptz,
+        UNIQUE (dividend_id, user_id)
+    );`
+
+    if _, err := Pool.Exec(ctx, entitlements); err != nil {
+        return fmt.Errorf("create dividend_entitlements table: %w", err)
+    }
+    logger.Log.Info("dividend_entitlements table created")
+
+    snapshots := `CREATE TABLE IF NOT EXISTS portfolio_snapshots (
+        user_id bigint NOT NULL,
+        snapshot_date date NOT NULL,
+        holdings_value_inr double precision NOT NULL,
+        cost_basis_inr double precision NOT NULL,
+        net_flow_inr double precision NOT NULL,
+        created_at timestamptz NOT NULL DEFAULT now(),
+        PRIMARY KEY (user_id, snapshot_date)
+    );`
+
+    if _, err := Pool.Exec(ctx, snapshots); err != nil {
+        return fmt.Errorf("create portfolio_snapshots table: %w", err)
+    }
+    logger.Log.Info("portfolio_snapshots table created")
+
+    sellOrders := `CREATE TABLE IF NOT EXISTS sell_orders (
+        id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+        user_id bigint NOT NULL,
+        stock_symbol text NOT NULL,
+        quantity double precision NOT NULL,
+        client_order_id text,
+        status text NOT NULL DEFAULT 'PENDING',
+        price double precision,
+        gross_inr double precision,
+        brokerage_inr double precision,
+        stt_inr double precision,
+        exchange_inr double precision,
+        gst_inr double precision,
+        fees_inr double precision,
+        net_inr double precision,
+        broker_ref text,
+        reject_reason text,
+        created_at timestamptz NOT NULL DEFAULT now(),
+        executed_at timestamptz,
+        UNIQUE (user_id, client_order_id)
+    );
+    CREATE INDEX IF NOT EXISTS sell_orders_status_idx ON sell_orders (status, created_at);`
+
+    if _, err := Pool.Exec(ctx, sellOrders); err != nil {
+        return fmt.Errorf("create sell_orders table: %w", err)
+    }
+    logger.Log.Info("sell_orders table created")
+
+    bankAccounts := `CREATE TABLE IF NOT EXISTS bank_accounts (
+        id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+        user_id bigint NOT NULL,
+        holder_name text NOT NULL,
+        ifsc text NOT NULL,
+        account_number_masked text NOT NULL,
+        account_number_hash text NOT NULL,
+        beneficiary_id text NOT NULL,
+        created_at timestamptz NOT NULL DEFAULT now(),
+        UNIQUE (user_id, account_number_hash)
+    );`
+
+    if _, err := Pool.Exec(ctx, bankAccounts); err != nil {
+        return fmt.Errorf("create bank_accounts table: %w", err)
+    }
+    logger.Log.Info("bank_accounts table created")
+
+    withdrawals := `CREATE TABLE IF NOT EXISTS withdrawals (
+        id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+        user_id bigint NOT NULL,
+        bank_account_id uuid NOT NULL,
+        amount_inr double precision NOT NULL,
+        client_request_id text,
+        status text NOT NULL DEFAULT 'REQUESTED',
+        payout_ref text UNIQUE,
+        failure_reason text,
+        created_at timestamptz NOT NULL DEFAULT now(),
+        processing_at timestamptz,
+        completed_at timestamptz,
+        UNIQUE (user_id, client_request_id)
+    );
+    CREATE INDEX IF NOT EXISTS withdrawals_status_idx ON withdrawals (status, created_at);`
+
+    if _, err := Pool.Exec(ctx, withdrawals); err != nil {
+        return fmt.Errorf("create withdrawals table: %w", err)
+    }
+    logger.Log.Info("withdrawals table created")
+
+    paymentCallbacks := `CREATE TABLE IF NOT EXISTS payment_callbacks (
+        event_id text PRIMARY KEY,
+        payout_ref text NOT NULL,
+        status text NOT NULL,
+        reason text,
+        received_at timestamptz NOT NULL DEFAULT now()
+    );`
+
+    if _, err := Pool.Exec(ctx, paymentCallbacks); err != nil {
+        return fmt.Errorf("create payment_callbacks table: %w", err)
+    }
+    logger.Log.Info("payment_callbacks table created")
+
+    shareTransfers := `CREATE TABLE IF NOT EXISTS share_transfers (
+        id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+        sender_id bigint NOT NULL,
+        recipient_id bigint NOT NULL,
+        stock_symbol text NOT NULL,
+        quantity double precision NOT NULL,
+        value_inr double precision NOT NULL,
+        requires_acceptance boolean NOT NULL,
+        status text NOT NULL,
+        note text,
+        client_transfer_id text,
+        created_at timestamptz NOT NULL DEFAULT now(),
+        completed_at timestamptz,
+        UNIQUE (sender_id, client_transfer_id)
+    );
+    CREATE INDEX IF NOT EXISTS share_transfers_sender_idx ON share_transfers (sender_id, created_at);
+    CREATE INDEX IF NOT EXISTS share_transfers_recipient_idx ON share_transfers (recipient_id, created_at);`
+
+    if _, err := Pool.Exec(ctx, shareTransfers); err != nil {
+        return fmt.Errorf("create share_transfers table: %w", err)
+    }
+    logger.Log.Info("share_transfers table created")
+
+    // KYC profile. Shares are only credited, sold or withdrawn against once
+    // kyc_status is VERIFIED.
+    kyc := `ALTER TABLE users ADD COLUMN IF NOT EXISTS pan text;
+    ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_status text NOT NULL DEFAULT 'NOT_SUBMITTED';
+    ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_submitted_at timestamptz;
+    ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_reviewed_at timestamptz;
+    ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_reviewed_by bigint;
+    ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_verified_at timestamptz;
+    ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_rejection_reason text;
+    CREATE INDEX IF NOT EXISTS users_kyc_status_idx ON users (kyc_status, kyc_submitted_at);`
+
+    if _, err := Pool.Exec(ctx, kyc); err != nil {
+        return fmt.Errorf("add users kyc columns: %w", err)
+    }
+
+    kycDocuments := `CREATE TABLE IF NOT EXISTS kyc_documents (
+        id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+        user_id bigint NOT NULL,
+        document_type text NOT NULL,
+        reference text NOT NULL,
+        created_at timestamptz NOT NULL DEFAULT now()
+    );
+    CREATE INDEX IF NOT EXISTS kyc_documents_user_idx ON kyc_documents (user_id, created_at);`
+
+    if _, err := Pool.Exec(ctx, kycDocuments); err != nil {
+        return fmt.Errorf("create kyc_documents table: %w", err)
+    }
+    logger.Log.Info("kyc_documents table created")
+
+    pendingRewards := `CREATE TABLE IF NOT EXISTS pending_rewards (
+        id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+        user_id bigint NOT NULL,
+        stock_symbol text NOT NULL,
+        shares double precision NOT NULL,
+        reward_id text NOT NULL UNIQUE,
+        requested_at timestamptz NOT NULL,
+        fee double precision NOT NULL,
+        status text NOT NULL DEFAULT 'PENDING',
+        released_reward_id uuid,
+        created_at timestamptz NOT NULL DEFAULT now(),
+        released_at timestamptz
+    );
+    CREATE INDEX IF NOT EXISTS pending_rewards_user_idx ON pending_rewards (user_id, status);`
+
+    if _, err := Pool.Exec(ctx, pendingRewards); err != nil {
+        return fmt.Errorf("create pending_rewards table: %w", err)
+    }
+    logger.Log.Info("pending_rewards table created")
+
+    // grant_price is the per-share price a held reward is credited at, kept
+    // in step with its shares by corporate actions.
+    if _, err := Pool.Exec(ctx, `ALTER TABLE pending_rewards ADD COLUMN IF NOT EXISTS grant_price double precision;`); err != nil {
+        return fmt.Errorf("add pending_rewards.grant_price: %w", err)
+    }
+
+    // Refresh tokens are stored as SHA-256 hashes. Each login starts a
+    // family; refreshing marks the token used and issues the next one in the
+    // same family.
+    refreshTokens := `CREATE TABLE IF NOT EXISTS refresh_tokens (
+        id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+        user_id bigint NOT NULL,
+        family_id uuid NOT NULL,
+        token_hash text NOT NULL UNIQUE,
+        access_jti text NOT NULL,
+        access_expires_at timestamptz NOT NULL,
+        created_at timestamptz NOT NULL DEFAULT now(),
+        expires_at timestamptz NOT NULL,
+        used_at timestamptz,
+        revoked_at timestamptz
+    );
+    CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens (family_id);
+    CREATE INDEX IF NOT EXISTS refresh_tokens_user_idx ON refresh_tokens (user_id);
+    CREATE INDEX IF NOT EXISTS refresh_tokens_access_jti_idx ON refresh_tokens (access_jti);`
+
+    if _, err := Pool.Exec(ctx, refreshTokens); err != nil {
+        return fmt.Errorf("create refresh_tokens table: %w", err)
+    }
+    logger.Log.Info("refresh_tokens table created")
+
+    revokedTokens := `CREATE TABLE IF NOT EXISTS revoked_tokens (
+        jti text PRIMARY KEY,
+        user_id bigint NOT NULL,
+        expires_at timestamptz NOT NULL,
+        revoked_at timestamptz NOT NULL DEFAULT now()
+    );`
+
+    if _, err := Pool.Exec(ctx, revokedTokens); err != nil {
+        return fmt.Errorf("create revoked_tokens table: %w", err)
+    }
+    logger.Log.Info("revoked_tokens table created")
+
+    apiKeys := `CREATE TABLE IF NOT EXISTS api_keys (
+        id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+        name text NOT NULL,
+        prefix text NOT NULL,
+        key_hash text NOT NULL UNIQUE,
+        scopes text[] NOT NULL,
+        allowed_ips text[] NOT NULL DEFAULT '{}',
+        expires_at timestamptz,
+        created_by bigint NOT NULL,
+        created_at timestamptz NOT NULL DEFAULT now(),
+        revoked_at timestamptz,
+        last_used_at timestamptz
+    );`
+
+    if _, err := Pool.Exec(ctx, apiKeys); err != nil {
+        return fmt.Errorf("create api_keys table: %w", err)
+    }
+    logger.Log.Info("api_keys table created")
+
+    apiKeyUsage := `CREATE TABLE IF NOT EXISTS api_key_usage (
+        id bigserial PRIMARY KEY,
+        api_key_id uuid NOT NULL,
+        method text NOT NULL,
+        path text NOT NULL,
+        status integer NOT NULL,
+        client_ip text NOT NULL,
+        used_at timestamptz NOT NULL DEFAULT now()
+    );
+    CREATE INDEX IF NOT EXISTS api_key_usage_key_idx ON api_key_usage (api_key_id, used_at);`
+
+    if _, err := Pool.Exec(ctx, apiKeyUsage); err != nil {
+        return fmt.Errorf("create api_key_usage table: %w", err)
+    }
+    logger.Log.Info("api_key_usage table created")
+
+    passwordResets := `CREATE TABLE IF NOT EXISTS password_resets (
+        id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+        user_id bigint NOT NULL,
+        token_hash text NOT NULL UNIQUE,
+        created_at timestamptz NOT NULL DEFAULT now(),
+        expires_at timestamptz NOT NULL,
+        used_at timestamptz
+    );
+    CREATE INDEX IF NOT EXISTS password_resets_user_idx ON password_resets (user_id);`
+
+    if _, err := Pool.Exec(ctx, passwordResets); err != nil {
+        return fmt.Errorf("create password_resets table: %w", err)
+    }
+    logger.Log.Info("password_resets table created")
+
+    userMFA := `ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret text;
+    ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at timestamptz;
+    ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step bigint;`
+
+    if _, err := Pool.Exec(ctx, userMFA); err != nil {
+        return fmt.Errorf("add users totp columns: %w", err)
+    }
+
+    recoveryCodes := `CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+        id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+        user_id bigint NOT NULL,
+        code_hash text NOT NULL,
+        created_at timestamptz NOT NULL DEFAULT now(),
+        used_at timestamptz
+    );
+    CREATE INDEX IF NOT EXISTS mfa_recovery_codes_user_idx ON mfa_recovery_codes (user_id);`
+
+    if _, err := Pool.Exec(ctx, recoveryCodes); err != nil {
+        return fmt.Errorf("create mfa_recovery_codes table: %w", err)
+    }
+    logger.Log.Info("mfa_recovery_codes table created")
+
+    mfaChallenges := `CREATE TABLE IF NOT EXISTS mfa_challenges (
+        id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+        user_id bigint NOT NULL,
+        token_hash text NOT NULL UNIQUE,
+        attempts int NOT NULL DEFAULT 0,
+        created_at timestamptz NOT NULL DEFAULT now(),
+        expires_at timestamptz NOT NULL,
+        used_at timestamptz
+    );`
+
+    if _, err := Pool.Exec(ctx, mfaChallenges); err != nil {
+        return fmt.Errorf("create mfa_challenges table: %w", err)
+    }
+    logger.Log.Info("mfa_challenges table created")
+
+    mfaRequiredRoles := `CREATE TABLE IF NOT EXISTS mfa_required_roles (
+        role text PRIMARY KEY,
+        set_by bigint NOT NULL,
+        set_at timestamptz NOT NULL DEFAULT now()
+    );`
+
+    if _, err := Pool.Exec(ctx, mfaRequiredRoles); err != nil {
+        return fmt.Errorf("create mfa_required_roles table: %w", err)
+    }
+    logger.Log.Info("mfa_required_roles table created")
+
+    loginFailures := `CREATE TABLE IF NOT EXISTS login_failures (
+        key text PRIMARY KEY,
+        failures int NOT NULL DEFAULT 0,
+        last_failure_at timestamptz,
+        next_attempt_at timestamptz,
+        locked_until timestamptz
+    );`
+
+    if _, err := Pool.Exec(ctx, loginFailures); err != nil {
+        return fmt.Errorf("create login_failures table: %w", err)
+    }
+    logger.Log.Info("login_failures table created")
+
+    securityEvents := `CREATE TABLE IF NOT EXISTS security_events (
+        id bigserial PRIMARY KEY,
+        event_type text NOT NULL,
+        user_id bigint,
+        email text,
+        client_ip text,
+        detail text NOT NULL DEFAULT '',
+        created_at timestamptz NOT NULL DEFAULT now()
+    );
+    CREATE INDEX IF NOT EXISTS security_events_created_idx ON security_events (created_at);
+    CREATE INDEX IF NOT EXISTS security_events_type_idx ON security_events (event_type, created_at);`
+
+    if _, err := Pool.Exec(ctx, securityEvents); err != nil {
+        return fmt.Errorf("create security_events table: %w", err)
+    }
+    logger.Log.Info("security_events table created")
+
+    // Audit entries can only be added. The triggers reject updates,
+    // deletes and truncation, whoever attempts them.
+    auditLog := `CREATE TABLE IF NOT EXISTS audit_log (
+        id bigserial PRIMARY KEY,
+        action text NOT NULL,
+        actor_user_id bigint,
+        actor_role text,
+        actor_api_key_id uuid,
+        target_type text NOT NULL,
+        target_id text NOT NULL,
+        before jsonb,
+        after jsonb,
+        client_ip text,
+        request_id text,
+        created_at timestamptz NOT NULL DEFAULT now()
+    );
+    CREATE INDEX IF NOT EXISTS audit_log_created_idx ON audit_log (created_at);
+    CREATE INDEX IF NOT EXISTS audit_log_action_idx ON audit_log (action, created_at);
+    CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor_user_id, created_at);
+    CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log (target_type, target_id);
+    CREATE INDEX IF NOT EXISTS audit_log_request_idx ON audit_log (request_id);
+
+    CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+    BEGIN
+        RAISE EXCEPTION 'audit_log is append-only';
+    END;
+    $$ LANGUAGE plpgsql;
+
+    DROP TRIGGER IF EXISTS audit_log_no_update ON audit_log;
+    CREATE TRIGGER audit_log_no_update BEFORE UPDATE OR DELETE ON audit_log
+        FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+    DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+    CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+        FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();`
+
+    if _, err := Pool.Exec(ctx, auditLog); err != nil {
+        return fmt.Errorf("create audit_log table: %w", err)
+    }
+    logger.Log.Info("audit_log table created")
+
+    return runMigrations(ctx)
 }
 
 func Close() {
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"stock-reward-api/logger"
+)
+
+// migration is a one-off change to existing data. Unlike the statements in
+// ensureTables, which are safe to repeat, a migration runs exactly once and
+// is recorded in schema_migrations.
+type migration struct {
+	name string
+	sql  string
+}
+
+// migrations run in order. Append new ones; never edit or reorder one that
+// has shipped.
+var migrations = []migration{
+	{
+		// CASH entries now follow the STOCK convention: DEBIT is money into
+		// the user's account, CREDIT money out. Dividend, merger cash and
+		// withdrawal entries, and the withdrawal clearing entries, were
+		// booked the other way round. Sale cash entries are rebooked from
+		// the executed orders, which record the gross proceeds and fees.
+		name: "0001_cash_debit_is_inflow",
+		sql: `UPDATE ledger_entries
+		SET direction = CASE direction WHEN 'CREDIT' THEN 'DEBIT' ELSE 'CREDIT' END
+		WHERE (entry_type = 'CASH' AND reference_type IN ('DIVIDEND', 'CORPORATE_ACTION', 'WITHDRAWAL'))
+			OR (entry_type = 'PAYOUT_CLEARING' AND reference_type = 'WITHDRAWAL');
+
+		DELETE FROM ledger_entries WHERE entry_type = 'CASH' AND reference_type = 'SELL';
+
+		INSERT INTO ledger_entries
+		(user_id, entry_type, stock_symbol, amount_inr, direction, reference_id, reference_type, created_at)
+		SELECT user_id, 'CASH', stock_symbol, gross_inr, 'DEBIT', id, 'SELL', executed_at
+		FROM sell_orders WHERE status = 'EXECUTED';
+
+		INSERT INTO ledger_entries
+		(user_id, entry_type, stock_symbol, amount_inr, direction, reference_id, reference_type, created_at)
+		SELECT user_id, 'CASH', stock_symbol, fees_inr, 'CREDIT', id, 'SELL', executed_at
+		FROM sell_orders WHERE status = 'EXECUTED' AND fees_inr > 0;`,
+	},
+	{
+		// Rewards held before grant prices were recorded take the last
+		// price recorded for their symbol when they were granted. Split
+		// history is already rescaled, like their shares.
+		name: "0002_pending_reward_grant_price",
+		sql: `UPDATE pending_rewards p
+		SET grant_price = (
+			SELECT h.price FROM stock_price_history h
+			WHERE h.stock_symbol = p.stock_symbol AND h.recorded_at <= p.requested_at
+			ORDER BY h.recorded_at DESC LIMIT 1
+		)
+		WHERE p.status = 'PENDING' AND p.grant_price IS NULL;`,
+	},
+}
+
+// runMigrations applies every migration not yet recorded in
+// schema_migrations, each in its own transaction. An advisory lock keeps
+// instances starting together from applying the same migration twice.
+func runMigrations(ctx context.Context) error {
+	if _, err := Pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		name text PRIMARY KEY,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	);`); err != nil {
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+
+	for _, m := range migrations {
+		if err := applyMigration(ctx, m); err != nil {
+			return fmt.Errorf("migration %s: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, m migration) error {
+	tx, err := Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('schema_migrations'))`); err != nil {
+		return err
+	}
+	var applied bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE name = $1)`, m.name).Scan(&applied); err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, m.sql); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (name) VALUES ($1)`, m.name); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	logger.Log.Infof("Applied migration %s", m.name)
+	return nil
+}
@@ -0,0 +1,218 @@
+// Package jwtkeys holds the asymmetric keys access tokens are signed with.
+// Keys are listed in a JSON manifest, each with a kid and the time it takes
+// over signing, so rotations can be scheduled ahead. A key is published for
+// verification as soon as it is listed and until Overlap after its
+// successor took over, by which time every token it signed has expired.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms.
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// minRSABits is the smallest RSA modulus accepted.
+const minRSABits = 2048
+
+// Key is one signing key.
+type Key struct {
+	ID         string
+	Algorithm  string
+	ActiveFrom time.Time
+	private    crypto.Signer
+}
+
+// Method is the JWT signing method for the key.
+func (k Key) Method() jwt.SigningMethod {
+	if k.Algorithm == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// PrivateKey is what the key's signing method signs with.
+func (k Key) PrivateKey() crypto.Signer {
+	return k.private
+}
+
+// PublicKey is what the key's signing method verifies with.
+func (k Key) PublicKey() crypto.PublicKey {
+	return k.private.Public()
+}
+
+// Keyset is a schedule of signing keys.
+type Keyset struct {
+	// Keys are ordered by ActiveFrom.
+	Keys    []Key
+	Overlap time.Duration
+}
+
+// manifestEntry is one key in the manifest file.
+type manifestEntry struct {
+	KID            string    `json:"kid"`
+	PrivateKeyFile string    `json:"private_key_file"`
+	ActiveFrom     time.Time `json:"active_from"`
+}
+
+// Load reads the manifest at path. Key file paths are relative to the
+// manifest. It fails unless every key is valid and one is active now.
+func Load(path string, overlap time.Duration) (*Keyset, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []manifestEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s lists no keys", path)
+	}
+
+	ks := &Keyset{Overlap: overlap}
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if e.KID == "" {
+			return nil, errors.New("every key needs a kid")
+		}
+		if seen[e.KID] {
+			return nil, fmt.Errorf("duplicate kid %q", e.KID)
+		}
+		seen[e.KID] = true
+
+		file := e.PrivateKeyFile
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		signer, alg, err := readPrivateKey(file)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", e.KID, err)
+		}
+		ks.Keys = append(ks.Keys, Key{ID: e.KID, Algorithm: alg, ActiveFrom: e.ActiveFrom, private: signer})
+	}
+	sort.SliceStable(ks.Keys, func(i, j int) bool { return ks.Keys[i].ActiveFrom.Before(ks.Keys[j].ActiveFrom) })
+
+	if _, err := ks.Signing(time.Now()); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// readPrivateKey reads a PEM encoded PKCS#8 (or PKCS#1 RSA) private key.
+func readPrivateKey(file string) (crypto.Signer, string, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, "", err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, "", errors.New("no PEM data found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, "", fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, "", fmt.Errorf("RSA key is %d bits; at least %d required", k.N.BitLen(), minRSABits)
+		}
+		return k, AlgRS256, nil
+	case ed25519.PrivateKey:
+		return k, AlgEdDSA, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported key type %T; use RSA or Ed25519", parsed)
+	}
+}
+
+// Signing returns the key new tokens are signed with at now: the latest
+// one whose ActiveFrom has passed.
+func (ks *Keyset) Signing(now time.Time) (Key, error) {
+	for i := len(ks.Keys) - 1; i >= 0; i-- {
+		if !ks.Keys[i].ActiveFrom.After(now) {
+			return ks.Keys[i], nil
+		}
+	}
+	return Key{}, errors.New("no signing key is active yet")
+}
+
+// Published returns the keys tokens may be verified with at now: upcoming
+// keys, the active one, and earlier ones until Overlap after they were
+// replaced.
+func (ks *Keyset) Published(now time.Time) []Key {
+	var keys []Key
+	for i, k := range ks.Keys {
+		if i+1 < len(ks.Keys) {
+			next := ks.Keys[i+1].ActiveFrom
+			if !next.After(now) && now.Sub(next) >= ks.Overlap {
+				continue
+			}
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Lookup returns the published key with kid.
+func (ks *Keyset) Lookup(kid string, now time.Time) (Key, bool) {
+	for _, k := range ks.Published(now) {
+		if k.ID == kid {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// Keyfunc resolves the key a token names in its kid header, refusing
+// tokens whose alg does not match that key.
+func (ks *Keyset) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	k, ok := ks.Lookup(kid, time.Now())
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if t.Method.Alg() != k.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method %v for key %q", t.Header["alg"], kid)
+	}
+	return k.PublicKey(), nil
+}
+
+var current atomic.Pointer[Keyset]
+
+// Current returns the keyset in use, or nil before one is installed.
+func Current() *Keyset {
+	return current.Load()
+}
+
+// Use installs ks as the keyset in use.
+func Use(ks *Keyset) {
+	current.Store(ks)
+}
@@ -0,0 +1,209 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// writeKey writes key to dir as a PKCS#8 PEM file and returns its name.
+func writeKey(t *testing.T, dir, name string, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, name), block, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// writeManifest writes entries as the manifest in dir and returns its path.
+func writeManifest(t *testing.T, dir string, entries []manifestEntry) string {
+	t.Helper()
+	raw, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "keys.json")
+	if err := os.WriteFile(path, raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newEd25519(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+func TestLoadAndRotation(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Truncate(time.Second)
+	old, current, next := now.Add(-48*time.Hour), now.Add(-2*time.Hour), now.Add(24*time.Hour)
+
+	path := writeManifest(t, dir, []manifestEntry{
+		// Listed out of order; Load sorts by ActiveFrom.
+		{KID: "next", PrivateKeyFile: writeKey(t, dir, "next.pem", newEd25519(t)), ActiveFrom: next},
+		{KID: "old", PrivateKeyFile: writeKey(t, dir, "old.pem", rsaKey), ActiveFrom: old},
+		{KID: "current", PrivateKeyFile: writeKey(t, dir, "current.pem", newEd25519(t)), ActiveFrom: current},
+	})
+
+	ks, err := Load(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if ks.Keys[0].ID != "old" || ks.Keys[0].Algorithm != AlgRS256 || ks.Keys[1].Algorithm != AlgEdDSA {
+		t.Fatalf("unexpected key order or algorithms: %+v", ks.Keys)
+	}
+
+	signing := []struct {
+		at   time.Time
+		want string
+	}{
+		{old, "old"},
+		{current.Add(-time.Second), "old"},
+		{current, "current"},
+		{now, "current"},
+		{next, "next"},
+	}
+	for _, tt := range signing {
+		k, err := ks.Signing(tt.at)
+		if err != nil || k.ID != tt.want {
+			t.Errorf("Signing(%s) = %q, %v; want %q", tt.at, k.ID, err, tt.want)
+		}
+	}
+	if _, err := ks.Signing(old.Add(-time.Second)); err == nil {
+		t.Error("Signing before the first key is active succeeded")
+	}
+
+	published := []struct {
+		name string
+		at   time.Time
+		want []string
+	}{
+		{"old key within the overlap", current.Add(30 * time.Minute), []string{"old", "current", "next"}},
+		{"old key after the overlap", current.Add(time.Hour), []string{"current", "next"}},
+		{"after the next rotation", next.Add(2 * time.Hour), []string{"next"}},
+	}
+	for _, tt := range published {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, k := range ks.Published(tt.at) {
+				ids = append(ids, k.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Published = %v, want %v", ids, tt.want)
+			}
+			if got := len(ks.JWKS(tt.at).Keys); got != len(tt.want) {
+				t.Errorf("JWKS has %d keys, want %d", got, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	dir := t.TempDir()
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	good := writeKey(t, dir, "good.pem", newEd25519(t))
+
+	tests := []struct {
+		name    string
+		entries []manifestEntry
+		want    string
+	}{
+		{"no keys", []manifestEntry{}, "lists no keys"},
+		{"missing kid", []manifestEntry{{PrivateKeyFile: good, ActiveFrom: past}}, "kid"},
+		{"duplicate kid", []manifestEntry{
+			{KID: "a", PrivateKeyFile: good, ActiveFrom: past},
+			{KID: "a", PrivateKeyFile: good, ActiveFrom: past},
+		}, "duplicate"},
+		{"weak rsa key", []manifestEntry{{KID: "a", PrivateKeyFile: writeKey(t, dir, "weak.pem", weak), ActiveFrom: past}}, "2048"},
+		{"none active yet", []manifestEntry{{KID: "a", PrivateKeyFile: good, ActiveFrom: time.Now().Add(time.Hour)}}, "no signing key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeManifest(t, dir, tt.entries), time.Hour)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyfunc(t *testing.T) {
+	dir := t.TempDir()
+	path := writeManifest(t, dir, []manifestEntry{
+		{KID: "k1", PrivateKeyFile: writeKey(t, dir, "k1.pem", newEd25519(t)), ActiveFrom: time.Now().Add(-time.Hour)},
+	})
+	ks, err := Load(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ks.Signing(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(kid string) string {
+		tok := jwt.NewWithClaims(key.Method(), jwt.MapClaims{"sub": "1"})
+		if kid != "" {
+			tok.Header["kid"] = kid
+		}
+		s, err := tok.SignedString(key.PrivateKey())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	parse := func(s string) error {
+		_, err := jwt.Parse(s, ks.Keyfunc, jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}))
+		return err
+	}
+
+	if err := parse(sign("k1")); err != nil {
+		t.Errorf("valid token rejected: %v", err)
+	}
+	if err := parse(sign("unknown")); err == nil {
+		t.Error("token with an unknown kid accepted")
+	}
+	if err := parse(sign("")); err == nil {
+		t.Error("token without a kid accepted")
+	}
+
+	// An HMAC token keyed with the public key must not pass as the
+	// Ed25519 key it names.
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "1"})
+	hs.Header["kid"] = "k1"
+	forged, err := hs.SignedString([]byte(key.PublicKey().(ed25519.PublicKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parse(forged); err == nil {
+		t.Error("HS256 token accepted for an EdDSA key")
+	}
+}
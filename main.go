@@ -13,9 +13,15 @@
 // @name Authorization
 // @description Enter JWT as: Bearer <token>
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key for backend services, created by admins
+
 package main
 
 import (
+	"context"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -25,6 +31,8 @@ import (
 	"stock-reward-api/db"
 	_ "stock-reward-api/docs"
 	"stock-reward-api/logger"
+	"stock-reward-api/middleware"
+	"stock-reward-api/repository"
 	"stock-reward-api/routes"
 	"stock-reward-api/utils"
 )
@@ -34,17 +42,44 @@ func main() {
 
     db.Connect()
 	defer db.Close()
+	utils.InitSigningKeys()
 	utils.LoadDummyUsers()
+	utils.BootstrapAdmins()
 	utils.LoadDummyStocks()
+	if err := utils.LoadMarketCalendar(); err != nil {
+		logger.Log.Warnf("Using default market calendar: %v", err)
+	}
+	if err := repository.BackfillTradeDates(context.Background()); err != nil {
+		logger.Log.Errorf("Failed to backfill reward trade dates: %v", err)
+	}
+
+	utils.InitPriceCache()
+	utils.InitBroker()
+	utils.InitPaymentGateway()
+	utils.InitTransferLimits()
+	utils.InitMailer()
+	utils.InitEmailVerification()
+	utils.InitLoginThrottle()
 
-	// Seed RNG for stock price updates
+	// Start the seeded price simulator
 	utils.StartStockPriceUpdater(10 * time.Second)
+	utils.StartCorporateActionProcessor(time.Minute)
+	utils.StartDividendProcessor(time.Minute)
+	utils.StartPortfolioSnapshotter(time.Minute)
+	utils.StartSellOrderProcessor(10 * time.Second)
+	utils.StartWithdrawalProcessor(30 * time.Second)
+	utils.StartTokenCleanup(time.Hour)
+	utils.StartSigningKeyReload(5 * time.Minute)
 	
 	r := gin.Default()
+	r.Use(middleware.RequestID())
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	
 	routes.RegisterRoutes(r)
 	routes.RegisterUserRoutes(r)
+	routes.RegisterAdminRoutes(r)
+	routes.RegisterPaymentRoutes(r)
+	routes.RegisterWellKnownRoutes(r)
 
 	r.Run(":8080")
 }
\ No newline at end of file
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"stock-reward-api/audit"
+	"stock-reward-api/logger"
+	"stock-reward-api/models"
+	"stock-reward-api/repository"
+)
+
+// AuthOrAPIKey authenticates requests carrying an X-API-Key header as that
+// API key and everything else as AuthMiddleware does. Every request made
+// with a key is recorded. Routes using it must check the key's scope with
+// RequireScopeOr.
+func AuthOrAPIKey() gin.HandlerFunc {
+	auth := AuthMiddleware()
+
+	return func(c *gin.Context) {
+		raw := strings.TrimSpace(c.GetHeader("X-API-Key"))
+		if raw == "" {
+			auth(c)
+			return
+		}
+
+		key, err := repository.AuthenticateAPIKey(c.Request.Context(), raw)
+		if err != nil {
+			if !errors.Is(err, repository.ErrInvalidAPIKey) {
+				logger.Log.Errorf("failed to authenticate api key: %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !ipAllowed(c.ClientIP(), key.AllowedIPs) {
+			recordAPIKeyUsage(c, key, http.StatusForbidden)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key not allowed from this address"})
+			return
+		}
+
+		c.Set("api_key", &key)
+		c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), audit.Actor{APIKeyID: &key.ID}))
+		c.Next()
+		recordAPIKeyUsage(c, key, c.Writer.Status())
+	}
+}
+
+func recordAPIKeyUsage(c *gin.Context, key models.APIKey, status int) {
+	usage := models.APIKeyUsage{
+		Method:   c.Request.Method,
+		Path:     c.Request.URL.Path,
+		Status:   status,
+		ClientIP: c.ClientIP(),
+		UsedAt:   time.Now(),
+	}
+	if err := repository.RecordAPIKeyUsage(context.Background(), key.ID, usage); err != nil {
+		logger.Log.Errorf("failed to record usage of api key %s: %v", key.ID, err)
+	}
+}
+
+// ipAllowed reports whether ip matches one of the allowed addresses or
+// CIDR ranges. An empty list allows every address.
+func ipAllowed(ip string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, a := range allowed {
+		if _, network, err := net.ParseCIDR(a); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+			continue
+		}
+		if allowedIP := net.ParseIP(a); allowedIP != nil && allowedIP.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentAPIKey returns the API key the request was authenticated with.
+func CurrentAPIKey(c *gin.Context) (*models.APIKey, bool) {
+	k, ok := c.Get("api_key")
+	if !ok {
+		return nil, false
+	}
+	key, ok := k.(*models.APIKey)
+	return key, ok
+}
+
+// RequireScopeOr allows requests made with an API key holding scope, and
+// applies policy to requests made by users.
+func RequireScopeOr(scope string, policy gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := CurrentAPIKey(c)
+		if !ok {
+			policy(c)
+			return
+		}
+
+		for _, s := range key.Scopes {
+			if s == scope {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key missing scope " + scope})
+	}
+}
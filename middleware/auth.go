@@ -3,15 +3,18 @@ package middleware
 import (
 	"fmt"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	
 	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v5"
 
+	"stock-reward-api/audit"
 	"stock-reward-api/db"
+	"stock-reward-api/jwtkeys"
 	"stock-reward-api/logger"
 	"stock-reward-api/models"
+	"stock-reward-api/repository"
 
 )
 
@@ -24,16 +27,9 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenStr := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer"))
-		secret := os.Getenv("JWT_SECRET")
-		if secret == "" {
-			logger.Log.Warn("JWT_SECRET not set; using empty secret")
-		}
 
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"]) }
-			return []byte(secret), nil
-		})
+		token, err := jwt.Parse(tokenStr, jwtkeys.Current().Keyfunc,
+			jwt.WithValidMethods([]string{jwtkeys.AlgRS256, jwtkeys.AlgEdDSA}))
 		if err != nil || !token.Valid {
 			logger.Log.Errorf("failed to parse/validate token: %v", err)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
@@ -46,6 +42,29 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Every access token carries a jti so it can be revoked on logout or
+		// when its session is compromised.
+		jti, _ := claims["jti"].(string)
+		if jti == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token missing id; log in again"})
+			return
+		}
+		revoked, err := repository.IsTokenRevoked(c.Request.Context(), jti)
+		if err != nil {
+			logger.Log.Errorf("failed to check token revocation: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
+			return
+		}
+		expiresAt, err := claims.GetExpirationTime()
+		if err != nil || expiresAt == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token missing expiry"})
+			return
+		}
+
 		sub, ok := claims["sub"]
 		if !ok {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token missing subject"})
@@ -72,7 +91,10 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		var user models.User
-	err = db.Pool.QueryRow(c.Request.Context(), "SELECT id, name, email, created_at FROM users WHERE id=$1", userID).Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+	err = db.Pool.QueryRow(c.Request.Context(), `
+		SELECT id, name, email, created_at, role, totp_enabled_at IS NOT NULL,
+			EXISTS (SELECT 1 FROM mfa_required_roles r WHERE r.role = users.role)
+		FROM users WHERE id=$1`, userID).Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.Role, &user.MFAEnabled, &user.MFARequired)
 
 		if err != nil {
 			logger.Log.Errorf("failed to load user %d: %v", userID, err)
@@ -80,11 +102,35 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Tokens carry the role they were issued under. A role change
+		// invalidates them so a demoted user cannot keep using the old role.
+		role, _ := claims["role"].(string)
+		if role == "" {
+			role = models.RoleUser
+		}
+		if role != user.Role {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "role has changed; log in again"})
+			return
+		}
+
 		c.Set("user", &user)
+		c.Set("access_token", repository.AccessToken{JTI: jti, ExpiresAt: expiresAt.Time})
+		c.Request = c.Request.WithContext(audit.WithUser(c.Request.Context(), user.ID, user.Role))
 		c.Next()
 	}
 }
 
+// CurrentAccessToken returns the access token the request was authenticated
+// with.
+func CurrentAccessToken(c *gin.Context) (repository.AccessToken, bool) {
+	t, ok := c.Get("access_token")
+	if !ok {
+		return repository.AccessToken{}, false
+	}
+	token, ok := t.(repository.AccessToken)
+	return token, ok
+}
+
 func CurrentUser(c *gin.Context) (*models.User, bool) {
 	u, ok := c.Get("user")
 	if !ok {
@@ -93,3 +139,57 @@ func CurrentUser(c *gin.Context) (*models.User, bool) {
 	usr, ok := u.(*models.User)
 	return usr, ok
 }
+
+// RequireRoles allows the request through only for users holding one of
+// roles. It must run after AuthMiddleware.
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := CurrentUser(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+			return
+		}
+
+		if hasRole(user, roles) {
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient role"})
+	}
+}
+
+// RequireSelfOrRoles allows the request through when the user ID in path
+// parameter param is the current user's, or the current user holds one of
+// roles. It must run after AuthMiddleware.
+func RequireSelfOrRoles(param string, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := CurrentUser(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param(param), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+
+		if id == user.ID || hasRole(user, roles) {
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "access to another user's data is not allowed"})
+	}
+}
+
+func hasRole(user *models.User, roles []string) bool {
+	for _, role := range roles {
+		if user.Role == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"stock-reward-api/models"
+)
+
+func TestRequireSelfOrRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		user *models.User
+		path string
+		want int
+	}{
+		{"own data", &models.User{ID: 7, Role: models.RoleUser}, "/users/7", http.StatusOK},
+		{"another user's data", &models.User{ID: 7, Role: models.RoleUser}, "/users/8", http.StatusForbidden},
+		{"issuer is not a listed role", &models.User{ID: 7, Role: models.RoleIssuer}, "/users/8", http.StatusForbidden},
+		{"support role", &models.User{ID: 7, Role: models.RoleSupport}, "/users/8", http.StatusOK},
+		{"admin role", &models.User{ID: 7, Role: models.RoleAdmin}, "/users/8", http.StatusOK},
+		{"non-numeric id", &models.User{ID: 7, Role: models.RoleAdmin}, "/users/me", http.StatusBadRequest},
+		{"id with leading zero is the same user", &models.User{ID: 7, Role: models.RoleUser}, "/users/007", http.StatusOK},
+		{"unauthenticated", nil, "/users/7", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/users/:userId", func(c *gin.Context) {
+				if tt.user != nil {
+					c.Set("user", tt.user)
+				}
+			}, RequireSelfOrRoles("userId", models.RoleSupport, models.RoleAdmin), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		user *models.User
+		want int
+	}{
+		{"listed role", &models.User{ID: 1, Role: models.RoleAdmin}, http.StatusOK},
+		{"other role", &models.User{ID: 1, Role: models.RoleUser}, http.StatusForbidden},
+		{"unauthenticated", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/admin", func(c *gin.Context) {
+				if tt.user != nil {
+					c.Set("user", tt.user)
+				}
+			}, RequireRoles(models.RoleAdmin), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
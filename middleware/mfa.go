@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireMFA turns away users whose role requires two-factor
+// authentication until they have set it up. Requests made with an API key
+// pass through. It must run after AuthMiddleware or AuthOrAPIKey.
+func RequireMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := CurrentUser(c)
+		if ok && user.MFARequired && !user.MFAEnabled {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "two-factor authentication is required for your role; set it up at /api/user/mfa/enroll"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"stock-reward-api/audit"
+)
+
+// validRequestID bounds request IDs accepted from clients so they are safe
+// to log and store.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID tags every request with the client's X-Request-ID, or a new
+// one, echoes it in the response and records it with the client IP for
+// audit log entries.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+		c.Set("request_id", id)
+		c.Header("X-Request-ID", id)
+		c.Request = c.Request.WithContext(audit.WithRequest(c.Request.Context(), id, c.ClientIP()))
+		c.Next()
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,29 +11,401 @@ type RewardEvent struct {
 	ID          uuid.UUID
 	UserID      int64
 	StockSymbol string
-	Shares    float64
-	ReferenceID    string
+	Shares      float64
+	ReferenceID string
 	RewardedAt  time.Time
 	CreatedAt   time.Time
+	TradeDate   time.Time
+	GrantPrice  float64
 }
 
-
 type LedgerEntry struct {
-	ID          uuid.UUID
-	UserID      int64
-	EntryType   string
-	StockSymbol *string
-	Quantity    *float64
-	AmountINR   *float64
-	Direction   string
-	ReferenceID uuid.UUID
-	CreatedAt   time.Time
+	ID            uuid.UUID
+	UserID        int64
+	EntryType     string
+	StockSymbol   *string
+	Quantity      *float64
+	AmountINR     *float64
+	Direction     string
+	ReferenceID   uuid.UUID
+	ReferenceType string
+	CreatedAt     time.Time
 }
 
+// Roles a user can hold. Users see only their own data, issuers grant
+// rewards, support staff can read any user's data and admins can do
+// everything.
+const (
+	RoleUser    = "user"
+	RoleIssuer  = "issuer"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
 type User struct {
 	ID        int64
 	CreatedAt time.Time
 	Name      string
 	Email     string
-	Password  string	
-}
\ No newline at end of file
+	Password  string
+	Role      string
+	// MFAEnabled and MFARequired report whether the user has set up
+	// two-factor authentication and whether their role requires it.
+	MFAEnabled  bool
+	MFARequired bool
+}
+
+type CorporateAction struct {
+	ID          uuid.UUID `json:"id"`
+	ActionType  string    `json:"action_type"`
+	StockSymbol string    `json:"stock_symbol"`
+	NewSymbol   string    `json:"new_symbol,omitempty"`
+	RatioFrom   float64   `json:"ratio_from"`
+	RatioTo     float64   `json:"ratio_to"`
+	// SettlementPrice is the price fractional merger shares are paid out at,
+	// or the final valuation of a delisted symbol.
+	SettlementPrice float64    `json:"settlement_price,omitempty"`
+	RecordDate      time.Time  `json:"record_date"`
+	ExDate          time.Time  `json:"ex_date"`
+	Status          string     `json:"status"`
+	Notes           string     `json:"notes,omitempty"`
+	CreatedBy       int64      `json:"created_by"`
+	CreatedAt       time.Time  `json:"created_at"`
+	AppliedAt       *time.Time `json:"applied_at,omitempty"`
+}
+
+type CorporateActionImpact struct {
+	UserID     int64   `json:"user_id"`
+	Holding    float64 `json:"holding"`
+	Adjustment float64 `json:"adjustment"`
+	NewSymbol  string  `json:"new_symbol"`
+	NewHolding float64 `json:"new_holding"`
+	CashINR    float64 `json:"cash_inr,omitempty"`
+}
+
+type CorporateActionResult struct {
+	Action      CorporateAction         `json:"action"`
+	DryRun      bool                    `json:"dry_run"`
+	PriceFactor float64                 `json:"price_factor"`
+	Holders     []CorporateActionImpact `json:"holders"`
+}
+
+type Dividend struct {
+	ID             uuid.UUID  `json:"id"`
+	StockSymbol    string     `json:"stock_symbol"`
+	AmountPerShare float64    `json:"amount_per_share"`
+	RecordDate     time.Time  `json:"record_date"`
+	PayDate        time.Time  `json:"pay_date"`
+	Status         string     `json:"status"`
+	Notes          string     `json:"notes,omitempty"`
+	CreatedBy      int64      `json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	EntitledAt     *time.Time `json:"entitled_at,omitempty"`
+	PaidAt         *time.Time `json:"paid_at,omitempty"`
+}
+
+type DividendEntitlement struct {
+	ID             uuid.UUID  `json:"id"`
+	DividendID     uuid.UUID  `json:"dividend_id"`
+	UserID         int64      `json:"user_id"`
+	StockSymbol    string     `json:"stock_symbol"`
+	AmountPerShare float64    `json:"amount_per_share"`
+	RecordDate     time.Time  `json:"record_date"`
+	PayDate        time.Time  `json:"pay_date"`
+	Shares         float64    `json:"shares"`
+	GrossINR       float64    `json:"gross_inr"`
+	TDSINR         float64    `json:"tds_inr"`
+	NetINR         float64    `json:"net_inr"`
+	Status         string     `json:"status"`
+	PaidAt         *time.Time `json:"paid_at,omitempty"`
+}
+
+type StockStatus struct {
+	StockSymbol     string  `json:"stock_symbol"`
+	Status          string  `json:"status"`
+	SuccessorSymbol string  `json:"successor_symbol,omitempty"`
+	Price           float64 `json:"price"`
+}
+
+type SymbolLineage struct {
+	Symbol  string            `json:"symbol"`
+	Symbols []StockStatus     `json:"symbols"`
+	Actions []CorporateAction `json:"actions"`
+}
+
+type Holding struct {
+	StockSymbol       string  `json:"stock_symbol"`
+	Shares            float64 `json:"shares"`
+	StockPrice        float64 `json:"stock_price"`
+	TotalValueINR     float64 `json:"total_value_inr"`
+	CostBasisINR      float64 `json:"cost_basis_inr"`
+	AverageCostINR    float64 `json:"average_cost_inr"`
+	UnrealizedGainINR float64 `json:"unrealized_gain_inr"`
+	UnrealizedGainPct float64 `json:"unrealized_gain_pct"`
+}
+
+type PortfolioTotals struct {
+	TotalValueINR     float64 `json:"total_value_inr"`
+	CostBasisINR      float64 `json:"cost_basis_inr"`
+	UnrealizedGainINR float64 `json:"unrealized_gain_inr"`
+	UnrealizedGainPct float64 `json:"unrealized_gain_pct"`
+}
+
+type PortfolioSnapshot struct {
+	Date         time.Time `json:"date"`
+	NAVINR       float64   `json:"nav_inr"`
+	CostBasisINR float64   `json:"cost_basis_inr"`
+	NetFlowINR   float64   `json:"net_flow_inr"`
+}
+
+type PortfolioPerformance struct {
+	UserID int64               `json:"user_id"`
+	From   string              `json:"from,omitempty"`
+	To     string              `json:"to,omitempty"`
+	Series []PortfolioSnapshot `json:"series"`
+	XIRR   *float64            `json:"xirr"`
+	TWR    *float64            `json:"twr"`
+}
+
+type Instrument struct {
+	StockSymbol     string `json:"stock_symbol"`
+	Name            string `json:"name"`
+	Sector          string `json:"sector"`
+	Industry        string `json:"industry"`
+	MarketCapBucket string `json:"market_cap_bucket"`
+	Exchange        string `json:"exchange"`
+}
+
+type AllocationBucket struct {
+	Key               string   `json:"key"`
+	Symbols           []string `json:"symbols"`
+	TotalValueINR     float64  `json:"total_value_inr"`
+	CostBasisINR      float64  `json:"cost_basis_inr"`
+	UnrealizedGainINR float64  `json:"unrealized_gain_inr"`
+	Weight            float64  `json:"weight"`
+}
+
+type Allocation struct {
+	By            string             `json:"by"`
+	TotalValueINR float64            `json:"total_value_inr"`
+	Buckets       []AllocationBucket `json:"buckets"`
+}
+
+type StatsBucket struct {
+	Key           string  `json:"key"`
+	RewardCount   int     `json:"reward_count"`
+	Shares        float64 `json:"shares"`
+	ValueINR      float64 `json:"value_inr"`
+	GrantValueINR float64 `json:"grant_value_inr"`
+}
+
+type UserStats struct {
+	UserID  int64         `json:"user_id"`
+	Period  string        `json:"period"`
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	GroupBy string        `json:"group_by"`
+	Buckets []StatsBucket `json:"buckets"`
+	Totals  StatsBucket   `json:"totals"`
+}
+
+type SellOrder struct {
+	ID            uuid.UUID  `json:"id"`
+	UserID        int64      `json:"user_id"`
+	StockSymbol   string     `json:"stock_symbol"`
+	Quantity      float64    `json:"quantity"`
+	ClientOrderID string     `json:"client_order_id,omitempty"`
+	Status        string     `json:"status"`
+	Price         *float64   `json:"price,omitempty"`
+	GrossINR      *float64   `json:"gross_inr,omitempty"`
+	BrokerageINR  *float64   `json:"brokerage_inr,omitempty"`
+	STTINR        *float64   `json:"stt_inr,omitempty"`
+	ExchangeINR   *float64   `json:"exchange_inr,omitempty"`
+	GSTINR        *float64   `json:"gst_inr,omitempty"`
+	FeesINR       *float64   `json:"fees_inr,omitempty"`
+	NetINR        *float64   `json:"net_inr,omitempty"`
+	BrokerRef     string     `json:"broker_ref,omitempty"`
+	RejectReason  string     `json:"reject_reason,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ExecutedAt    *time.Time `json:"executed_at,omitempty"`
+}
+
+type Wallet struct {
+	UserID     int64              `json:"user_id"`
+	BalanceINR float64            `json:"balance_inr"`
+	CreditsINR float64            `json:"credits_inr"`
+	DebitsINR  float64            `json:"debits_inr"`
+	BySource   map[string]float64 `json:"by_source"`
+}
+
+type BankAccount struct {
+	ID                  uuid.UUID `json:"id"`
+	UserID              int64     `json:"user_id"`
+	HolderName          string    `json:"holder_name"`
+	IFSC                string    `json:"ifsc"`
+	AccountNumberMasked string    `json:"account_number_masked"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+type Withdrawal struct {
+	ID              uuid.UUID  `json:"id"`
+	UserID          int64      `json:"user_id"`
+	BankAccountID   uuid.UUID  `json:"bank_account_id"`
+	AmountINR       float64    `json:"amount_inr"`
+	ClientRequestID string     `json:"client_request_id,omitempty"`
+	Status          string     `json:"status"`
+	PayoutRef       string     `json:"payout_ref,omitempty"`
+	FailureReason   string     `json:"failure_reason,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ProcessingAt    *time.Time `json:"processing_at,omitempty"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+}
+
+type ShareTransfer struct {
+	ID                 uuid.UUID  `json:"id"`
+	SenderID           int64      `json:"sender_id"`
+	RecipientID        int64      `json:"recipient_id"`
+	StockSymbol        string     `json:"stock_symbol"`
+	Quantity           float64    `json:"quantity"`
+	ValueINR           float64    `json:"value_inr"`
+	RequiresAcceptance bool       `json:"requires_acceptance"`
+	Status             string     `json:"status"`
+	Note               string     `json:"note,omitempty"`
+	ClientTransferID   string     `json:"client_transfer_id,omitempty"`
+	Direction          string     `json:"direction,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	CompletedAt        *time.Time `json:"completed_at,omitempty"`
+}
+
+type KYCDocument struct {
+	ID           uuid.UUID `json:"id"`
+	DocumentType string    `json:"document_type"`
+	Reference    string    `json:"reference"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type PendingReward struct {
+	ID               uuid.UUID  `json:"id"`
+	UserID           int64      `json:"user_id"`
+	StockSymbol      string     `json:"stock_symbol"`
+	Shares           float64    `json:"shares"`
+	RewardID         string     `json:"reward_id"`
+	RequestedAt      time.Time  `json:"requested_at"`
+	GrantPrice       float64    `json:"grant_price,omitempty"`
+	Fee              float64    `json:"-"`
+	Status           string     `json:"status"`
+	ReleasedRewardID *uuid.UUID `json:"released_reward_id,omitempty"`
+	ReleasedAt       *time.Time `json:"released_at,omitempty"`
+}
+
+type KYCProfile struct {
+	UserID          int64           `json:"user_id"`
+	Name            string          `json:"name"`
+	Email           string          `json:"email"`
+	PAN             string          `json:"pan,omitempty"`
+	Status          string          `json:"status"`
+	SubmittedAt     *time.Time      `json:"submitted_at,omitempty"`
+	ReviewedAt      *time.Time      `json:"reviewed_at,omitempty"`
+	ReviewedBy      *int64          `json:"reviewed_by,omitempty"`
+	VerifiedAt      *time.Time      `json:"verified_at,omitempty"`
+	RejectionReason string          `json:"rejection_reason,omitempty"`
+	Documents       []KYCDocument   `json:"documents"`
+	PendingRewards  []PendingReward `json:"pending_rewards"`
+}
+
+// API key scopes.
+const (
+	ScopeRewardsWrite  = "rewards:write"
+	ScopePortfolioRead = "portfolio:read"
+)
+
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedBy  int64      `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+type APIKeyUsage struct {
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Status   int       `json:"status"`
+	ClientIP string    `json:"client_ip"`
+	UsedAt   time.Time `json:"used_at"`
+}
+
+// Security event types.
+const (
+	SecurityEventAccountLocked   = "ACCOUNT_LOCKED"
+	SecurityEventIPLocked        = "IP_LOCKED"
+	SecurityEventAccountUnlocked = "ACCOUNT_UNLOCKED"
+)
+
+type SecurityEvent struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"event_type"`
+	UserID    *int64    `json:"user_id,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Audit log actions.
+const (
+	AuditRewardCreate          = "reward.create"
+	AuditRewardHold            = "reward.hold"
+	AuditRewardRelease         = "reward.release"
+	AuditKYCApprove            = "kyc.approve"
+	AuditKYCReject             = "kyc.reject"
+	AuditRoleChange            = "user.role_change"
+	AuditLogin                 = "auth.login"
+	AuditLoginFailed           = "auth.login_failed"
+	AuditLogout                = "auth.logout"
+	AuditAccountUnlock         = "auth.unlock"
+	AuditPasswordReset         = "auth.password_reset"
+	AuditPasswordChange        = "auth.password_change"
+	AuditMFAEnable             = "mfa.enable"
+	AuditMFADisable            = "mfa.disable"
+	AuditMFAPolicyChange       = "mfa.policy_change"
+	AuditAPIKeyCreate          = "api_key.create"
+	AuditAPIKeyRevoke          = "api_key.revoke"
+	AuditCorporateActionCreate = "corporate_action.create"
+	AuditCorporateActionApply  = "corporate_action.apply"
+	AuditDividendDeclare       = "dividend.declare"
+	AuditDividendEntitle       = "dividend.entitle"
+	AuditDividendPay           = "dividend.pay"
+	AuditSellOrderCreate       = "sell_order.create"
+	AuditSellOrderCancel       = "sell_order.cancel"
+	AuditSellOrderExecute      = "sell_order.execute"
+	AuditSellOrderReject       = "sell_order.reject"
+	AuditWithdrawalCreate      = "withdrawal.create"
+	AuditWithdrawalProcess     = "withdrawal.process"
+	AuditWithdrawalPaid        = "withdrawal.paid"
+	AuditWithdrawalFail        = "withdrawal.fail"
+	AuditTransferCreate        = "transfer.create"
+	AuditTransferAccept        = "transfer.accept"
+	AuditTransferDecline       = "transfer.decline"
+	AuditTransferCancel        = "transfer.cancel"
+)
+
+type AuditEntry struct {
+	ID            int64           `json:"id"`
+	Action        string          `json:"action"`
+	ActorUserID   *int64          `json:"actor_user_id,omitempty"`
+	ActorRole     string          `json:"actor_role,omitempty"`
+	ActorAPIKeyID *uuid.UUID      `json:"actor_api_key_id,omitempty"`
+	TargetType    string          `json:"target_type"`
+	TargetID      string          `json:"target_id"`
+	Before        json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After         json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	ClientIP      string          `json:"client_ip,omitempty"`
+	RequestID     string          `json:"request_id,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
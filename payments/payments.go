@@ -0,0 +1,99 @@
+// Package payments sends payouts to users' bank accounts through a payment
+// gateway. Gateways report the outcome of a payout asynchronously with a
+// signed callback, which may be delivered more than once.
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const (
+	CallbackPaid   = "PAID"
+	CallbackFailed = "FAILED"
+)
+
+var ErrRejected = errors.New("payout rejected by gateway")
+
+// BankAccount identifies the account a beneficiary is paid into.
+type BankAccount struct {
+	HolderName    string
+	IFSC          string
+	AccountNumber string
+}
+
+// Payout asks the gateway to pay AmountINR to a registered beneficiary.
+// Reference is unique per payout and lets the gateway drop duplicates.
+type Payout struct {
+	Reference     string
+	BeneficiaryID string
+	AmountINR     float64
+}
+
+// Callback reports the final outcome of a payout. EventID is unique per
+// event and is used to ignore redelivered callbacks.
+type Callback struct {
+	EventID   string `json:"event_id" binding:"required"`
+	PayoutRef string `json:"payout_ref" binding:"required"`
+	Status    string `json:"status" binding:"required,oneof=PAID FAILED"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Gateway is implemented by payment providers. Account numbers are handed
+// to the gateway once, when the beneficiary is registered, and referred to
+// by beneficiary ID afterwards.
+type Gateway interface {
+	AddBeneficiary(ctx context.Context, account BankAccount) (beneficiaryID string, err error)
+	// InitiatePayout accepts a payout for processing and returns the
+	// gateway's reference for it. The outcome arrives later as a Callback.
+	InitiatePayout(ctx context.Context, payout Payout) (payoutRef string, err error)
+}
+
+// Default is the gateway withdrawals are paid through.
+var Default Gateway
+
+// AccountHashKey keys the hashes that identify registered bank accounts.
+var AccountHashKey []byte
+
+// Sign returns the hex HMAC-SHA256 of body under secret, as sent in the
+// X-Signature header of callbacks.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid signature of body. It always
+// fails for an empty secret.
+func Verify(secret, body []byte, signature string) bool {
+	if len(secret) == 0 {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// MaskAccountNumber hides all but the last four digits of an account
+// number.
+func MaskAccountNumber(number string) string {
+	if len(number) <= 4 {
+		return number
+	}
+	return strings.Repeat("X", len(number)-4) + number[len(number)-4:]
+}
+
+// HashAccountNumber returns a keyed hash identifying an account without
+// storing its number. The key stops the hash being reversed by trying
+// every possible account number.
+func HashAccountNumber(key []byte, ifsc, number string) string {
+	return Sign(key, []byte(strings.ToUpper(ifsc)+":"+number))
+}
@@ -0,0 +1,68 @@
+package payments
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("callback-secret")
+	body := []byte(`{"event_id":"evt_1","status":"PAID"}`)
+	valid := Sign(secret, body)
+
+	tests := []struct {
+		name      string
+		secret    []byte
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{"valid signature", secret, body, valid, true},
+		{"upper case hex", secret, body, strings.ToUpper(valid), true},
+		{"tampered body", secret, []byte(`{"event_id":"evt_1","status":"FAILED"}`), valid, false},
+		{"wrong secret", []byte("other-secret"), body, valid, false},
+		{"empty secret", nil, body, Sign(nil, body), false},
+		{"empty signature", secret, body, "", false},
+		{"not hex", secret, body, "zz" + valid[2:], false},
+		{"truncated", secret, body, valid[:len(valid)-2], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.body, tt.signature); got != tt.want {
+				t.Errorf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashAccountNumber(t *testing.T) {
+	key := []byte("hash-key")
+	h := HashAccountNumber(key, "HDFC0001234", "50100012345678")
+
+	if got := HashAccountNumber(key, "hdfc0001234", "50100012345678"); got != h {
+		t.Error("hash depends on IFSC case")
+	}
+	if got := HashAccountNumber(key, "HDFC0001234", "50100012345679"); got == h {
+		t.Error("different account numbers hash the same")
+	}
+	if got := HashAccountNumber([]byte("other-key"), "HDFC0001234", "50100012345678"); got == h {
+		t.Error("hash does not depend on the key")
+	}
+	if strings.Contains(h, "50100012345678") {
+		t.Error("hash contains the account number")
+	}
+}
+
+func TestMaskAccountNumber(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"50100012345678", "XXXXXXXXXX5678"},
+		{"12345", "X2345"},
+		{"1234", "1234"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := MaskAccountNumber(tt.in); got != tt.want {
+			t.Errorf("MaskAccountNumber(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
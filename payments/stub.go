@@ -0,0 +1,62 @@
+package payments
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Stub is a local gateway that accepts every payout and reports its outcome
+// through Notify after Delay, retrying failed deliveries. A FailureRate share
+// of payouts fail.
+type Stub struct {
+	Delay       time.Duration
+	FailureRate float64
+	Notify      func(ctx context.Context, cb Callback) error
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	accepted map[string]string
+}
+
+func (s *Stub) AddBeneficiary(ctx context.Context, account BankAccount) (string, error) {
+	return "BEN-" + uuid.NewString(), nil
+}
+
+func (s *Stub) InitiatePayout(ctx context.Context, payout Payout) (string, error) {
+	s.mu.Lock()
+	if s.accepted == nil {
+		s.accepted = make(map[string]string)
+		s.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	// Like real gateways, a repeated reference returns the original payout.
+	if ref, ok := s.accepted[payout.Reference]; ok {
+		s.mu.Unlock()
+		return ref, nil
+	}
+	ref := "PAYOUT-" + uuid.NewString()
+	s.accepted[payout.Reference] = ref
+	failed := s.rng.Float64() < s.FailureRate
+	s.mu.Unlock()
+
+	cb := Callback{EventID: uuid.NewString(), PayoutRef: ref, Status: CallbackPaid}
+	if failed {
+		cb.Status = CallbackFailed
+		cb.Reason = "beneficiary bank declined the credit"
+	}
+	if s.Notify != nil {
+		go func() {
+			// Redeliver on failure, as gateways do.
+			for attempt := 0; attempt < 3; attempt++ {
+				time.Sleep(s.Delay)
+				if s.Notify(context.Background(), cb) == nil {
+					return
+				}
+			}
+		}()
+	}
+	return ref, nil
+}
@@ -0,0 +1,221 @@
+// Package pdf writes simple text documents as PDF without buffering the
+// whole document: each page is written to the underlying writer as soon as
+// it is full, and only object offsets are kept in memory.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// A4 landscape, in points.
+	pageWidth  = 842.0
+	pageHeight = 595.0
+	margin     = 36.0
+
+	titleSize   = 8.0
+	headingSize = 11.0
+	textSize    = 7.0
+)
+
+// Fonts are the standard Type 1 fonts every PDF reader provides, so nothing
+// has to be embedded.
+const (
+	fontRegular = "F1"
+	fontMono    = "F2"
+	fontBold    = "F3"
+)
+
+const (
+	objCatalog = 1
+	objPages   = 2
+	objFonts   = 3 // through 5
+	firstFree  = 6
+)
+
+// Writer lays out lines of text top to bottom, starting a new page whenever
+// the current one is full.
+type Writer struct {
+	w       *countingWriter
+	title   string
+	offsets map[int]int64
+	next    int
+	pages   []int
+	page    *bytes.Buffer
+	y       float64
+	err     error
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// NewWriter starts a document titled title on w. The title is repeated at
+// the top of every page.
+func NewWriter(w io.Writer, title string) *Writer {
+	p := &Writer{
+		w:       &countingWriter{w: w},
+		title:   title,
+		offsets: make(map[int]int64),
+		next:    firstFree,
+	}
+
+	p.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	p.object(objCatalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", objPages))
+	for i, base := range []string{"Helvetica", "Courier", "Helvetica-Bold"} {
+		p.object(objFonts+i, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", base))
+	}
+	return p
+}
+
+// Heading writes a line in bold, leaving a gap above it.
+func (p *Writer) Heading(s string) {
+	if p.page != nil && p.y < pageHeight-margin-2*headingSize {
+		p.y -= headingSize / 2
+	}
+	p.line(fontBold, headingSize, s)
+}
+
+// Text writes a line in a monospace font, so callers can align columns
+// with spaces.
+func (p *Writer) Text(s string) {
+	p.line(fontMono, textSize, s)
+}
+
+// Blank leaves an empty line.
+func (p *Writer) Blank() {
+	p.line(fontMono, textSize, "")
+}
+
+func (p *Writer) line(font string, size float64, s string) {
+	if p.err != nil {
+		return
+	}
+	leading := size * 1.3
+	if p.page == nil || p.y-leading < margin {
+		p.newPage()
+	}
+	p.y -= leading
+	if s != "" {
+		fmt.Fprintf(p.page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, margin, p.y, escape(s))
+	}
+}
+
+func (p *Writer) newPage() {
+	p.flushPage()
+	p.page = &bytes.Buffer{}
+	p.y = pageHeight - margin
+	if p.title != "" {
+		p.y -= titleSize * 1.3
+		fmt.Fprintf(p.page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", fontRegular, titleSize, margin, p.y, escape(p.title))
+		fmt.Fprintf(p.page, "BT /%s %.1f Tf %.2f %.2f Td (Page %d) Tj ET\n", fontRegular, titleSize, pageWidth-margin-40, p.y, len(p.pages)+1)
+		p.y -= titleSize
+	}
+}
+
+// flushPage writes the current page's content stream and page object.
+func (p *Writer) flushPage() {
+	if p.page == nil || p.err != nil {
+		return
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(p.page.Bytes())
+	zw.Close()
+
+	content := p.alloc()
+	p.offsets[content] = p.w.n
+	p.printf("%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", content, compressed.Len())
+	if _, err := p.w.Write(compressed.Bytes()); err != nil && p.err == nil {
+		p.err = err
+	}
+	p.printf("\nendstream\nendobj\n")
+
+	page := p.alloc()
+	p.object(page, fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /%s %d 0 R /%s %d 0 R /%s %d 0 R >> >> /Contents %d 0 R >>",
+		objPages, pageWidth, pageHeight, fontRegular, objFonts, fontMono, objFonts+1, fontBold, objFonts+2, content))
+	p.pages = append(p.pages, page)
+	p.page = nil
+
+	if f, ok := p.w.w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+}
+
+// Close writes the final page, the page tree and the cross-reference table.
+// It does not close the underlying writer.
+func (p *Writer) Close() error {
+	if p.page == nil && len(p.pages) == 0 {
+		p.newPage()
+	}
+	p.flushPage()
+
+	kids := make([]string, len(p.pages))
+	for i, n := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", n)
+	}
+	p.object(objPages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+
+	info := p.alloc()
+	p.object(info, fmt.Sprintf("<< /Title (%s) /Producer (stock-reward-api) >>", escape(p.title)))
+
+	xref := p.w.n
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", p.next)
+	for n := 1; n < p.next; n++ {
+		p.printf("%010d 00000 n \n", p.offsets[n])
+	}
+	p.printf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", p.next, objCatalog, info, xref)
+	return p.err
+}
+
+func (p *Writer) alloc() int {
+	n := p.next
+	p.next++
+	return n
+}
+
+func (p *Writer) object(n int, body string) {
+	p.offsets[n] = p.w.n
+	p.printf("%d 0 obj\n%s\nendobj\n", n, body)
+}
+
+func (p *Writer) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	if _, err := fmt.Fprintf(p.w, format, args...); err != nil {
+		p.err = err
+	}
+}
+
+// escape makes s safe inside a PDF string literal. Characters outside
+// printable ASCII are replaced, since the standard fonts only cover
+// WinAnsi.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,134 @@
+package performance
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// CashFlow is an amount paid into (negative) or out of (positive) an
+// investment from the investor's point of view.
+type CashFlow struct {
+	Date   time.Time
+	Amount float64
+}
+
+// Point is one day of a NAV series. Flow is the external money that moved
+// into the portfolio that day (negative when it moved out), valued at the
+// same end-of-day prices as NAV.
+type Point struct {
+	Date time.Time
+	NAV  float64
+	Flow float64
+}
+
+var ErrNoSolution = errors.New("xirr did not converge")
+
+const (
+	daysPerYear   = 365.0
+	maxIterations = 100
+	tolerance     = 1e-7
+)
+
+func npv(rate float64, flows []CashFlow) (value, derivative float64) {
+	t0 := flows[0].Date
+	for _, f := range flows {
+		years := f.Date.Sub(t0).Hours() / 24 / daysPerYear
+		d := math.Pow(1+rate, years)
+		value += f.Amount / d
+		derivative -= years * f.Amount / (d * (1 + rate))
+	}
+	return value, derivative
+}
+
+// XIRR returns the annualised internal rate of return of irregularly spaced
+// cash flows. Flows must contain at least one payment in and one out.
+func XIRR(flows []CashFlow) (float64, error) {
+	if len(flows) < 2 {
+		return 0, ErrNoSolution
+	}
+	var in, out bool
+	for _, f := range flows {
+		in = in || f.Amount < 0
+		out = out || f.Amount > 0
+	}
+	if !in || !out {
+		return 0, ErrNoSolution
+	}
+
+	// Newton-Raphson from a 10% guess usually converges in a few steps.
+	rate := 0.1
+	for i := 0; i < maxIterations; i++ {
+		v, d := npv(rate, flows)
+		if math.Abs(v) < tolerance {
+			return rate, nil
+		}
+		if d == 0 {
+			break
+		}
+		next := rate - v/d
+		if next <= -1 || math.IsNaN(next) || math.IsInf(next, 0) {
+			break
+		}
+		if math.Abs(next-rate) < tolerance {
+			return next, nil
+		}
+		rate = next
+	}
+
+	// Fall back to bisection, which is slower but cannot diverge.
+	lo, hi := -0.9999, 10.0
+	vlo, _ := npv(lo, flows)
+	vhi, _ := npv(hi, flows)
+	if vlo*vhi > 0 {
+		return 0, ErrNoSolution
+	}
+	for i := 0; i < 200; i++ {
+		mid := (lo + hi) / 2
+		vmid, _ := npv(mid, flows)
+		if math.Abs(vmid) < tolerance || hi-lo < tolerance {
+			return mid, nil
+		}
+		if vmid*vlo < 0 {
+			hi = mid
+		} else {
+			lo, vlo = mid, vmid
+		}
+	}
+	return 0, ErrNoSolution
+}
+
+// FlowsFromSeries turns a NAV series into XIRR cash flows: the opening NAV is
+// treated as the initial investment, each later day's flow as a further
+// contribution, and the closing NAV as the final redemption.
+func FlowsFromSeries(series []Point) []CashFlow {
+	if len(series) == 0 {
+		return nil
+	}
+
+	flows := []CashFlow{{Date: series[0].Date, Amount: -series[0].NAV}}
+	for _, p := range series[1:] {
+		if p.Flow != 0 {
+			flows = append(flows, CashFlow{Date: p.Date, Amount: -p.Flow})
+		}
+	}
+	last := series[len(series)-1]
+	flows = append(flows, CashFlow{Date: last.Date, Amount: last.NAV})
+	return flows
+}
+
+// TWR returns the cumulative time-weighted return of a NAV series. Flows are
+// assumed to arrive at the end of the day, so each day's return is
+// (NAV - Flow) / previous NAV. Days that start from an empty portfolio are
+// skipped.
+func TWR(series []Point) float64 {
+	growth := 1.0
+	for i := 1; i < len(series); i++ {
+		prev := series[i-1].NAV
+		if prev <= 0 {
+			continue
+		}
+		growth *= (series[i].NAV - series[i].Flow) / prev
+	}
+	return growth - 1
+}
@@ -0,0 +1,108 @@
+package performance
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestXIRRConverges(t *testing.T) {
+	tests := []struct {
+		name  string
+		flows []CashFlow
+		want  float64
+	}{
+		{
+			name:  "ten percent over one year",
+			flows: []CashFlow{{date("2023-01-01"), -1000}, {date("2024-01-01"), 1100}},
+			want:  0.10,
+		},
+		{
+			name:  "doubling over one year",
+			flows: []CashFlow{{date("2023-01-01"), -1000}, {date("2024-01-01"), 2000}},
+			want:  1.0,
+		},
+		{
+			name:  "half lost over one year",
+			flows: []CashFlow{{date("2023-01-01"), -1000}, {date("2024-01-01"), 500}},
+			want:  -0.5,
+		},
+		{
+			name:  "large return beyond the initial guess",
+			flows: []CashFlow{{date("2023-01-01"), -100}, {date("2024-01-01"), 900}},
+			want:  8.0,
+		},
+		{
+			// The example from the spreadsheet XIRR documentation.
+			name: "irregular flows",
+			flows: []CashFlow{
+				{date("2008-01-01"), -10000},
+				{date("2008-03-01"), 2750},
+				{date("2008-10-30"), 4250},
+				{date("2009-02-15"), 3250},
+				{date("2009-04-01"), 2750},
+			},
+			want: 0.373362535,
+		},
+		{
+			name: "contributions during the period",
+			flows: []CashFlow{
+				{date("2023-01-01"), -1000},
+				{date("2023-07-02"), -1000},
+				{date("2024-01-01"), 2150},
+			},
+			want: 0.1007,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := XIRR(tt.flows)
+			if err != nil {
+				t.Fatalf("XIRR: %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-4 {
+				t.Errorf("XIRR = %.6f, want %.6f", got, tt.want)
+			}
+			if v, _ := npv(got, tt.flows); math.Abs(v) > 1e-4 {
+				t.Errorf("npv at %.6f = %g, want 0", got, v)
+			}
+		})
+	}
+}
+
+func TestXIRRNoSolution(t *testing.T) {
+	tests := []struct {
+		name  string
+		flows []CashFlow
+	}{
+		{"no flows", nil},
+		{"single flow", []CashFlow{{date("2023-01-01"), -1000}}},
+		{"only payments in", []CashFlow{{date("2023-01-01"), -1000}, {date("2024-01-01"), -500}}},
+		{"only payments out", []CashFlow{{date("2023-01-01"), 1000}, {date("2024-01-01"), 500}}},
+		{
+			name: "npv negative at every rate",
+			flows: []CashFlow{
+				{date("2023-01-01"), -1000},
+				{date("2024-01-01"), 500},
+				{date("2025-01-01"), -1000},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := XIRR(tt.flows); err != ErrNoSolution {
+				t.Errorf("XIRR = %v, %v; want ErrNoSolution", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package pricecache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Loader fetches a price from the backing store on a cache miss.
+type Loader func(ctx context.Context, symbol string) (float64, error)
+
+// Update is sent to subscribers whenever a symbol's cached price changes.
+type Update struct {
+	Symbol   string    `json:"symbol"`
+	OldPrice float64   `json:"old_price"`
+	NewPrice float64   `json:"new_price"`
+	At       time.Time `json:"at"`
+}
+
+type Stats struct {
+	Hits        int64  `json:"hits"`
+	Misses      int64  `json:"misses"`
+	Entries     int    `json:"entries"`
+	Subscribers int    `json:"subscribers"`
+	Dropped     int64  `json:"dropped_notifications"`
+	TTL         string `json:"ttl"`
+}
+
+type entry struct {
+	price     float64
+	updatedAt time.Time
+}
+
+// Cache is an in-process read-through price cache. Entries older than the TTL
+// are reloaded through the Loader on the next read.
+type Cache struct {
+	ttl    time.Duration
+	loader Loader
+
+	mu      sync.RWMutex
+	entries map[string]entry
+
+	subsMu  sync.Mutex
+	subs    map[int]chan Update
+	nextSub int
+
+	hits    atomic.Int64
+	misses  atomic.Int64
+	dropped atomic.Int64
+}
+
+var ErrNoLoader = errors.New("price cache has no loader")
+
+// ErrNoPrice is returned by loaders for symbols that have no price.
+var ErrNoPrice = errors.New("no price for symbol")
+
+// Default is the cache shared by the application; main replaces it with one
+// backed by the database.
+var Default = New(30*time.Second, nil)
+
+func New(ttl time.Duration, loader Loader) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		loader:  loader,
+		entries: make(map[string]entry),
+		subs:    make(map[int]chan Update),
+	}
+}
+
+// Get returns the cached price of symbol, loading it on a miss or when the
+// entry has expired.
+func (c *Cache) Get(ctx context.Context, symbol string) (float64, error) {
+	c.mu.RLock()
+	e, ok := c.entries[symbol]
+	c.mu.RUnlock()
+
+	if ok && time.Since(e.updatedAt) < c.ttl {
+		c.hits.Add(1)
+		return e.price, nil
+	}
+	c.misses.Add(1)
+
+	if c.loader == nil {
+		return 0, ErrNoLoader
+	}
+	price, err := c.loader(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+
+	c.Set(symbol, price)
+	return price, nil
+}
+
+// GetMany returns prices for symbols and, separately, the symbols that have
+// no price. Any other load error fails the whole call.
+func (c *Cache) GetMany(ctx context.Context, symbols []string) (prices map[string]float64, missing []string, err error) {
+	prices = make(map[string]float64, len(symbols))
+	for _, symbol := range symbols {
+		price, err := c.Get(ctx, symbol)
+		if errors.Is(err, ErrNoPrice) {
+			missing = append(missing, symbol)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		prices[symbol] = price
+	}
+	return prices, missing, nil
+}
+
+func (c *Cache) Set(symbol string, price float64) {
+	c.SetMany(map[string]float64{symbol: price})
+}
+
+// SetMany refreshes several prices at once and notifies subscribers of every
+// price that changed.
+func (c *Cache) SetMany(prices map[string]float64) {
+	now := time.Now()
+	var updates []Update
+
+	c.mu.Lock()
+	for symbol, price := range prices {
+		old, ok := c.entries[symbol]
+		c.entries[symbol] = entry{price: price, updatedAt: now}
+		if !ok || old.price != price {
+			updates = append(updates, Update{Symbol: symbol, OldPrice: old.price, NewPrice: price, At: now})
+		}
+	}
+	c.mu.Unlock()
+
+	for _, u := range updates {
+		c.publish(u)
+	}
+}
+
+// Invalidate drops symbol so the next read goes to the loader.
+func (c *Cache) Invalidate(symbol string) {
+	c.mu.Lock()
+	delete(c.entries, symbol)
+	c.mu.Unlock()
+}
+
+// Subscribe returns a channel receiving price changes and a function that
+// cancels the subscription. Slow subscribers miss updates rather than block
+// the updater.
+func (c *Cache) Subscribe(buffer int) (<-chan Update, func()) {
+	ch := make(chan Update, buffer)
+
+	c.subsMu.Lock()
+	id := c.nextSub
+	c.nextSub++
+	c.subs[id] = ch
+	c.subsMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			c.subsMu.Lock()
+			delete(c.subs, id)
+			c.subsMu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (c *Cache) publish(u Update) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	for _, ch := range c.subs {
+		select {
+		case ch <- u:
+		default:
+			c.dropped.Add(1)
+		}
+	}
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.RLock()
+	entries := len(c.entries)
+	c.mu.RUnlock()
+
+	c.subsMu.Lock()
+	subscribers := len(c.subs)
+	c.subsMu.Unlock()
+
+	return Stats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Entries:     entries,
+		Subscribers: subscribers,
+		Dropped:     c.dropped.Load(),
+		TTL:         c.ttl.String(),
+	}
+}
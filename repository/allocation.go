@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+)
+
+// Allocation dimensions accepted by GetAllocation and GetRewardLiabilities.
+const (
+	GroupBySector    = "sector"
+	GroupByIndustry  = "industry"
+	GroupByMarketCap = "market_cap"
+	GroupByExchange  = "exchange"
+	GroupBySymbol    = "symbol"
+)
+
+// Unclassified is the bucket for instruments missing the grouped attribute.
+const Unclassified = "UNCLASSIFIED"
+
+var ErrInvalidGrouping = errors.New("by must be one of sector, industry, market_cap, exchange, symbol")
+
+// GetInstruments returns the metadata of the given symbols keyed by symbol.
+func GetInstruments(ctx context.Context, symbols []string) (map[string]models.Instrument, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT stock_symbol, COALESCE(name, ''), COALESCE(sector, ''), COALESCE(industry, ''),
+			COALESCE(market_cap_bucket, ''), COALESCE(exchange, '')
+		FROM stocks
+		WHERE stock_symbol = ANY($1)
+	`, symbols)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]models.Instrument)
+	for rows.Next() {
+		var i models.Instrument
+		if err := rows.Scan(&i.StockSymbol, &i.Name, &i.Sector, &i.Industry, &i.MarketCapBucket, &i.Exchange); err != nil {
+			return nil, err
+		}
+		out[i.StockSymbol] = i
+	}
+	return out, rows.Err()
+}
+
+func groupKey(i models.Instrument, by string) string {
+	var key string
+	switch by {
+	case GroupBySector:
+		key = i.Sector
+	case GroupByIndustry:
+		key = i.Industry
+	case GroupByMarketCap:
+		key = i.MarketCapBucket
+	case GroupByExchange:
+		key = i.Exchange
+	default:
+		key = i.StockSymbol
+	}
+	if key == "" {
+		return Unclassified
+	}
+	return key
+}
+
+// GetAllocation groups the user's holdings by the given dimension and
+// returns each bucket's value and weight of the portfolio.
+func GetAllocation(ctx context.Context, userID int64, by string) (models.Allocation, error) {
+	holdings, err := getHoldings(ctx, userID)
+	if err != nil {
+		return models.Allocation{}, err
+	}
+	return allocate(ctx, holdings, by)
+}
+
+// GetRewardLiabilities groups the shares owed to all users by the given
+// dimension, valued at the latest prices.
+func GetRewardLiabilities(ctx context.Context, by string) (models.Allocation, error) {
+	holdings, err := queryHoldings(ctx, `
+		SELECT stock_symbol, SUM(shares), SUM(cost)
+		FROM (
+			SELECT
+				l.stock_symbol,
+				SUM(`+signedQuantity+`) AS shares,
+				`+averageCost+` AS cost
+			FROM ledger_entries l
+			WHERE l.entry_type = 'STOCK'
+			GROUP BY l.user_id, l.stock_symbol
+			HAVING SUM(`+signedQuantity+`) > 0
+		) h
+		GROUP BY stock_symbol
+		ORDER BY stock_symbol;
+	`)
+	if err != nil {
+		return models.Allocation{}, err
+	}
+	return allocate(ctx, holdings, by)
+}
+
+func allocate(ctx context.Context, holdings []models.Holding, by string) (models.Allocation, error) {
+	switch by {
+	case GroupBySector, GroupByIndustry, GroupByMarketCap, GroupByExchange, GroupBySymbol:
+	default:
+		return models.Allocation{}, ErrInvalidGrouping
+	}
+
+	symbols := make([]string, len(holdings))
+	for i, h := range holdings {
+		symbols[i] = h.StockSymbol
+	}
+	instruments, err := GetInstruments(ctx, symbols)
+	if err != nil {
+		return models.Allocation{}, err
+	}
+
+	result := models.Allocation{By: by, Buckets: []models.AllocationBucket{}}
+	index := make(map[string]int)
+	for _, h := range holdings {
+		inst := instruments[h.StockSymbol]
+		inst.StockSymbol = h.StockSymbol
+		key := groupKey(inst, by)
+
+		i, ok := index[key]
+		if !ok {
+			i = len(result.Buckets)
+			index[key] = i
+			result.Buckets = append(result.Buckets, models.AllocationBucket{Key: key})
+		}
+		b := &result.Buckets[i]
+		b.Symbols = append(b.Symbols, h.StockSymbol)
+		b.TotalValueINR += h.TotalValueINR
+		b.CostBasisINR += h.CostBasisINR
+		result.TotalValueINR += h.TotalValueINR
+	}
+
+	for i := range result.Buckets {
+		b := &result.Buckets[i]
+		b.UnrealizedGainINR = b.TotalValueINR - b.CostBasisINR
+		if result.TotalValueINR > 0 {
+			b.Weight = b.TotalValueINR / result.TotalValueINR
+		}
+	}
+	sort.SliceStable(result.Buckets, func(i, j int) bool {
+		return result.Buckets[i].TotalValueINR > result.Buckets[j].TotalValueINR
+	})
+	return result, nil
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid, expired or revoked api key")
+)
+
+const apiKeyColumns = `id, name, prefix, scopes, allowed_ips, expires_at, created_by, created_at, revoked_at, last_used_at`
+
+func scanAPIKey(row pgx.Row) (models.APIKey, error) {
+	var k models.APIKey
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Scopes, &k.AllowedIPs, &k.ExpiresAt, &k.CreatedBy, &k.CreatedAt, &k.RevokedAt, &k.LastUsedAt)
+	return k, err
+}
+
+// CreateAPIKey stores the hash of key; the key itself is never stored.
+func CreateAPIKey(ctx context.Context, key, prefix, name string, scopes, allowedIPs []string, expiresAt *time.Time, createdBy int64) (models.APIKey, error) {
+	if allowedIPs == nil {
+		allowedIPs = []string{}
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	k, err := scanAPIKey(tx.QueryRow(ctx, `
+		INSERT INTO api_keys (name, prefix, key_hash, scopes, allowed_ips, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+apiKeyColumns,
+		name, prefix, hashToken(key), scopes, allowedIPs, expiresAt, createdBy))
+	if err != nil {
+		return k, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditAPIKeyCreate, "api_key", k.ID.String(), nil, k); err != nil {
+		return k, err
+	}
+	return k, tx.Commit(ctx)
+}
+
+// AuthenticateAPIKey returns the active key matching key. Revoked and
+// expired keys return ErrInvalidAPIKey.
+func AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error) {
+	k, err := scanAPIKey(db.Pool.QueryRow(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > now())
+	`, hashToken(key)))
+	if err == pgx.ErrNoRows {
+		return k, ErrInvalidAPIKey
+	}
+	return k, err
+}
+
+// RevokeAPIKey revokes the key; revoking it again is a no-op.
+func RevokeAPIKey(ctx context.Context, id uuid.UUID) (models.APIKey, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := scanAPIKey(tx.QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1 FOR UPDATE`, id))
+	if err == pgx.ErrNoRows {
+		return before, ErrAPIKeyNotFound
+	}
+	if err != nil || before.RevokedAt != nil {
+		return before, err
+	}
+
+	k, err := scanAPIKey(tx.QueryRow(ctx, `
+		UPDATE api_keys SET revoked_at = now() WHERE id = $1
+		RETURNING `+apiKeyColumns, id))
+	if err != nil {
+		return k, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditAPIKeyRevoke, "api_key", id.String(), before, k); err != nil {
+		return k, err
+	}
+	return k, tx.Commit(ctx)
+}
+
+// GetAPIKeys returns every key, newest first.
+func GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+// RecordAPIKeyUsage logs one request made with the key.
+func RecordAPIKeyUsage(ctx context.Context, id uuid.UUID, u models.APIKeyUsage) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO api_key_usage (api_key_id, method, path, status, client_ip, used_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, id, u.Method, u.Path, u.Status, u.ClientIP, u.UsedAt)
+	if err != nil {
+		return err
+	}
+	_, err = db.Pool.Exec(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, u.UsedAt)
+	return err
+}
+
+// GetAPIKeyUsage returns the key's most recent requests, newest first.
+func GetAPIKeyUsage(ctx context.Context, id uuid.UUID, limit int) ([]models.APIKeyUsage, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT method, path, status, client_ip, used_at
+		FROM api_key_usage
+		WHERE api_key_id = $1
+		ORDER BY used_at DESC
+		LIMIT $2
+	`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.APIKeyUsage
+	for rows.Next() {
+		var u models.APIKeyUsage
+		if err := rows.Scan(&u.Method, &u.Path, &u.Status, &u.ClientIP, &u.UsedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"stock-reward-api/audit"
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// recordAudit adds an entry to the audit log as part of tx, attributed to
+// the actor, client IP and request recorded on ctx. before and after are
+// snapshots of the target and are stored as JSON; either may be nil.
+func recordAudit(ctx context.Context, tx pgx.Tx, action, targetType, targetID string, before, after interface{}) error {
+	beforeJSON, err := auditSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	src := audit.FromContext(ctx)
+	_, err = tx.Exec(ctx, `
+		INSERT INTO audit_log
+		(action, actor_user_id, actor_role, actor_api_key_id, target_type, target_id, before, after, client_ip, request_id)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''))
+	`, action, src.UserID, src.Role, src.APIKeyID, targetType, targetID, beforeJSON, afterJSON, src.ClientIP, src.RequestID)
+	return err
+}
+
+func auditSnapshot(v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// AuditFilter narrows an audit log search. Empty fields match everything.
+type AuditFilter struct {
+	Action      string
+	ActorUserID *int64
+	TargetType  string
+	TargetID    string
+	RequestID   string
+	From        *time.Time
+	To          *time.Time
+}
+
+const auditColumns = `id, action, actor_user_id, COALESCE(actor_role, ''), actor_api_key_id, target_type, target_id,
+	before, after, COALESCE(client_ip, ''), COALESCE(request_id, ''), created_at`
+
+const auditWhere = `
+	WHERE ($1::text = '' OR action = $1)
+		AND ($2::bigint IS NULL OR actor_user_id = $2)
+		AND ($3::text = '' OR target_type = $3)
+		AND ($4::text = '' OR target_id = $4)
+		AND ($5::text = '' OR request_id = $5)
+		AND ($6::timestamptz IS NULL OR created_at >= $6)
+		AND ($7::timestamptz IS NULL OR created_at < $7)`
+
+func (f AuditFilter) args() []interface{} {
+	return []interface{}{f.Action, f.ActorUserID, f.TargetType, f.TargetID, f.RequestID, f.From, f.To}
+}
+
+func scanAuditEntry(row pgx.Row) (models.AuditEntry, error) {
+	var e models.AuditEntry
+	var before, after []byte
+	err := row.Scan(&e.ID, &e.Action, &e.ActorUserID, &e.ActorRole, &e.ActorAPIKeyID, &e.TargetType, &e.TargetID,
+		&before, &after, &e.ClientIP, &e.RequestID, &e.CreatedAt)
+	e.Before, e.After = before, after
+	return e, err
+}
+
+// SearchAuditLog returns up to limit entries matching f, newest first.
+func SearchAuditLog(ctx context.Context, f AuditFilter, limit int) ([]models.AuditEntry, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+auditColumns+`
+		FROM audit_log`+auditWhere+`
+		ORDER BY id DESC
+		LIMIT $8
+	`, append(f.args(), limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// ExportAuditLog passes every entry matching f to fn, oldest first, without
+// holding them all in memory.
+func ExportAuditLog(ctx context.Context, f AuditFilter, fn func(models.AuditEntry) error) error {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+auditColumns+`
+		FROM audit_log`+auditWhere+`
+		ORDER BY id
+	`, f.args()...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
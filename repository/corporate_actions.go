@@ -0,0 +1,519 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"stock-reward-api/calendar"
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	ActionSplit        = "SPLIT"
+	ActionBonus        = "BONUS"
+	ActionSymbolChange = "SYMBOL_CHANGE"
+	ActionMerger       = "MERGER"
+	ActionDelisting    = "DELISTING"
+
+	ActionStatusPending = "PENDING"
+	ActionStatusApplied = "APPLIED"
+
+	StockStatusActive   = "ACTIVE"
+	StockStatusRenamed  = "RENAMED"
+	StockStatusMerged   = "MERGED"
+	StockStatusDelisted = "DELISTED"
+)
+
+var (
+	ErrCorporateActionNotFound = errors.New("corporate action not found")
+	ErrCorporateActionApplied  = errors.New("corporate action already applied")
+	ErrCorporateActionNotDue   = errors.New("corporate action cannot be applied before its record date has passed and its ex-date has been reached")
+)
+
+const corporateActionColumns = `id, action_type, stock_symbol, COALESCE(new_symbol, ''), ratio_from, ratio_to, COALESCE(settlement_price, 0), record_date, ex_date, status, COALESCE(notes, ''), created_by, created_at, applied_at`
+
+func scanCorporateAction(row pgx.Row) (models.CorporateAction, error) {
+	var a models.CorporateAction
+	err := row.Scan(&a.ID, &a.ActionType, &a.StockSymbol, &a.NewSymbol, &a.RatioFrom, &a.RatioTo, &a.SettlementPrice, &a.RecordDate, &a.ExDate, &a.Status, &a.Notes, &a.CreatedBy, &a.CreatedAt, &a.AppliedAt)
+	return a, err
+}
+
+func CreateCorporateAction(ctx context.Context, a models.CorporateAction) (models.CorporateAction, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return a, err
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, `
+		INSERT INTO corporate_actions
+		(action_type, stock_symbol, new_symbol, ratio_from, ratio_to, settlement_price, record_date, ex_date, notes, created_by)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10)
+		RETURNING `+corporateActionColumns,
+		a.ActionType, a.StockSymbol, a.NewSymbol, a.RatioFrom, a.RatioTo, a.SettlementPrice, a.RecordDate, a.ExDate, a.Notes, a.CreatedBy)
+	created, err := scanCorporateAction(row)
+	if err != nil {
+		return created, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditCorporateActionCreate, "corporate_action", created.ID.String(), nil, created); err != nil {
+		return created, err
+	}
+	return created, tx.Commit(ctx)
+}
+
+func GetCorporateActions(ctx context.Context, stockSymbol string) ([]models.CorporateAction, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+corporateActionColumns+`
+		FROM corporate_actions
+		WHERE $1 = '' OR stock_symbol = $1
+		ORDER BY ex_date DESC, created_at DESC
+	`, stockSymbol)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.CorporateAction
+	for rows.Next() {
+		a, err := scanCorporateAction(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// GetDueCorporateActionIDs returns pending actions whose record date has
+// ended and whose ex-date has been reached at time now.
+func GetDueCorporateActionIDs(ctx context.Context, now time.Time) ([]uuid.UUID, error) {
+	today := calendar.Default.Date(now)
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id FROM corporate_actions
+		WHERE status = $1 AND record_date < $2 AND ex_date <= $2
+		ORDER BY ex_date, created_at
+	`, ActionStatusPending, today)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+// holdingMultiplier returns the number of new shares per share held and the
+// factor that converts pre-action prices into post-action prices.
+func holdingMultiplier(a models.CorporateAction) (adjustment, priceFactor float64) {
+	switch a.ActionType {
+	case ActionBonus:
+		// ratio_to bonus shares for every ratio_from held.
+		return a.RatioTo / a.RatioFrom, a.RatioFrom / (a.RatioFrom + a.RatioTo)
+	case ActionSplit:
+		// ratio_from old shares become ratio_to new shares.
+		return a.RatioTo/a.RatioFrom - 1, a.RatioFrom / a.RatioTo
+	default:
+		return 0, 1
+	}
+}
+
+// recordDateCutoff is the instant holdings are frozen for an action: the end
+// of the record date in the exchange timezone.
+func recordDateCutoff(recordDate time.Time) time.Time {
+	return calendar.Default.OnDate(recordDate).AddDate(0, 0, 1)
+}
+
+type holding struct {
+	shares float64
+	cost   float64
+}
+
+// holdersAsOf reconstructs every user's holding of symbol and its cost basis
+// from the ledger as of before.
+func holdersAsOf(ctx context.Context, q pgx.Tx, symbol string, before time.Time) (map[int64]holding, []int64, error) {
+	rows, err := q.Query(ctx, `
+		SELECT l.user_id, SUM(`+signedQuantity+`) AS shares, `+averageCost+` AS cost
+		FROM ledger_entries l
+		WHERE l.entry_type = 'STOCK'
+			AND l.stock_symbol = $1
+			AND l.created_at < $2
+		GROUP BY l.user_id
+		HAVING SUM(`+signedQuantity+`) > 0
+		ORDER BY l.user_id
+	`, symbol, before)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	holdings := make(map[int64]holding)
+	var order []int64
+	for rows.Next() {
+		var userID int64
+		var h holding
+		if err := rows.Scan(&userID, &h.shares, &h.cost); err != nil {
+			return nil, nil, err
+		}
+		holdings[userID] = h
+		order = append(order, userID)
+	}
+	return holdings, order, rows.Err()
+}
+
+// lockActionUsers locks the open sell orders and pending transfers of symbol,
+// then the holdings of every user who owns one of them or has ever held the
+// symbol, so no sale or transfer of it can be checked or posted while an
+// action rewrites it. Rows are locked before holdings, in the same order as
+// ExecuteSellOrder and AcceptShareTransfer, and users in ascending order.
+func lockActionUsers(ctx context.Context, tx pgx.Tx, symbol string) error {
+	users := make(map[int64]bool)
+	for _, q := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{`SELECT user_id FROM sell_orders WHERE stock_symbol = $1 AND status = $2 ORDER BY id FOR UPDATE`, []interface{}{symbol, OrderStatusPending}},
+		{`SELECT sender_id FROM share_transfers WHERE stock_symbol = $1 AND status = $2 ORDER BY id FOR UPDATE`, []interface{}{symbol, TransferPendingAcceptance}},
+		{`SELECT DISTINCT user_id FROM ledger_entries WHERE stock_symbol = $1 AND entry_type = 'STOCK'`, []interface{}{symbol}},
+	} {
+		rows, err := tx.Query(ctx, q.query, q.args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var userID int64
+			if err := rows.Scan(&userID); err != nil {
+				rows.Close()
+				return err
+			}
+			users[userID] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	sorted := make([]int64, 0, len(users))
+	for userID := range users {
+		sorted = append(sorted, userID)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for _, userID := range sorted {
+		if err := lockUserHoldings(ctx, tx, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// impactOf works out what a single holder receives from an action.
+func impactOf(a models.CorporateAction, userID int64, held float64) models.CorporateActionImpact {
+	impact := models.CorporateActionImpact{UserID: userID, Holding: held, NewSymbol: a.StockSymbol, NewHolding: held}
+
+	switch a.ActionType {
+	case ActionSplit, ActionBonus:
+		perShare, _ := holdingMultiplier(a)
+		impact.Adjustment = held * perShare
+		impact.NewHolding = held + impact.Adjustment
+	case ActionSymbolChange:
+		impact.Adjustment = -held
+		impact.NewSymbol = a.NewSymbol
+	case ActionMerger:
+		entitled := held * a.RatioTo / a.RatioFrom
+		whole := math.Floor(entitled + 1e-9)
+		impact.Adjustment = -held
+		impact.NewSymbol = a.NewSymbol
+		impact.NewHolding = whole
+		impact.CashINR = round2((entitled - whole) * a.SettlementPrice)
+	}
+
+	return impact
+}
+
+// ApplyCorporateAction applies an action to every holder of its symbol as of
+// the record date. Splits and bonus issues post adjustment STOCK entries and
+// rescale historical prices, reward cost basis and open sell orders and
+// transfers; symbol changes and mergers move holdings to the new symbol,
+// paying cash for fractional merger shares; delistings freeze the symbol at
+// its final price. Open sell orders and transfers follow a renamed symbol and
+// are closed by a merger or delisting. Existing rewards and ledger entries
+// keep the old symbol so history stays queryable under it.
+// With dryRun the impact is computed and returned without writing anything.
+func ApplyCorporateAction(ctx context.Context, id uuid.UUID, dryRun bool) (*models.CorporateActionResult, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	a, err := scanCorporateAction(tx.QueryRow(ctx, `SELECT `+corporateActionColumns+` FROM corporate_actions WHERE id=$1 FOR UPDATE`, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrCorporateActionNotFound
+		}
+		return nil, err
+	}
+	if a.Status != ActionStatusPending {
+		return nil, ErrCorporateActionApplied
+	}
+
+	now := time.Now()
+	cutoff := recordDateCutoff(a.RecordDate)
+	exStart := calendar.Default.OnDate(a.ExDate)
+	if !dryRun && (now.Before(cutoff) || now.Before(exStart)) {
+		return nil, ErrCorporateActionNotDue
+	}
+
+	holdings, order, err := holdersAsOf(ctx, tx, a.StockSymbol, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	_, priceFactor := holdingMultiplier(a)
+	result := &models.CorporateActionResult{Action: a, DryRun: dryRun, PriceFactor: priceFactor}
+	for _, userID := range order {
+		result.Holders = append(result.Holders, impactOf(a, userID, holdings[userID].shares))
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	symbols := []string{a.StockSymbol}
+	if a.NewSymbol != "" {
+		symbols = append(symbols, a.NewSymbol)
+	}
+	if err := lockStockPrices(ctx, tx, symbols...); err != nil {
+		return nil, err
+	}
+	if err := lockActionUsers(ctx, tx, a.StockSymbol); err != nil {
+		return nil, err
+	}
+
+	batch := &pgx.Batch{}
+	switch a.ActionType {
+	case ActionSplit, ActionBonus:
+		for _, h := range result.Holders {
+			if h.Adjustment == 0 {
+				continue
+			}
+			queueStockEntry(batch, h.UserID, a.StockSymbol, h.Adjustment, "DEBIT", 0, a.ID, exStart)
+		}
+
+		batch.Queue(`UPDATE stock_price_history SET price = price * $2 WHERE stock_symbol = $1 AND recorded_at < $3`, a.StockSymbol, priceFactor, exStart)
+		batch.Queue(`UPDATE stock_closes SET close_price = close_price * $2 WHERE stock_symbol = $1 AND trade_date < $3`, a.StockSymbol, priceFactor, a.ExDate)
+		batch.Queue(`UPDATE stocks SET price = round((price * $2)::numeric, 2), updated_at = now() WHERE stock_symbol = $1`, a.StockSymbol, priceFactor)
+		batch.Queue(`UPDATE rewards SET adjustment_factor = adjustment_factor / $2 WHERE stock_symbol = $1 AND timestamp < $3`, a.StockSymbol, priceFactor, exStart)
+		// Held rewards are not in the ledger yet, so their share counts are
+		// adjusted directly.
+		batch.Queue(`UPDATE pending_rewards SET shares = shares / $2, grant_price = grant_price * $2 WHERE stock_symbol = $1 AND status = $3 AND requested_at < $4`, a.StockSymbol, priceFactor, PendingRewardPending, exStart)
+		// Open sell orders and transfers were sized against the holdings
+		// before the adjustment entries, so they are rescaled with them.
+		// A transfer keeps the value it was sent at.
+		batch.Queue(`UPDATE sell_orders SET quantity = quantity / $2 WHERE stock_symbol = $1 AND status = $3`, a.StockSymbol, priceFactor, OrderStatusPending)
+		batch.Queue(`UPDATE share_transfers SET quantity = quantity / $2 WHERE stock_symbol = $1 AND status = $3`, a.StockSymbol, priceFactor, TransferPendingAcceptance)
+
+	case ActionSymbolChange, ActionMerger:
+		for _, h := range result.Holders {
+			cost := holdings[h.UserID].cost
+			queueStockEntry(batch, h.UserID, a.StockSymbol, h.Holding, "CREDIT", cost, a.ID, exStart)
+			if h.NewHolding > 0 {
+				// The cost basis moves to the new shares; the part that
+				// belongs to fractions paid out in cash is dropped with them.
+				carried := cost
+				if entitled := h.Holding * a.RatioTo / a.RatioFrom; a.ActionType == ActionMerger && entitled > 0 {
+					carried = cost * h.NewHolding / entitled
+				}
+				queueStockEntry(batch, h.UserID, a.NewSymbol, h.NewHolding, "DEBIT", carried, a.ID, exStart)
+			}
+			if h.CashINR > 0 {
+				batch.Queue(`
+					INSERT INTO ledger_entries
+					(user_id, entry_type, stock_symbol, amount_inr, direction, reference_id, reference_type, created_at)
+					VALUES ($1,'CASH',$2,$3,'DEBIT',$4,'CORPORATE_ACTION',$5)
+				`, h.UserID, a.StockSymbol, h.CashINR, a.ID, exStart)
+			}
+		}
+
+		if a.ActionType == ActionSymbolChange {
+			batch.Queue(`
+				INSERT INTO stocks (stock_symbol, price, name, sector, industry, market_cap_bucket, exchange)
+				SELECT $2, price, name, sector, industry, market_cap_bucket, exchange FROM stocks WHERE stock_symbol = $1
+				ON CONFLICT (stock_symbol) DO NOTHING
+			`, a.StockSymbol, a.NewSymbol)
+		}
+		// Held rewards follow the holdings to the new symbol, so they are
+		// released in a tradable stock.
+		ratio := 1.0
+		if a.ActionType == ActionMerger {
+			ratio = a.RatioTo / a.RatioFrom
+		}
+		batch.Queue(`UPDATE pending_rewards SET stock_symbol = $2, shares = shares * $3, grant_price = grant_price / $3 WHERE stock_symbol = $1 AND status = $4`, a.StockSymbol, a.NewSymbol, ratio, PendingRewardPending)
+
+		// A renamed holding is the same shares, so open orders and
+		// transfers follow it. After a merger the old shares are gone and
+		// only whole acquirer shares remain, so they are closed instead.
+		if a.ActionType == ActionSymbolChange {
+			batch.Queue(`UPDATE sell_orders SET stock_symbol = $2 WHERE stock_symbol = $1 AND status = $3`, a.StockSymbol, a.NewSymbol, OrderStatusPending)
+			batch.Queue(`UPDATE share_transfers SET stock_symbol = $2 WHERE stock_symbol = $1 AND status = $3`, a.StockSymbol, a.NewSymbol, TransferPendingAcceptance)
+		} else {
+			queueCloseOpenCommitments(batch, a.StockSymbol)
+		}
+
+		status := StockStatusRenamed
+		if a.ActionType == ActionMerger {
+			status = StockStatusMerged
+		}
+		batch.Queue(`UPDATE stocks SET status = $2, successor_symbol = $3, updated_at = now() WHERE stock_symbol = $1`, a.StockSymbol, status, a.NewSymbol)
+
+	case ActionDelisting:
+		batch.Queue(`UPDATE stocks SET status = $2, price = $3, updated_at = now() WHERE stock_symbol = $1`, a.StockSymbol, StockStatusDelisted, a.SettlementPrice)
+		queueCloseOpenCommitments(batch, a.StockSymbol)
+		batch.Queue(`
+			INSERT INTO stock_closes (stock_symbol, trade_date, close_price)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (stock_symbol, trade_date) DO UPDATE SET close_price = EXCLUDED.close_price
+		`, a.StockSymbol, a.ExDate, a.SettlementPrice)
+	}
+
+	batch.Queue(`UPDATE corporate_actions SET status = $2, applied_at = now() WHERE id = $1`, a.ID, ActionStatusApplied)
+
+	if err := execBatch(ctx, tx, batch); err != nil {
+		return nil, err
+	}
+
+	result.Action.Status = ActionStatusApplied
+	result.Action.AppliedAt = &now
+	if err := recordAudit(ctx, tx, models.AuditCorporateActionApply, "corporate_action", a.ID.String(), a, map[string]interface{}{
+		"status": ActionStatusApplied, "price_factor": priceFactor, "holders": len(result.Holders),
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// queueCloseOpenCommitments rejects the pending sell orders and cancels the
+// transfers awaiting acceptance of a symbol that is no longer tradable.
+func queueCloseOpenCommitments(batch *pgx.Batch, symbol string) {
+	batch.Queue(`UPDATE sell_orders SET status = $2, reject_reason = $3 WHERE stock_symbol = $1 AND status = $4`, symbol, OrderStatusRejected, ErrStockNotTradable.Error(), OrderStatusPending)
+	batch.Queue(`UPDATE share_transfers SET status = $2, completed_at = now() WHERE stock_symbol = $1 AND status = $3`, symbol, TransferCancelled, TransferPendingAcceptance)
+}
+
+func queueStockEntry(batch *pgx.Batch, userID int64, symbol string, quantity float64, direction string, amount float64, actionID uuid.UUID, at time.Time) {
+	batch.Queue(`
+		INSERT INTO ledger_entries
+		(user_id, entry_type, stock_symbol, quantity, direction, reference_id, reference_type, amount_inr, created_at)
+		VALUES ($1,'STOCK',$2,$3,$4,$5,'CORPORATE_ACTION',$6,$7)
+	`, userID, symbol, quantity, direction, actionID, amount, at)
+}
+
+// GetStockStatus returns the lifecycle status of symbol and the symbol it was
+// renamed or merged into, if any.
+func GetStockStatus(ctx context.Context, symbol string) (status string, successor string, err error) {
+	err = db.Pool.QueryRow(ctx, "SELECT status, COALESCE(successor_symbol, '') FROM stocks WHERE stock_symbol=$1", symbol).Scan(&status, &successor)
+	return status, successor, err
+}
+
+// GetSymbolLineage returns every symbol linked to symbol through renames and
+// mergers, in either direction, together with the actions that link them.
+func GetSymbolLineage(ctx context.Context, symbol string) (*models.SymbolLineage, error) {
+	rows, err := db.Pool.Query(ctx, `
+		WITH RECURSIVE lineage(stock_symbol) AS (
+			SELECT $1::text
+			UNION
+			SELECT CASE WHEN s.stock_symbol = l.stock_symbol THEN s.successor_symbol ELSE s.stock_symbol END
+			FROM stocks s
+			JOIN lineage l ON s.stock_symbol = l.stock_symbol OR s.successor_symbol = l.stock_symbol
+			WHERE s.successor_symbol IS NOT NULL
+		)
+		SELECT s.stock_symbol, s.status, COALESCE(s.successor_symbol, ''), s.price
+		FROM stocks s
+		JOIN lineage l ON l.stock_symbol = s.stock_symbol
+		ORDER BY s.stock_symbol
+	`, symbol)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lineage := &models.SymbolLineage{Symbol: symbol}
+	var symbols []string
+	for rows.Next() {
+		var st models.StockStatus
+		if err := rows.Scan(&st.StockSymbol, &st.Status, &st.SuccessorSymbol, &st.Price); err != nil {
+			return nil, err
+		}
+		lineage.Symbols = append(lineage.Symbols, st)
+		symbols = append(symbols, st.StockSymbol)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(symbols) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	actions, err := db.Pool.Query(ctx, `
+		SELECT `+corporateActionColumns+`
+		FROM corporate_actions
+		WHERE stock_symbol = ANY($1) OR new_symbol = ANY($1)
+		ORDER BY ex_date, created_at
+	`, symbols)
+	if err != nil {
+		return nil, err
+	}
+	defer actions.Close()
+
+	for actions.Next() {
+		a, err := scanCorporateAction(actions)
+		if err != nil {
+			return nil, err
+		}
+		lineage.Actions = append(lineage.Actions, a)
+	}
+	return lineage, actions.Err()
+}
+
+// GetUserLedger returns a user's ledger entries, optionally limited to the
+// given symbols.
+func GetUserLedger(ctx context.Context, userID int64, symbols []string) ([]models.LedgerEntry, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, user_id, entry_type, stock_symbol, quantity, amount_inr, direction, reference_id, COALESCE(reference_type, ''), created_at
+		FROM ledger_entries
+		WHERE user_id = $1 AND (COALESCE(cardinality($2::text[]), 0) = 0 OR stock_symbol = ANY($2))
+		ORDER BY created_at, id
+	`, userID, symbols)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.LedgerEntry
+	for rows.Next() {
+		var e models.LedgerEntry
+		if err := rows.Scan(&e.ID, &e.UserID, &e.EntryType, &e.StockSymbol, &e.Quantity, &e.AmountINR, &e.Direction, &e.ReferenceID, &e.ReferenceType, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
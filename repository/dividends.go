@@ -0,0 +1,316 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"stock-reward-api/calendar"
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+	"stock-reward-api/tax"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	DividendStatusDeclared = "DECLARED"
+	DividendStatusEntitled = "ENTITLED"
+	DividendStatusPaid     = "PAID"
+)
+
+var (
+	ErrDividendNotFound = errors.New("dividend not found")
+	ErrDividendState    = errors.New("dividend is not in the expected state")
+	ErrDividendNotDue   = errors.New("dividend is not due yet")
+)
+
+// TDSPolicy describes tax withheld on dividends. Tax is deducted once a
+// user's dividends from one company within a financial year exceed
+// Threshold.
+type TDSPolicy struct {
+	Rate      float64
+	Threshold float64
+}
+
+const dividendColumns = `id, stock_symbol, amount_per_share, record_date, pay_date, status, COALESCE(notes, ''), created_by, created_at, entitled_at, paid_at`
+
+func scanDividend(row pgx.Row) (models.Dividend, error) {
+	var d models.Dividend
+	err := row.Scan(&d.ID, &d.StockSymbol, &d.AmountPerShare, &d.RecordDate, &d.PayDate, &d.Status, &d.Notes, &d.CreatedBy, &d.CreatedAt, &d.EntitledAt, &d.PaidAt)
+	return d, err
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func CreateDividend(ctx context.Context, d models.Dividend) (models.Dividend, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return d, err
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, `
+		INSERT INTO dividends (stock_symbol, amount_per_share, record_date, pay_date, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+dividendColumns,
+		d.StockSymbol, d.AmountPerShare, d.RecordDate, d.PayDate, d.Notes, d.CreatedBy)
+	created, err := scanDividend(row)
+	if err != nil {
+		return created, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditDividendDeclare, "dividend", created.ID.String(), nil, created); err != nil {
+		return created, err
+	}
+	return created, tx.Commit(ctx)
+}
+
+func GetDividends(ctx context.Context, stockSymbol string) ([]models.Dividend, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+dividendColumns+`
+		FROM dividends
+		WHERE $1 = '' OR stock_symbol = $1
+		ORDER BY record_date DESC, created_at DESC
+	`, stockSymbol)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Dividend
+	for rows.Next() {
+		d, err := scanDividend(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// GetDueDividends returns declared dividends whose record date has ended and
+// entitled dividends whose pay date has been reached.
+func GetDueDividends(ctx context.Context, now time.Time) ([]models.Dividend, error) {
+	today := calendar.Default.Date(now)
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+dividendColumns+`
+		FROM dividends
+		WHERE (status = $1 AND record_date < $3)
+			OR (status = $2 AND pay_date <= $3)
+		ORDER BY record_date, created_at
+	`, DividendStatusDeclared, DividendStatusEntitled, today)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Dividend
+	for rows.Next() {
+		d, err := scanDividend(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func lockDividend(ctx context.Context, tx pgx.Tx, id uuid.UUID, status string) (models.Dividend, error) {
+	d, err := scanDividend(tx.QueryRow(ctx, `SELECT `+dividendColumns+` FROM dividends WHERE id=$1 FOR UPDATE`, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return d, ErrDividendNotFound
+		}
+		return d, err
+	}
+	if d.Status != status {
+		return d, ErrDividendState
+	}
+	return d, nil
+}
+
+// ComputeDividendEntitlements records what every holder as of the record
+// date is owed, withholding TDS according to policy. It returns the number of
+// entitlements created.
+func ComputeDividendEntitlements(ctx context.Context, id uuid.UUID, policy TDSPolicy) (int, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	d, err := lockDividend(ctx, tx, id, DividendStatusDeclared)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := recordDateCutoff(d.RecordDate)
+	if time.Now().Before(cutoff) {
+		return 0, ErrDividendNotDue
+	}
+
+	holdings, order, err := holdersAsOf(ctx, tx, d.StockSymbol, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	fy := tax.FinancialYearOf(d.PayDate)
+	rows, err := tx.Query(ctx, `
+		SELECT e.user_id, SUM(e.gross_inr)
+		FROM dividend_entitlements e
+		JOIN dividends d ON d.id = e.dividend_id
+		WHERE d.stock_symbol = $1 AND d.pay_date >= $2 AND d.pay_date < $3
+		GROUP BY e.user_id
+	`, d.StockSymbol, fy.Start, fy.End)
+	if err != nil {
+		return 0, err
+	}
+	priorGross := make(map[int64]float64)
+	for rows.Next() {
+		var userID int64
+		var gross float64
+		if err := rows.Scan(&userID, &gross); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		priorGross[userID] = gross
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var totalGross, totalTDS float64
+	batch := &pgx.Batch{}
+	for _, userID := range order {
+		shares := holdings[userID].shares
+		gross := round2(shares * d.AmountPerShare)
+		tds := 0.0
+		if priorGross[userID]+gross > policy.Threshold {
+			tds = round2(gross * policy.Rate)
+		}
+		totalGross += gross
+		totalTDS += tds
+		batch.Queue(`
+			INSERT INTO dividend_entitlements (dividend_id, user_id, shares, gross_inr, tds_inr, net_inr)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, d.ID, userID, shares, gross, tds, gross-tds)
+	}
+	batch.Queue(`UPDATE dividends SET status = $2, entitled_at = now() WHERE id = $1`, d.ID, DividendStatusEntitled)
+
+	if err := execBatch(ctx, tx, batch); err != nil {
+		return 0, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditDividendEntitle, "dividend", d.ID.String(), d, map[string]interface{}{
+		"status": DividendStatusEntitled, "holders": len(order), "gross_inr": round2(totalGross),
+		"tds_inr": round2(totalTDS), "tds_rate": policy.Rate, "tds_threshold": policy.Threshold,
+	}); err != nil {
+		return 0, err
+	}
+	return len(order), tx.Commit(ctx)
+}
+
+// PayDividend posts a CASH DEBIT of the net amount for every entitlement of
+// the dividend and marks it paid. It returns the number of users paid.
+func PayDividend(ctx context.Context, id uuid.UUID) (int, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	d, err := lockDividend(ctx, tx, id, DividendStatusEntitled)
+	if err != nil {
+		return 0, err
+	}
+	if time.Now().Before(calendar.Default.OnDate(d.PayDate)) {
+		return 0, ErrDividendNotDue
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO ledger_entries
+		(user_id, entry_type, stock_symbol, amount_inr, direction, reference_id, reference_type, created_at)
+		SELECT user_id, 'CASH', $2, net_inr, 'DEBIT', id, 'DIVIDEND', now()
+		FROM dividend_entitlements
+		WHERE dividend_id = $1 AND status = $3 AND net_inr > 0
+	`, d.ID, d.StockSymbol, DividendStatusEntitled)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		UPDATE dividend_entitlements SET status = $2, paid_at = now() WHERE dividend_id = $1 AND status = $3
+		RETURNING id, user_id, shares, gross_inr, tds_inr, net_inr, paid_at
+	`, d.ID, DividendStatusPaid, DividendStatusEntitled)
+	if err != nil {
+		return 0, err
+	}
+	var paid []models.DividendEntitlement
+	for rows.Next() {
+		e := models.DividendEntitlement{DividendID: d.ID, StockSymbol: d.StockSymbol, AmountPerShare: d.AmountPerShare,
+			RecordDate: d.RecordDate, PayDate: d.PayDate, Status: DividendStatusPaid}
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Shares, &e.GrossINR, &e.TDSINR, &e.NetINR, &e.PaidAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		paid = append(paid, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	// One entry per payout, each carrying the gross amount, TDS withheld
+	// and net amount credited.
+	for _, e := range paid {
+		if err := recordAudit(ctx, tx, models.AuditDividendPay, "dividend_entitlement", e.ID.String(), nil, e); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE dividends SET status = $2, paid_at = now() WHERE id = $1`, d.ID, DividendStatusPaid); err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), tx.Commit(ctx)
+}
+
+func GetUserDividends(ctx context.Context, userID int64) ([]models.DividendEntitlement, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT e.id, e.dividend_id, e.user_id, d.stock_symbol, d.amount_per_share, d.record_date, d.pay_date,
+			e.shares, e.gross_inr, e.tds_inr, e.net_inr, e.status, e.paid_at
+		FROM dividend_entitlements e
+		JOIN dividends d ON d.id = e.dividend_id
+		WHERE e.user_id = $1
+		ORDER BY d.pay_date DESC, d.stock_symbol
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.DividendEntitlement
+	for rows.Next() {
+		var e models.DividendEntitlement
+		if err := rows.Scan(&e.ID, &e.DividendID, &e.UserID, &e.StockSymbol, &e.AmountPerShare, &e.RecordDate, &e.PayDate,
+			&e.Shares, &e.GrossINR, &e.TDSINR, &e.NetINR, &e.Status, &e.PaidAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func execBatch(ctx context.Context, tx pgx.Tx, batch *pgx.Batch) error {
+	br := tx.SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := br.Exec(); err != nil {
+			br.Close()
+			return err
+		}
+	}
+	return br.Close()
+}
@@ -0,0 +1,395 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"stock-reward-api/db"
+	"stock-reward-api/logger"
+	"stock-reward-api/models"
+	"stock-reward-api/pricecache"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	KYCNotSubmitted  = "NOT_SUBMITTED"
+	KYCPendingReview = "PENDING_REVIEW"
+	KYCVerified      = "VERIFIED"
+	KYCRejected      = "REJECTED"
+
+	PendingRewardPending  = "PENDING"
+	PendingRewardReleased = "RELEASED"
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrKYCNotVerified     = errors.New("kyc is not verified")
+	ErrKYCAlreadyVerified = errors.New("kyc is already verified")
+	ErrKYCNotPending      = errors.New("kyc is not pending review")
+)
+
+// requireKYCVerified returns ErrKYCNotVerified unless the user's KYC has
+// been approved.
+func requireKYCVerified(ctx context.Context, q queryRower, userID int64) error {
+	var status string
+	if err := q.QueryRow(ctx, "SELECT kyc_status FROM users WHERE id = $1", userID).Scan(&status); err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if status != KYCVerified {
+		return ErrKYCNotVerified
+	}
+	return nil
+}
+
+// lockKYCStatus locks the user's row and returns their KYC status.
+func lockKYCStatus(ctx context.Context, tx pgx.Tx, userID int64) (string, error) {
+	var status string
+	if err := tx.QueryRow(ctx, "SELECT kyc_status FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&status); err != nil {
+		if err == pgx.ErrNoRows {
+			return "", ErrUserNotFound
+		}
+		return "", err
+	}
+	return status, nil
+}
+
+// SubmitKYC records the user's PAN and document references and queues the
+// profile for review. Rejected profiles may be resubmitted; documents from
+// earlier submissions are kept.
+func SubmitKYC(ctx context.Context, userID int64, pan string, documents []models.KYCDocument) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	status, err := lockKYCStatus(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	if status == KYCVerified {
+		return ErrKYCAlreadyVerified
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users
+		SET pan = $2, kyc_status = $3, kyc_submitted_at = now(), kyc_rejection_reason = NULL
+		WHERE id = $1
+	`, userID, pan, KYCPendingReview)
+	if err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	for _, d := range documents {
+		batch.Queue(`INSERT INTO kyc_documents (user_id, document_type, reference) VALUES ($1, $2, $3)`, userID, d.DocumentType, d.Reference)
+	}
+	if err := execBatch(ctx, tx, batch); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ApproveKYC marks the user's KYC verified and credits every reward held
+// while it was not, as granted. Approval and release happen in one
+// transaction. Rewards without a grant price whose symbol has no current
+// price are left held and returned in stillHeld; ReleaseHeldRewards credits
+// them once it has one.
+func ApproveKYC(ctx context.Context, userID, reviewerID int64) (released, stillHeld []models.PendingReward, err error) {
+	prices, err := heldRewardPrices(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	status, err := lockKYCStatus(ctx, tx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if status != KYCPendingReview {
+		return nil, nil, ErrKYCNotPending
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users
+		SET kyc_status = $2, kyc_reviewed_at = now(), kyc_reviewed_by = $3, kyc_verified_at = now()
+		WHERE id = $1
+	`, userID, KYCVerified, reviewerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditKYCApprove, "user", strconv.FormatInt(userID, 10),
+		map[string]string{"kyc_status": status}, map[string]string{"kyc_status": KYCVerified}); err != nil {
+		return nil, nil, err
+	}
+
+	released, stillHeld, err = releaseHeldRewards(ctx, tx, userID, prices)
+	if err != nil {
+		return nil, nil, err
+	}
+	return released, stillHeld, tx.Commit(ctx)
+}
+
+// ReleaseHeldRewards credits rewards still held for a verified user, e.g.
+// ones left held at approval because they had no price then.
+func ReleaseHeldRewards(ctx context.Context, userID int64) (released, stillHeld []models.PendingReward, err error) {
+	prices, err := heldRewardPrices(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	status, err := lockKYCStatus(ctx, tx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if status != KYCVerified {
+		return nil, nil, ErrKYCNotVerified
+	}
+
+	released, stillHeld, err = releaseHeldRewards(ctx, tx, userID, prices)
+	if err != nil {
+		return nil, nil, err
+	}
+	return released, stillHeld, tx.Commit(ctx)
+}
+
+// heldRewardPrices looks up the current price of every symbol the user has
+// rewards held in without a grant price, which were held before grant
+// prices were recorded and granted before any price history. It runs before
+// the release transaction so no row locks are held while prices load;
+// symbols without a price are left out.
+func heldRewardPrices(ctx context.Context, userID int64) (map[string]float64, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT DISTINCT stock_symbol FROM pending_rewards WHERE user_id = $1 AND status = $2 AND grant_price IS NULL
+	`, userID, PendingRewardPending)
+	if err != nil {
+		return nil, err
+	}
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		symbols = append(symbols, symbol)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	prices, _, err := pricecache.Default.GetMany(ctx, symbols)
+	return prices, err
+}
+
+// releaseHeldRewards credits the user's held rewards at the time they were
+// granted and at their grant price, so their cost basis and holding period
+// are those of the grant. Rewards without a grant price are credited at
+// prices instead; those whose symbol is not in prices, including ones a
+// corporate action moved to a new symbol since prices were loaded, stay
+// held and are returned in stillHeld.
+func releaseHeldRewards(ctx context.Context, tx pgx.Tx, userID int64, prices map[string]float64) (released, stillHeld []models.PendingReward, err error) {
+	pending, err := queryPendingRewards(ctx, tx, `WHERE user_id = $1 AND status = $2 ORDER BY requested_at FOR UPDATE`, userID, PendingRewardPending)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	released, stillHeld = []models.PendingReward{}, []models.PendingReward{}
+	for _, p := range pending {
+		price := p.GrantPrice
+		if price <= 0 {
+			var ok bool
+			if price, ok = prices[p.StockSymbol]; !ok {
+				logger.Log.Warnf("Held reward %s of %s for user %d stays held: no price", p.RewardID, p.StockSymbol, userID)
+				stillHeld = append(stillHeld, p)
+				continue
+			}
+		}
+		rewardUUID, err := insertReward(ctx, tx, userID, p.StockSymbol, p.Shares, p.RewardID, p.RequestedAt, price, p.Fee)
+		if err != nil {
+			return nil, nil, err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE pending_rewards SET status = $2, released_reward_id = $3, released_at = $4 WHERE id = $1
+		`, p.ID, PendingRewardReleased, rewardUUID, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		before := p
+		p.Status, p.ReleasedRewardID, p.ReleasedAt = PendingRewardReleased, &rewardUUID, &now
+		if err := recordAudit(ctx, tx, models.AuditRewardRelease, "reward", p.RewardID, before, p); err != nil {
+			return nil, nil, err
+		}
+		released = append(released, p)
+		logger.Log.Infof("Released held reward %s of %.6f %s to user %d", p.RewardID, p.Shares, p.StockSymbol, userID)
+	}
+	return released, stillHeld, nil
+}
+
+// RejectKYC returns the user's profile to them with a reason. Held rewards
+// stay held until a resubmission is approved.
+func RejectKYC(ctx context.Context, userID, reviewerID int64, reason string) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	status, err := lockKYCStatus(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	if status != KYCPendingReview {
+		return ErrKYCNotPending
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users
+		SET kyc_status = $2, kyc_reviewed_at = now(), kyc_reviewed_by = $3, kyc_rejection_reason = $4
+		WHERE id = $1
+	`, userID, KYCRejected, reviewerID, reason)
+	if err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, models.AuditKYCReject, "user", strconv.FormatInt(userID, 10),
+		map[string]string{"kyc_status": status}, map[string]string{"kyc_status": KYCRejected, "reason": reason}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+const kycProfileColumns = `id, name, email, COALESCE(pan, ''), kyc_status, kyc_submitted_at, kyc_reviewed_at, kyc_reviewed_by,
+	kyc_verified_at, COALESCE(kyc_rejection_reason, '')`
+
+func scanKYCProfile(row pgx.Row) (models.KYCProfile, error) {
+	var p models.KYCProfile
+	err := row.Scan(&p.UserID, &p.Name, &p.Email, &p.PAN, &p.Status, &p.SubmittedAt, &p.ReviewedAt, &p.ReviewedBy,
+		&p.VerifiedAt, &p.RejectionReason)
+	return p, err
+}
+
+// GetKYCProfile returns the user's KYC profile with their documents and
+// held rewards.
+func GetKYCProfile(ctx context.Context, userID int64) (models.KYCProfile, error) {
+	p, err := scanKYCProfile(db.Pool.QueryRow(ctx, `SELECT `+kycProfileColumns+` FROM users WHERE id = $1`, userID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return p, ErrUserNotFound
+		}
+		return p, err
+	}
+
+	docs, err := getKYCDocuments(ctx, []int64{userID})
+	if err != nil {
+		return p, err
+	}
+	p.Documents = docs[userID]
+
+	p.PendingRewards, err = queryPendingRewards(ctx, db.Pool, `WHERE user_id = $1 ORDER BY requested_at DESC`, userID)
+	return p, err
+}
+
+// ListKYCProfiles returns profiles in the given status with their
+// documents, oldest submission first, for review.
+func ListKYCProfiles(ctx context.Context, status string) ([]models.KYCProfile, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+kycProfileColumns+`
+		FROM users
+		WHERE kyc_status = $1
+		ORDER BY kyc_submitted_at NULLS LAST, id
+	`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.KYCProfile
+	var ids []int64
+	for rows.Next() {
+		p, err := scanKYCProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+		ids = append(ids, p.UserID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	docs, err := getKYCDocuments(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].Documents = docs[out[i].UserID]
+	}
+	return out, nil
+}
+
+func getKYCDocuments(ctx context.Context, userIDs []int64) (map[int64][]models.KYCDocument, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT user_id, id, document_type, reference, created_at
+		FROM kyc_documents
+		WHERE user_id = ANY($1)
+		ORDER BY created_at
+	`, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[int64][]models.KYCDocument)
+	for rows.Next() {
+		var userID int64
+		var d models.KYCDocument
+		if err := rows.Scan(&userID, &d.ID, &d.DocumentType, &d.Reference, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		out[userID] = append(out[userID], d)
+	}
+	return out, rows.Err()
+}
+
+type rowsQuerier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+func queryPendingRewards(ctx context.Context, q rowsQuerier, where string, args ...interface{}) ([]models.PendingReward, error) {
+	rows, err := q.Query(ctx, `
+		SELECT id, user_id, stock_symbol, shares, reward_id, requested_at, COALESCE(grant_price, 0), fee, status, released_reward_id, released_at
+		FROM pending_rewards `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.PendingReward
+	for rows.Next() {
+		var p models.PendingReward
+		if err := rows.Scan(&p.ID, &p.UserID, &p.StockSymbol, &p.Shares, &p.RewardID, &p.RequestedAt, &p.GrantPrice, &p.Fee, &p.Status, &p.ReleasedRewardID, &p.ReleasedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
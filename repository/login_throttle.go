@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-reward-api/db"
+	"stock-reward-api/logger"
+	"stock-reward-api/models"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var ErrLoginThrottled = errors.New("too many failed login attempts; try again later")
+
+// LoginThrottle bounds failed logins per account and per client IP. Each
+// failure delays the next attempt by BaseDelay, doubling with every further
+// failure up to MaxDelay. Reaching MaxFailures for an account, or
+// IPMaxFailures for an IP, locks it out for LockoutDuration. Failures more
+// than Window apart start the count again.
+type LoginThrottle struct {
+	MaxFailures     int
+	IPMaxFailures   int
+	Window          time.Duration
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutDuration time.Duration
+}
+
+// DefaultLoginThrottle applies to every login.
+var DefaultLoginThrottle = LoginThrottle{
+	MaxFailures:     5,
+	IPMaxFailures:   20,
+	Window:          15 * time.Minute,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	LockoutDuration: 15 * time.Minute,
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// backoff is the delay imposed after the nth consecutive failure.
+func (t LoginThrottle) backoff(n int) time.Duration {
+	d := t.BaseDelay
+	for i := 1; i < n && d < t.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > t.MaxDelay {
+		d = t.MaxDelay
+	}
+	return d
+}
+
+// LoginRetryAfter returns how long a login for email from ip has to wait
+// because of earlier failures, or zero if it may go ahead.
+func LoginRetryAfter(ctx context.Context, email, ip string) (time.Duration, error) {
+	var until *time.Time
+	err := db.Pool.QueryRow(ctx, `
+		SELECT MAX(GREATEST(next_attempt_at, locked_until)) FROM login_failures WHERE key = ANY($1)
+	`, []string{accountThrottleKey(email), ipThrottleKey(ip)}).Scan(&until)
+	if err != nil {
+		return 0, err
+	}
+	if until == nil {
+		return 0, nil
+	}
+	if wait := time.Until(*until); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+// RecordLoginFailure counts a failed login for email from ip against both,
+// and records a security event for each one it locks out. userID is nil
+// when email is not registered.
+func RecordLoginFailure(ctx context.Context, email, ip string, userID *int64, t LoginThrottle) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	targetType, targetID := "email", email
+	if userID != nil {
+		targetType, targetID = "user", strconv.FormatInt(*userID, 10)
+	}
+	if err := recordAudit(ctx, tx, models.AuditLoginFailed, targetType, targetID, nil, map[string]string{"email": email}); err != nil {
+		return err
+	}
+
+	locked, err := recordThrottleFailure(ctx, tx, accountThrottleKey(email), t.MaxFailures, t)
+	if err != nil {
+		return err
+	}
+	if locked {
+		logger.Log.Warnf("Account %s locked for %s after %d failed logins", email, t.LockoutDuration, t.MaxFailures)
+		err := recordSecurityEvent(ctx, tx, models.SecurityEvent{
+			Type:     models.SecurityEventAccountLocked,
+			UserID:   userID,
+			Email:    email,
+			ClientIP: ip,
+			Detail:   fmt.Sprintf("%d failed logins; locked for %s", t.MaxFailures, t.LockoutDuration),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	locked, err = recordThrottleFailure(ctx, tx, ipThrottleKey(ip), t.IPMaxFailures, t)
+	if err != nil {
+		return err
+	}
+	if locked {
+		logger.Log.Warnf("IP %s locked for %s after %d failed logins", ip, t.LockoutDuration, t.IPMaxFailures)
+		err := recordSecurityEvent(ctx, tx, models.SecurityEvent{
+			Type:     models.SecurityEventIPLocked,
+			Email:    email,
+			ClientIP: ip,
+			Detail:   fmt.Sprintf("%d failed logins; locked for %s", t.IPMaxFailures, t.LockoutDuration),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// recordThrottleFailure counts a failure against key and reports whether
+// it reached max and locked the key out. The count starts again after a
+// lockout.
+func recordThrottleFailure(ctx context.Context, tx pgx.Tx, key string, max int, t LoginThrottle) (locked bool, err error) {
+	if _, err := tx.Exec(ctx, `INSERT INTO login_failures (key) VALUES ($1) ON CONFLICT (key) DO NOTHING`, key); err != nil {
+		return false, err
+	}
+
+	var failures int
+	var last *time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT failures, last_failure_at FROM login_failures WHERE key = $1 FOR UPDATE
+	`, key).Scan(&failures, &last)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	if last == nil || now.Sub(*last) > t.Window {
+		failures = 0
+	}
+	failures++
+
+	var lockedUntil *time.Time
+	if max > 0 && failures >= max {
+		until := now.Add(t.LockoutDuration)
+		lockedUntil = &until
+		failures = 0
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE login_failures
+		SET failures = $2, last_failure_at = $3, next_attempt_at = $4, locked_until = COALESCE($5, locked_until)
+		WHERE key = $1
+	`, key, failures, now, now.Add(t.backoff(failures)), lockedUntil)
+	return lockedUntil != nil, err
+}
+
+// ClearLoginFailures forgets failed logins for email after a successful
+// one. Failures from the client IP still count.
+func ClearLoginFailures(ctx context.Context, email string) error {
+	_, err := db.Pool.Exec(ctx, `DELETE FROM login_failures WHERE key = $1`, accountThrottleKey(email))
+	return err
+}
+
+// UnlockAccount lifts a lockout or backoff on the user's account and
+// records who lifted it.
+func UnlockAccount(ctx context.Context, userID, adminID int64) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var email string
+	if err := tx.QueryRow(ctx, `SELECT email FROM users WHERE id = $1`, userID).Scan(&email); err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	var before struct {
+		Failures      int        `json:"failures"`
+		NextAttemptAt *time.Time `json:"next_attempt_at"`
+		LockedUntil   *time.Time `json:"locked_until"`
+	}
+	err = tx.QueryRow(ctx, `
+		DELETE FROM login_failures WHERE key = $1 RETURNING failures, next_attempt_at, locked_until
+	`, accountThrottleKey(email)).Scan(&before.Failures, &before.NextAttemptAt, &before.LockedUntil)
+	if err != nil && err != pgx.ErrNoRows {
+		return err
+	}
+	err = recordSecurityEvent(ctx, tx, models.SecurityEvent{
+		Type:   models.SecurityEventAccountUnlocked,
+		UserID: &userID,
+		Email:  email,
+		Detail: fmt.Sprintf("unlocked by admin %d", adminID),
+	})
+	if err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, models.AuditAccountUnlock, "user", strconv.FormatInt(userID, 10), before, nil); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// DeleteStaleLoginFailures removes failure counts that no longer delay or
+// lock out anything.
+func DeleteStaleLoginFailures(ctx context.Context, t LoginThrottle) (int64, error) {
+	tag, err := db.Pool.Exec(ctx, `
+		DELETE FROM login_failures
+		WHERE (last_failure_at IS NULL OR last_failure_at < $1)
+			AND (next_attempt_at IS NULL OR next_attempt_at < now())
+			AND (locked_until IS NULL OR locked_until < now())
+	`, time.Now().Add(-t.Window))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
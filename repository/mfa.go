@@ -0,0 +1,369 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+	"stock-reward-api/totp"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrMFANotEnrolled      = errors.New("start enrollment before confirming it")
+	ErrMFARequiredForRole  = errors.New("two-factor authentication is required for your role")
+	ErrInvalidMFACode      = errors.New("invalid authentication code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired MFA token; log in again")
+)
+
+// MaxMFAAttempts is how many wrong codes a login challenge accepts before
+// it is discarded.
+const MaxMFAAttempts = 5
+
+// mfaSkew is how many 30 second steps either side of now a code may be
+// from, to allow for clock drift on the user's device.
+const mfaSkew = 1
+
+// MFAStatus describes a user's two-factor setup.
+type MFAStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	Required               bool       `json:"required"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// normalizeRecoveryCode lets recovery codes be typed with or without
+// dashes and in any case.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// GetMFAStatus returns the user's two-factor setup.
+func GetMFAStatus(ctx context.Context, userID int64) (MFAStatus, error) {
+	var s MFAStatus
+	err := db.Pool.QueryRow(ctx, `
+		SELECT u.totp_enabled_at,
+			EXISTS (SELECT 1 FROM mfa_required_roles r WHERE r.role = u.role),
+			(SELECT COUNT(*) FROM mfa_recovery_codes c WHERE c.user_id = u.id AND c.used_at IS NULL)
+		FROM users u WHERE u.id = $1
+	`, userID).Scan(&s.EnabledAt, &s.Required, &s.RecoveryCodesRemaining)
+	if err == pgx.ErrNoRows {
+		return s, ErrUserNotFound
+	}
+	s.Enabled = s.EnabledAt != nil
+	return s, err
+}
+
+// BeginMFAEnrollment stores secret as the user's pending TOTP secret,
+// replacing any earlier unconfirmed one.
+func BeginMFAEnrollment(ctx context.Context, userID int64, secret string) error {
+	tag, err := db.Pool.Exec(ctx, `
+		UPDATE users SET totp_secret = $2, totp_last_step = NULL
+		WHERE id = $1 AND totp_enabled_at IS NULL
+	`, userID, secret)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+// ConfirmMFAEnrollment enables two-factor authentication once the user
+// proves their authenticator works with code, stores recoveryCodes and logs
+// the user out of every existing session, which were not protected by it.
+func ConfirmMFAEnrollment(ctx context.Context, userID int64, code string, recoveryCodes []string) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var secret *string
+	var enabledAt *time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT totp_secret, totp_enabled_at FROM users WHERE id = $1 FOR UPDATE
+	`, userID).Scan(&secret, &enabledAt)
+	if err != nil {
+		return err
+	}
+	if enabledAt != nil {
+		return ErrMFAAlreadyEnabled
+	}
+	if secret == nil {
+		return ErrMFANotEnrolled
+	}
+	step, ok := totp.Validate(*secret, code, time.Now(), mfaSkew)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET totp_enabled_at = now(), totp_last_step = $2 WHERE id = $1
+	`, userID, step); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodes); err != nil {
+		return err
+	}
+	if err := revokeUserSessions(ctx, tx, userID); err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, models.AuditMFAEnable, "user", strconv.FormatInt(userID, 10),
+		map[string]bool{"mfa_enabled": false}, map[string]bool{"mfa_enabled": true}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// DisableMFA turns two-factor authentication off after checking code. It
+// is refused when the user's role requires it.
+func DisableMFA(ctx context.Context, userID int64, code string) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var required bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM mfa_required_roles r WHERE r.role = u.role)
+		FROM users u WHERE u.id = $1
+	`, userID).Scan(&required)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrMFARequiredForRole
+	}
+	if err := verifyMFACode(ctx, tx, userID, code); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL WHERE id = $1
+	`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, models.AuditMFADisable, "user", strconv.FormatInt(userID, 10),
+		map[string]bool{"mfa_enabled": true}, map[string]bool{"mfa_enabled": false}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes with
+// recoveryCodes after checking code.
+func RegenerateRecoveryCodes(ctx context.Context, userID int64, code string, recoveryCodes []string) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := verifyMFACode(ctx, tx, userID, code); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodes); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID int64, recoveryCodes []string) error {
+	batch := &pgx.Batch{}
+	batch.Queue(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID)
+	for _, code := range recoveryCodes {
+		batch.Queue(`INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hashToken(normalizeRecoveryCode(code)))
+	}
+	return execBatch(ctx, tx, batch)
+}
+
+// verifyMFACode checks code against the user's authenticator, refusing a
+// step that was already used, or else against their unused recovery codes.
+// Whichever matched is consumed. The user row is locked until tx ends.
+func verifyMFACode(ctx context.Context, tx pgx.Tx, userID int64, code string) error {
+	var secret *string
+	var enabledAt *time.Time
+	var lastStep *int64
+	err := tx.QueryRow(ctx, `
+		SELECT totp_secret, totp_enabled_at, totp_last_step FROM users WHERE id = $1 FOR UPDATE
+	`, userID).Scan(&secret, &enabledAt, &lastStep)
+	if err != nil {
+		return err
+	}
+	if enabledAt == nil || secret == nil {
+		return ErrMFANotEnabled
+	}
+
+	if step, ok := totp.Validate(*secret, code, time.Now(), mfaSkew); ok {
+		if lastStep != nil && step <= *lastStep {
+			return ErrInvalidMFACode
+		}
+		_, err := tx.Exec(ctx, `UPDATE users SET totp_last_step = $2 WHERE id = $1`, userID, step)
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE mfa_recovery_codes SET used_at = now()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// CreateMFAChallenge records a login that passed the password check and
+// still needs a second factor. token is handed to the client to complete it.
+func CreateMFAChallenge(ctx context.Context, userID int64, token string, expiresAt time.Time) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO mfa_challenges (user_id, token_hash, expires_at) VALUES ($1, $2, $3)
+	`, userID, hashToken(token), expiresAt)
+	return err
+}
+
+// MFAChallengeEmail returns the email of the user logging in with the
+// challenge token, so the login can be throttled before its code is checked.
+func MFAChallengeEmail(ctx context.Context, token string) (string, error) {
+	var email string
+	err := db.Pool.QueryRow(ctx, `
+		SELECT u.email FROM mfa_challenges c JOIN users u ON u.id = c.user_id WHERE c.token_hash = $1
+	`, hashToken(token)).Scan(&email)
+	if err == pgx.ErrNoRows {
+		return "", ErrInvalidMFAChallenge
+	}
+	return email, err
+}
+
+// CompleteMFAChallenge checks code for the login challenge token and
+// returns the user logging in and whether their email is verified. Each
+// challenge can be completed once and is discarded after MaxMFAAttempts
+// wrong codes.
+func CompleteMFAChallenge(ctx context.Context, token, code string) (user models.User, emailVerified bool, err error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return user, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var expiresAt time.Time
+	var attempts int
+	var usedAt *time.Time
+	hash := hashToken(token)
+	err = tx.QueryRow(ctx, `
+		SELECT c.user_id, u.email, c.attempts, c.expires_at, c.used_at
+		FROM mfa_challenges c JOIN users u ON u.id = c.user_id
+		WHERE c.token_hash = $1
+		FOR UPDATE OF c
+	`, hash).Scan(&user.ID, &user.Email, &attempts, &expiresAt, &usedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return user, false, ErrInvalidMFAChallenge
+		}
+		return user, false, err
+	}
+	if usedAt != nil || attempts >= MaxMFAAttempts || time.Now().After(expiresAt) {
+		return user, false, ErrInvalidMFAChallenge
+	}
+
+	if err := verifyMFACode(ctx, tx, user.ID, code); err != nil {
+		if !errors.Is(err, ErrInvalidMFACode) {
+			return user, false, err
+		}
+		// The failed attempt is counted outside tx, which is rolled back.
+		if err := tx.Rollback(ctx); err != nil {
+			return user, false, err
+		}
+		if _, err := db.Pool.Exec(ctx, `UPDATE mfa_challenges SET attempts = attempts + 1 WHERE token_hash = $1`, hash); err != nil {
+			return user, false, err
+		}
+		return user, false, ErrInvalidMFACode
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE mfa_challenges SET used_at = now() WHERE token_hash = $1`, hash); err != nil {
+		return user, false, err
+	}
+	err = tx.QueryRow(ctx, `
+		SELECT name, role, email_verified_at IS NOT NULL FROM users WHERE id = $1
+	`, user.ID).Scan(&user.Name, &user.Role, &emailVerified)
+	if err != nil {
+		return user, false, err
+	}
+	return user, emailVerified, tx.Commit(ctx)
+}
+
+// GetMFARequiredRoles returns the roles that must use two-factor
+// authentication.
+func GetMFARequiredRoles(ctx context.Context) ([]string, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT role FROM mfa_required_roles ORDER BY role`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// SetMFARequiredRoles makes two-factor authentication required for exactly
+// roles. Users in those roles without it can only set it up until they do.
+func SetMFARequiredRoles(ctx context.Context, roles []string, setBy int64) error {
+	for _, role := range roles {
+		if !ValidRole(role) {
+			return ErrInvalidRole
+		}
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var before []string
+	if err := tx.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(role ORDER BY role), '{}') FROM mfa_required_roles
+	`).Scan(&before); err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	batch.Queue(`DELETE FROM mfa_required_roles WHERE NOT (role = ANY($1))`, roles)
+	for _, role := range roles {
+		batch.Queue(`
+			INSERT INTO mfa_required_roles (role, set_by) VALUES ($1, $2)
+			ON CONFLICT (role) DO NOTHING
+		`, role, setBy)
+	}
+	if err := execBatch(ctx, tx, batch); err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, models.AuditMFAPolicyChange, "mfa_policy", "required_roles",
+		map[string][]string{"roles": before}, map[string][]string{"roles": roles}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -3,16 +3,23 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
 	"time"
 
+	"stock-reward-api/calendar"
 	"stock-reward-api/db"
 	"stock-reward-api/logger"
 	"stock-reward-api/models"
+	"stock-reward-api/pricecache"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 )
 
-
+// CreateReward credits a reward to the user's ledger. Rewards for users
+// whose KYC is not verified are held in pending_rewards instead, and held is
+// true; they are credited when the KYC is approved.
 func CreateReward(
 	ctx context.Context,
 	userID int64,
@@ -22,69 +29,117 @@ func CreateReward(
 	rewardedAt time.Time,
 	pricePerShare float64,
 	fee float64,
-) error {
+) (held bool, err error) {
 
 	tx, err := db.Pool.Begin(ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
-	defer tx.Rollback(ctx) 
+	defer tx.Rollback(ctx)
 
 	//check if rewqardID already exists
-	var existingID uuid.UUID
-	err = tx.QueryRow(ctx, "SELECT id FROM rewards WHERE reward_id=$1", rewardID).Scan(&existingID)
-	if err == nil {
+	var existing bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM rewards WHERE reward_id=$1)
+			OR EXISTS (SELECT 1 FROM pending_rewards WHERE reward_id=$1)
+	`, rewardID).Scan(&existing)
+	if err != nil {
+		return false, err
+	}
+	if existing {
 		logger.Log.Errorf("duplicate reward_id: %s", rewardID)
-		return errors.New("duplicate reward")
+		return false, errors.New("duplicate reward")
 	}
 
+	// The share lock keeps an approval from committing between reading the
+	// status and holding the reward, which would leave it pending.
+	var kycStatus string
+	var emailVerified bool
+	if err := tx.QueryRow(ctx, "SELECT kyc_status, email_verified_at IS NOT NULL FROM users WHERE id=$1 FOR SHARE", userID).Scan(&kycStatus, &emailVerified); err != nil {
+		return false, err
+	}
+	if RequireVerifiedEmailForRewards && !emailVerified {
+		return false, ErrEmailNotVerified
+	}
+	if kycStatus != KYCVerified {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO pending_rewards (user_id, stock_symbol, shares, reward_id, requested_at, grant_price, fee)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, userID, stockSymbol, shares, rewardID, rewardedAt, pricePerShare, fee)
+		if err != nil {
+			logger.Log.Errorf("failed to hold reward: %v", err)
+			return false, errors.New("duplicate reward or failed to hold reward")
+		}
+		if err := recordAudit(ctx, tx, models.AuditRewardHold, "reward", rewardID, nil, map[string]interface{}{
+			"user_id": userID, "stock_symbol": stockSymbol, "shares": shares, "price_per_share": pricePerShare, "fee": fee, "kyc_status": kycStatus,
+		}); err != nil {
+			return false, err
+		}
+		return true, tx.Commit(ctx)
+	}
+
+	rewardUUID, err := insertReward(ctx, tx, userID, stockSymbol, shares, rewardID, rewardedAt, pricePerShare, fee)
+	if err != nil {
+		return false, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditRewardCreate, "reward", rewardID, nil, map[string]interface{}{
+		"id": rewardUUID, "user_id": userID, "stock_symbol": stockSymbol, "shares": shares,
+		"price_per_share": pricePerShare, "fee": fee, "rewarded_at": rewardedAt,
+	}); err != nil {
+		return false, err
+	}
+	return false, tx.Commit(ctx)
+}
+
+// insertReward records a reward and its STOCK, CASH and FEE ledger entries.
+func insertReward(ctx context.Context, tx pgx.Tx, userID int64, stockSymbol string, shares float64, rewardID string, rewardedAt time.Time, pricePerShare, fee float64) (uuid.UUID, error) {
 	var rewardUUID uuid.UUID
-	err = tx.QueryRow(ctx, `
+	err := tx.QueryRow(ctx, `
 		INSERT INTO rewards 
-		(user_id, stock_symbol, shares, reward_id, timestamp)
-		VALUES ($1, $2, $3, $4, $5)
+		(user_id, stock_symbol, shares, reward_id, timestamp, trade_date, grant_price)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id
-	`, userID, stockSymbol, shares, rewardID, rewardedAt).Scan(&rewardUUID)
+	`, userID, stockSymbol, shares, rewardID, rewardedAt, calendar.Default.TradeDate(rewardedAt), pricePerShare).Scan(&rewardUUID)
 
 	if err != nil {
 		logger.Log.Errorf("failed to insert reward_event: %v", err)
-		return errors.New("duplicate reward or failed to insert reward_event")
+		return rewardUUID, errors.New("duplicate reward or failed to insert reward_event")
 	}
 
 	totalStockCost := shares * pricePerShare
 
 	_, err = tx.Exec(ctx, `
 		INSERT INTO ledger_entries
-		(user_id, entry_type, stock_symbol, quantity, direction, reference_id, amount_inr,created_at)
-		VALUES ($1,'STOCK',$2,$3,'DEBIT',$4,0,$5)
-	`, userID, stockSymbol, shares, rewardUUID, rewardedAt)
+		(user_id, entry_type, stock_symbol, quantity, direction, reference_id, reference_type, amount_inr,created_at)
+		VALUES ($1,'STOCK',$2,$3,'DEBIT',$4,'REWARD',$5,$6)
+	`, userID, stockSymbol, shares, rewardUUID, totalStockCost, rewardedAt)
 	if err != nil {
-		return err
+		return rewardUUID, err
 	}
 
 	_, err = tx.Exec(ctx, `
 		INSERT INTO ledger_entries
-		(user_id, entry_type, amount_inr, direction, reference_id,created_at)
-		VALUES ($1,'CASH',$2,'CREDIT',$3,$4)
+		(user_id, entry_type, amount_inr, direction, reference_id, reference_type,created_at)
+		VALUES ($1,'CASH',$2,'CREDIT',$3,'REWARD',$4)
 	`, userID, totalStockCost, rewardUUID, rewardedAt)
 	if err != nil {
-		return err
+		return rewardUUID, err
 	}
 
 	_, err = tx.Exec(ctx, `
 		INSERT INTO ledger_entries
-		(user_id, entry_type, amount_inr, direction, reference_id,created_at)
-		VALUES ($1,'FEE',$2,'CREDIT',$3,$4)
+		(user_id, entry_type, amount_inr, direction, reference_id, reference_type,created_at)
+		VALUES ($1,'FEE',$2,'CREDIT',$3,'REWARD',$4)
 	`, userID, fee, rewardUUID, rewardedAt)
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit(ctx)
+	return rewardUUID, err
 }
 
-func GetTodayStocks(ctx context.Context, userID int64) ([]models.RewardEvent, error) {
-	rows, err := db.Pool.Query(ctx, "SELECT * FROM rewards WHERE user_id=$1 AND DATE(timestamp) = CURRENT_DATE ", userID)
+func GetTodayStocks(ctx context.Context, userID int64, tradeDate time.Time) ([]models.RewardEvent, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, user_id, stock_symbol, shares, timestamp, reward_id, created_at, trade_date, COALESCE(grant_price, 0)
+		FROM rewards
+		WHERE user_id=$1 AND trade_date = $2
+	`, userID, tradeDate)
 	if err != nil {
 		return nil, err
 	}
@@ -93,7 +148,7 @@ func GetTodayStocks(ctx context.Context, userID int64) ([]models.RewardEvent, er
 	var out []models.RewardEvent
 	for rows.Next() {
 		var r models.RewardEvent
-		if err := rows.Scan(&r.ID, &r.UserID, &r.StockSymbol, &r.Shares, &r.RewardedAt, &r.ReferenceID, &r.CreatedAt); err != nil {
+		if err := rows.Scan(&r.ID, &r.UserID, &r.StockSymbol, &r.Shares, &r.RewardedAt, &r.ReferenceID, &r.CreatedAt, &r.TradeDate, &r.GrantPrice); err != nil {
 			return nil, err
 		}
 		out = append(out, r)
@@ -101,120 +156,375 @@ func GetTodayStocks(ctx context.Context, userID int64) ([]models.RewardEvent, er
 	return out, nil
 }
 
+// BackfillTradeDates assigns a trade date to rewards recorded before trade
+// dates were tracked.
+func BackfillTradeDates(ctx context.Context) error {
+	rows, err := db.Pool.Query(ctx, "SELECT id, timestamp FROM rewards WHERE trade_date IS NULL")
+	if err != nil {
+		return err
+	}
+
+	pending := make(map[uuid.UUID]time.Time)
+	for rows.Next() {
+		var id uuid.UUID
+		var ts time.Time
+		if err := rows.Scan(&id, &ts); err != nil {
+			rows.Close()
+			return err
+		}
+		pending[id] = calendar.Default.TradeDate(ts)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, day := range pending {
+		if _, err := db.Pool.Exec(ctx, "UPDATE rewards SET trade_date=$2 WHERE id=$1", id, day); err != nil {
+			return err
+		}
+	}
+	if len(pending) > 0 {
+		logger.Log.Infof("Backfilled trade dates for %d rewards", len(pending))
+	}
+	return nil
+}
+
+// GetStockPrice returns the stored price of stockSymbol, or
+// pricecache.ErrNoPrice for an unknown symbol.
 func GetStockPrice(ctx context.Context, stockSymbol string) (float64, error) {
 	var price float64
 	err := db.Pool.QueryRow(ctx, "SELECT price FROM stocks WHERE stock_symbol=$1", stockSymbol).Scan(&price)
+	if err == pgx.ErrNoRows {
+		return 0, pricecache.ErrNoPrice
+	}
 	return price, err
 }
 
-func GetHistoricalINR(ctx context.Context, userID int64) (map[time.Time]float64, error) {
-	query := `
-		SELECT
-			DATE(l.created_at) AS reward_date,
-			SUM(l.quantity * sp.price) AS total_value_inr
-		FROM ledger_entries l
-		JOIN stocks sp
-		ON l.stock_symbol = sp.stock_symbol
-		WHERE l.user_id = $1
-			AND l.entry_type = 'STOCK'
-			AND l.direction = 'DEBIT'
-		GROUP BY reward_date
-		ORDER BY reward_date;
-	`
-
-	rows, err := db.Pool.Query(ctx, query, userID)
-	// return an arr which ha s date and total value in inr
+// SaveStockPrices writes prices to the stocks table and appends them to the
+// price history in a single transaction, holding the same per-symbol locks
+// as ApplyCorporateAction.
+func SaveStockPrices(ctx context.Context, prices map[string]float64) error {
+	tx, err := db.Pool.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	defer tx.Rollback(ctx)
 
-	result := make(map[time.Time]float64)
-	for rows.Next() {
-		var rewardDate time.Time
-		var totalValueInr float64
-		if err := rows.Scan(&rewardDate, &totalValueInr); err != nil {
+	symbols := make([]string, 0, len(prices))
+	for symbol := range prices {
+		symbols = append(symbols, symbol)
+	}
+	if err := lockStockPrices(ctx, tx, symbols...); err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	for _, symbol := range symbols {
+		batch.Queue("UPDATE stocks SET price=$2, updated_at=now() WHERE stock_symbol=$1", symbol, prices[symbol])
+		batch.Queue("INSERT INTO stock_price_history (stock_symbol, price) VALUES ($1, $2)", symbol, prices[symbol])
+	}
+	if err := execBatch(ctx, tx, batch); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// lockStockPrices serialises price writes to symbols for the rest of the
+// transaction. Symbols are locked in sorted order so that two writers cannot
+// deadlock.
+func lockStockPrices(ctx context.Context, tx pgx.Tx, symbols ...string) error {
+	sorted := append([]string(nil), symbols...)
+	sort.Strings(sorted)
+	for _, symbol := range sorted {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('stock_price'), hashtext($1))`, symbol); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetValuationPrices returns the price to value symbols with at time at: the
+// cached live price while the market is open, otherwise the last recorded
+// close. Symbols without a recorded close fall back to their live price, and
+// symbols without any price are left out.
+func GetValuationPrices(ctx context.Context, at time.Time, symbols []string) (map[string]float64, error) {
+	prices := make(map[string]float64, len(symbols))
+	if len(symbols) == 0 {
+		return prices, nil
+	}
+
+	if day, ok := calendar.Default.LastCompletedSession(at); ok && !calendar.Default.IsOpen(at) {
+		rows, err := db.Pool.Query(ctx, `
+			SELECT DISTINCT ON (stock_symbol) stock_symbol, close_price
+			FROM stock_closes
+			WHERE trade_date <= $1 AND stock_symbol = ANY($2)
+			ORDER BY stock_symbol, trade_date DESC
+		`, day, symbols)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var symbol string
+			var price float64
+			if err := rows.Scan(&symbol, &price); err != nil {
+				return nil, err
+			}
+			prices[symbol] = price
+		}
+		if err := rows.Err(); err != nil {
 			return nil, err
 		}
-		result[rewardDate] = totalValueInr
 	}
 
+	var missing []string
+	for _, symbol := range symbols {
+		if _, ok := prices[symbol]; !ok {
+			missing = append(missing, symbol)
+		}
+	}
+
+	live, _, err := pricecache.Default.GetMany(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for symbol, price := range live {
+		prices[symbol] = price
+	}
+
+	return prices, nil
+}
+
+// GetHistoricalINR returns the user's end-of-day holdings value for every
+// snapshotted trading day.
+func GetHistoricalINR(ctx context.Context, userID int64) (map[time.Time]float64, error) {
+	snapshots, err := GetPortfolioSnapshots(ctx, userID, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[time.Time]float64, len(snapshots))
+	for _, s := range snapshots {
+		result[s.Date] = s.NAVINR
+	}
 	return result, nil
 }
 
-func GetUserStats(ctx context.Context, userID int64) (map[string]float64, error) {
+// Stats groupings accepted by GetUserStats.
+const (
+	StatsBySymbol = "symbol"
+	StatsByDay    = "day"
+	StatsByWeek   = "week"
+)
+
+var ErrInvalidStatsGrouping = errors.New("group_by must be one of symbol, day, week")
+
+// GetUserStats aggregates the rewards attributed to trading days from
+// through to (inclusive) into buckets by symbol, trade date or ISO week.
+// Shares are adjusted for later splits and bonus issues and valued at the
+// current valuation price; grant value uses the price each reward was
+// granted at.
+func GetUserStats(ctx context.Context, userID int64, from, to time.Time, groupBy string) (models.UserStats, error) {
+	stats := models.UserStats{
+		UserID:  userID,
+		From:    from.Format("2006-01-02"),
+		To:      to.Format("2006-01-02"),
+		GroupBy: groupBy,
+		Buckets: []models.StatsBucket{},
+	}
+	switch groupBy {
+	case StatsBySymbol, StatsByDay, StatsByWeek:
+	default:
+		return stats, ErrInvalidStatsGrouping
+	}
+
 	query := `
 		SELECT
-			l.stock_symbol,
-			SUM(l.quantity * sp.price) AS total_value_inr
-		FROM ledger_entries l
-		JOIN stocks sp
-		ON l.stock_symbol = sp.stock_symbol
-		WHERE l.user_id = $1
-			AND l.entry_type = 'STOCK'
-			AND l.direction = 'DEBIT'
-			AND DATE(l.created_at) = CURRENT_DATE
-		GROUP BY l.stock_symbol
-		ORDER BY l.stock_symbol;
+			r.trade_date,
+			r.stock_symbol,
+			COUNT(*) AS reward_count,
+			SUM(r.shares * r.adjustment_factor) AS total_shares,
+			SUM(r.shares * COALESCE(r.grant_price, 0)) AS grant_value
+		FROM rewards r
+		WHERE r.user_id = $1
+			AND r.trade_date >= $2
+			AND r.trade_date <= $3
+		GROUP BY r.trade_date, r.stock_symbol
+		ORDER BY r.trade_date, r.stock_symbol;
 	`
 
-	rows, err := db.Pool.Query(ctx, query, userID)
+	rows, err := db.Pool.Query(ctx, query, userID, from, to)
 	if err != nil {
-		return nil, err
+		return stats, err
 	}
 	defer rows.Close()
 
-	result := make(map[string]float64)
+	type dayRow struct {
+		tradeDate  time.Time
+		symbol     string
+		count      int
+		shares     float64
+		grantValue float64
+	}
+	var days []dayRow
+	var symbols []string
+	seen := make(map[string]bool)
 	for rows.Next() {
-		var symbol string
-		var totalValueInr float64
-		if err := rows.Scan(&symbol, &totalValueInr); err != nil {
-			return nil, err
+		var d dayRow
+		if err := rows.Scan(&d.tradeDate, &d.symbol, &d.count, &d.shares, &d.grantValue); err != nil {
+			return stats, err
+		}
+		days = append(days, d)
+		if !seen[d.symbol] {
+			seen[d.symbol] = true
+			symbols = append(symbols, d.symbol)
 		}
-		result[symbol] = totalValueInr
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
 	}
 
-	return result, nil
+	prices, err := GetValuationPrices(ctx, time.Now(), symbols)
+	if err != nil {
+		return stats, err
+	}
+
+	index := make(map[string]int)
+	for _, d := range days {
+		var key string
+		switch groupBy {
+		case StatsBySymbol:
+			key = d.symbol
+		case StatsByDay:
+			key = d.tradeDate.Format("2006-01-02")
+		case StatsByWeek:
+			year, week := d.tradeDate.ISOWeek()
+			key = fmt.Sprintf("%d-W%02d", year, week)
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(stats.Buckets)
+			index[key] = i
+			stats.Buckets = append(stats.Buckets, models.StatsBucket{Key: key})
+		}
+		value := d.shares * prices[d.symbol]
+		for _, b := range []*models.StatsBucket{&stats.Buckets[i], &stats.Totals} {
+			b.RewardCount += d.count
+			b.Shares += d.shares
+			b.ValueINR += value
+			b.GrantValueINR += d.grantValue
+		}
+	}
+	if groupBy == StatsBySymbol {
+		sort.Slice(stats.Buckets, func(i, j int) bool { return stats.Buckets[i].Key < stats.Buckets[j].Key })
+	}
+	stats.Totals.Key = "total"
+
+	return stats, nil
 }
 
-func GetPortfolio(ctx context.Context, userID int64) (map[string]map[string]float64, error) {
-	query := `
+// signedQuantity is the change a STOCK ledger entry makes to a holding:
+// DEBIT entries add shares and CREDIT entries remove them.
+const signedQuantity = `CASE WHEN l.direction = 'DEBIT' THEN l.quantity ELSE -l.quantity END`
+
+// averageCost is the cost basis of the current holding under the average
+// cost method: the cost of every share acquired, scaled down to the shares
+// still held.
+const averageCost = `COALESCE(
+	SUM(l.amount_inr) FILTER (WHERE l.direction = 'DEBIT')
+	/ NULLIF(SUM(l.quantity) FILTER (WHERE l.direction = 'DEBIT'), 0)
+	* SUM(` + signedQuantity + `), 0)`
+
+// GetPortfolio returns the user's current holdings keyed by symbol, each
+// with its average-cost basis and unrealized gain, plus portfolio totals.
+func GetPortfolio(ctx context.Context, userID int64) (map[string]models.Holding, models.PortfolioTotals, error) {
+	var totals models.PortfolioTotals
+
+	holdings, err := getHoldings(ctx, userID)
+	if err != nil {
+		return nil, totals, err
+	}
+
+	result := make(map[string]models.Holding)
+	for _, h := range holdings {
+		result[h.StockSymbol] = h
+
+		totals.CostBasisINR += h.CostBasisINR
+		totals.TotalValueINR += h.TotalValueINR
+	}
+	totals.UnrealizedGainINR = totals.TotalValueINR - totals.CostBasisINR
+	totals.UnrealizedGainPct = gainPct(totals.UnrealizedGainINR, totals.CostBasisINR)
+
+	return result, totals, nil
+}
+
+// getHoldings returns the user's current holdings valued at the latest
+// prices, ordered by symbol.
+func getHoldings(ctx context.Context, userID int64) ([]models.Holding, error) {
+	return queryHoldings(ctx, `
 		SELECT
 			l.stock_symbol,
-			SUM(l.quantity) AS total_shares,
-			MAX(sp.price) ,
-			SUM(l.quantity * sp.price) AS total_value_inr
+			SUM(`+signedQuantity+`) AS total_shares,
+			`+averageCost+` AS cost_basis
 		FROM ledger_entries l
-		JOIN stocks sp
-		ON l.stock_symbol = sp.stock_symbol
 		WHERE l.user_id = $1
 			AND l.entry_type = 'STOCK'
-			AND l.direction = 'DEBIT'
 		GROUP BY l.stock_symbol
+		HAVING SUM(`+signedQuantity+`) > 0
 		ORDER BY l.stock_symbol;
-	`
+	`, userID)
+}
 
-	rows, err := db.Pool.Query(ctx, query, userID)
+// queryHoldings runs a query returning (symbol, shares, cost basis) rows and
+// values each at the latest price. Symbols without a price are dropped.
+func queryHoldings(ctx context.Context, query string, args ...interface{}) ([]models.Holding, error) {
+	rows, err := db.Pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	// return map of stock symbol as follows "symbol": {"shares": quantity, "stock_price": stock_price, "total_value_inr": totalValueInr}
-	result := make(map[string]map[string]float64)
+
+	var holdings []models.Holding
+	var symbols []string
 	for rows.Next() {
-		var symbol string
-		var totalShares float64
-		var stockPrice float64
-		var totalValueInr float64
-		if err := rows.Scan(&symbol, &totalShares, &stockPrice, &totalValueInr); err != nil {
+		var h models.Holding
+		if err := rows.Scan(&h.StockSymbol, &h.Shares, &h.CostBasisINR); err != nil {
 			return nil, err
 		}
-		result[symbol] = map[string]float64{
-			"shares": totalShares,
-			"stock_price": stockPrice,
-			"total_value_inr": totalValueInr,
-		}	
+		holdings = append(holdings, h)
+		symbols = append(symbols, h.StockSymbol)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return result, nil
-}
\ No newline at end of file
+	prices, err := GetValuationPrices(ctx, time.Now(), symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	valued := holdings[:0]
+	for _, h := range holdings {
+		stockPrice, ok := prices[h.StockSymbol]
+		if !ok {
+			continue
+		}
+		h.StockPrice = stockPrice
+		h.TotalValueINR = h.Shares * stockPrice
+		h.AverageCostINR = h.CostBasisINR / h.Shares
+		h.UnrealizedGainINR = h.TotalValueINR - h.CostBasisINR
+		h.UnrealizedGainPct = gainPct(h.UnrealizedGainINR, h.CostBasisINR)
+		valued = append(valued, h)
+	}
+	return valued, nil
+}
+
+func gainPct(gain, cost float64) float64 {
+	if cost == 0 {
+		return 0
+	}
+	return gain / cost * 100
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func recordSecurityEvent(ctx context.Context, tx pgx.Tx, e models.SecurityEvent) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO security_events (event_type, user_id, email, client_ip, detail)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+	`, e.Type, e.UserID, e.Email, e.ClientIP, e.Detail)
+	return err
+}
+
+// ListSecurityEvents returns the most recent security events, newest first,
+// optionally only those of eventType.
+func ListSecurityEvents(ctx context.Context, eventType string, limit int) ([]models.SecurityEvent, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, event_type, user_id, COALESCE(email, ''), COALESCE(client_ip, ''), detail, created_at
+		FROM security_events
+		WHERE $1 = '' OR event_type = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`, eventType, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.SecurityEvent{}
+	for rows.Next() {
+		var e models.SecurityEvent
+		if err := rows.Scan(&e.ID, &e.Type, &e.UserID, &e.Email, &e.ClientIP, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,363 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"stock-reward-api/broker"
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	OrderStatusPending   = "PENDING"
+	OrderStatusExecuted  = "EXECUTED"
+	OrderStatusRejected  = "REJECTED"
+	OrderStatusCancelled = "CANCELLED"
+)
+
+var (
+	ErrSellOrderNotFound  = errors.New("sell order not found")
+	ErrSellOrderState     = errors.New("sell order is not pending")
+	ErrInsufficientShares = errors.New("insufficient shares available")
+	ErrStockNotTradable   = errors.New("stock is not active")
+)
+
+const sellOrderColumns = `id, user_id, stock_symbol, quantity, COALESCE(client_order_id, ''), status, price, gross_inr,
+	brokerage_inr, stt_inr, exchange_inr, gst_inr, fees_inr, net_inr, COALESCE(broker_ref, ''), COALESCE(reject_reason, ''),
+	created_at, executed_at`
+
+func scanSellOrder(row pgx.Row) (models.SellOrder, error) {
+	var o models.SellOrder
+	err := row.Scan(&o.ID, &o.UserID, &o.StockSymbol, &o.Quantity, &o.ClientOrderID, &o.Status, &o.Price, &o.GrossINR,
+		&o.BrokerageINR, &o.STTINR, &o.ExchangeINR, &o.GSTINR, &o.FeesINR, &o.NetINR, &o.BrokerRef, &o.RejectReason,
+		&o.CreatedAt, &o.ExecutedAt)
+	return o, err
+}
+
+// lockUserHoldings serialises changes to a user's holdings for the rest of
+// the transaction, so that checks against available shares cannot race.
+func lockUserHoldings(ctx context.Context, tx pgx.Tx, userID int64) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('holdings'), $1::int)`, userID)
+	return err
+}
+
+// availableShares is the user's ledger holding of symbol less shares already
+// committed to pending sell orders and transfers, other than exclude.
+func availableShares(ctx context.Context, tx pgx.Tx, userID int64, symbol string, exclude uuid.UUID) (float64, error) {
+	var held, committed float64
+	err := tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(`+signedQuantity+`), 0)
+		FROM ledger_entries l
+		WHERE l.user_id = $1 AND l.stock_symbol = $2 AND l.entry_type = 'STOCK'
+	`, userID, symbol).Scan(&held)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(quantity), 0) FROM (
+			SELECT quantity FROM sell_orders
+			WHERE user_id = $1 AND stock_symbol = $2 AND status = $3 AND id <> $5
+			UNION ALL
+			SELECT quantity FROM share_transfers
+			WHERE sender_id = $1 AND stock_symbol = $2 AND status = $4 AND id <> $5
+		) pending
+	`, userID, symbol, OrderStatusPending, TransferPendingAcceptance, exclude).Scan(&committed)
+	if err != nil {
+		return 0, err
+	}
+	return held - committed, nil
+}
+
+// CreateSellOrder records a pending order to sell quantity shares of symbol
+// after checking them against the user's ledger holdings. A repeated
+// clientOrderID returns the existing order with existing=true.
+func CreateSellOrder(ctx context.Context, userID int64, symbol string, quantity float64, clientOrderID string) (order models.SellOrder, existing bool, err error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return order, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUserHoldings(ctx, tx, userID); err != nil {
+		return order, false, err
+	}
+	if err := requireKYCVerified(ctx, tx, userID); err != nil {
+		return order, false, err
+	}
+
+	if clientOrderID != "" {
+		order, err = scanSellOrder(tx.QueryRow(ctx, `SELECT `+sellOrderColumns+` FROM sell_orders WHERE user_id = $1 AND client_order_id = $2`, userID, clientOrderID))
+		if err == nil {
+			return order, true, nil
+		}
+		if err != pgx.ErrNoRows {
+			return order, false, err
+		}
+	}
+
+	var status string
+	if err := tx.QueryRow(ctx, "SELECT status FROM stocks WHERE stock_symbol = $1", symbol).Scan(&status); err != nil {
+		if err == pgx.ErrNoRows {
+			return order, false, ErrStockNotTradable
+		}
+		return order, false, err
+	}
+	if status != StockStatusActive {
+		return order, false, ErrStockNotTradable
+	}
+
+	available, err := availableShares(ctx, tx, userID, symbol, uuid.Nil)
+	if err != nil {
+		return order, false, err
+	}
+	if quantity > available+1e-9 {
+		return order, false, ErrInsufficientShares
+	}
+
+	order, err = scanSellOrder(tx.QueryRow(ctx, `
+		INSERT INTO sell_orders (user_id, stock_symbol, quantity, client_order_id)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING `+sellOrderColumns,
+		userID, symbol, quantity, clientOrderID))
+	if err != nil {
+		return order, false, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditSellOrderCreate, "sell_order", order.ID.String(), nil, order); err != nil {
+		return order, false, err
+	}
+	return order, false, tx.Commit(ctx)
+}
+
+// ExecuteSellOrder sends a pending order to the execution adapter and books
+// the fill: a STOCK CREDIT of the shares sold carrying the proceeds net of
+// fees, a CASH DEBIT of the gross proceeds and a CASH CREDIT of the fees.
+// Orders that no longer pass validation are rejected. When the market is
+// closed the order is left pending and broker.ErrMarketClosed is returned.
+func ExecuteSellOrder(ctx context.Context, id uuid.UUID, adapter broker.ExecutionAdapter, schedule broker.FeeSchedule) (models.SellOrder, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return models.SellOrder{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	order, err := scanSellOrder(tx.QueryRow(ctx, `SELECT `+sellOrderColumns+` FROM sell_orders WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return order, ErrSellOrderNotFound
+		}
+		return order, err
+	}
+	if order.Status != OrderStatusPending {
+		return order, ErrSellOrderState
+	}
+
+	if err := lockUserHoldings(ctx, tx, order.UserID); err != nil {
+		return order, err
+	}
+
+	before := order
+	reject := func(reason string) (models.SellOrder, error) {
+		order, err := scanSellOrder(tx.QueryRow(ctx, `
+			UPDATE sell_orders SET status = $2, reject_reason = $3 WHERE id = $1
+			RETURNING `+sellOrderColumns, id, OrderStatusRejected, reason))
+		if err != nil {
+			return order, err
+		}
+		if err := recordAudit(ctx, tx, models.AuditSellOrderReject, "sell_order", id.String(), before, order); err != nil {
+			return order, err
+		}
+		return order, tx.Commit(ctx)
+	}
+
+	// KYC was checked when the order was placed; it must still hold now.
+	if err := requireKYCVerified(ctx, tx, order.UserID); err != nil {
+		if errors.Is(err, ErrKYCNotVerified) {
+			return reject(err.Error())
+		}
+		return order, err
+	}
+
+	var status string
+	if err := tx.QueryRow(ctx, "SELECT status FROM stocks WHERE stock_symbol = $1", order.StockSymbol).Scan(&status); err != nil {
+		return order, err
+	}
+	if status != StockStatusActive {
+		return reject(ErrStockNotTradable.Error())
+	}
+	available, err := availableShares(ctx, tx, order.UserID, order.StockSymbol, order.ID)
+	if err != nil {
+		return order, err
+	}
+	if order.Quantity > available+1e-9 {
+		return reject(ErrInsufficientShares.Error())
+	}
+
+	fill, err := adapter.Execute(ctx, broker.Order{
+		ID:       order.ID.String(),
+		Symbol:   order.StockSymbol,
+		Side:     broker.SideSell,
+		Quantity: order.Quantity,
+	})
+	if err != nil {
+		return order, err
+	}
+
+	gross := round2(fill.Price * fill.Quantity)
+	fees := schedule.Compute(gross)
+	net := round2(gross - fees.TotalINR)
+
+	batch := &pgx.Batch{}
+	batch.Queue(`
+		INSERT INTO ledger_entries
+		(user_id, entry_type, stock_symbol, quantity, direction, reference_id, reference_type, amount_inr, created_at)
+		VALUES ($1,'STOCK',$2,$3,'CREDIT',$4,'SELL',$5,$6)
+	`, order.UserID, order.StockSymbol, fill.Quantity, order.ID, net, fill.ExecutedAt)
+	batch.Queue(`
+		INSERT INTO ledger_entries
+		(user_id, entry_type, stock_symbol, amount_inr, direction, reference_id, reference_type, created_at)
+		VALUES ($1,'CASH',$2,$3,'DEBIT',$4,'SELL',$5)
+	`, order.UserID, order.StockSymbol, gross, order.ID, fill.ExecutedAt)
+	if fees.TotalINR > 0 {
+		batch.Queue(`
+			INSERT INTO ledger_entries
+			(user_id, entry_type, stock_symbol, amount_inr, direction, reference_id, reference_type, created_at)
+			VALUES ($1,'CASH',$2,$3,'CREDIT',$4,'SELL',$5)
+		`, order.UserID, order.StockSymbol, fees.TotalINR, order.ID, fill.ExecutedAt)
+	}
+	if err := execBatch(ctx, tx, batch); err != nil {
+		return order, err
+	}
+
+	order, err = scanSellOrder(tx.QueryRow(ctx, `
+		UPDATE sell_orders SET
+			status = $2, quantity = $3, price = $4, gross_inr = $5, brokerage_inr = $6, stt_inr = $7,
+			exchange_inr = $8, gst_inr = $9, fees_inr = $10, net_inr = $11, broker_ref = $12, executed_at = $13
+		WHERE id = $1
+		RETURNING `+sellOrderColumns,
+		id, OrderStatusExecuted, fill.Quantity, fill.Price, gross, fees.BrokerageINR, fees.STTINR,
+		fees.ExchangeINR, fees.GSTINR, fees.TotalINR, net, fill.BrokerRef, fill.ExecutedAt))
+	if err != nil {
+		return order, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditSellOrderExecute, "sell_order", id.String(), before, order); err != nil {
+		return order, err
+	}
+	return order, tx.Commit(ctx)
+}
+
+// CancelSellOrder cancels one of the user's pending orders.
+func CancelSellOrder(ctx context.Context, userID int64, id uuid.UUID) (models.SellOrder, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return models.SellOrder{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	order, err := scanSellOrder(tx.QueryRow(ctx, `
+		UPDATE sell_orders SET status = $3
+		WHERE id = $1 AND user_id = $2 AND status = $4
+		RETURNING `+sellOrderColumns, id, userID, OrderStatusCancelled, OrderStatusPending))
+	if err == nil {
+		before := order
+		before.Status = OrderStatusPending
+		if err := recordAudit(ctx, tx, models.AuditSellOrderCancel, "sell_order", id.String(), before, order); err != nil {
+			return order, err
+		}
+		return order, tx.Commit(ctx)
+	}
+	if err != pgx.ErrNoRows {
+		return order, err
+	}
+
+	var status string
+	if err := tx.QueryRow(ctx, "SELECT status FROM sell_orders WHERE id = $1 AND user_id = $2", id, userID).Scan(&status); err != nil {
+		if err == pgx.ErrNoRows {
+			return order, ErrSellOrderNotFound
+		}
+		return order, err
+	}
+	return order, ErrSellOrderState
+}
+
+// GetPendingSellOrderIDs returns pending orders, oldest first.
+func GetPendingSellOrderIDs(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT id FROM sell_orders WHERE status = $1 ORDER BY created_at`, OrderStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+func GetUserSellOrders(ctx context.Context, userID int64) ([]models.SellOrder, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+sellOrderColumns+`
+		FROM sell_orders
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.SellOrder
+	for rows.Next() {
+		o, err := scanSellOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// GetWallet returns the user's cash balance from CASH ledger entries, broken
+// down by what produced them. Entries booked against rewards record the
+// platform buying the rewarded shares and are not the user's money.
+// CreditsINR is money in, from CASH DEBIT entries, and DebitsINR money out,
+// from CASH CREDIT entries.
+func GetWallet(ctx context.Context, userID int64) (models.Wallet, error) {
+	wallet := models.Wallet{UserID: userID, BySource: map[string]float64{}}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT COALESCE(l.reference_type, ''),
+			COALESCE(SUM(l.amount_inr) FILTER (WHERE l.direction = 'DEBIT'), 0),
+			COALESCE(SUM(l.amount_inr) FILTER (WHERE l.direction = 'CREDIT'), 0)
+		FROM ledger_entries l
+		WHERE l.user_id = $1 AND l.entry_type = 'CASH' AND COALESCE(l.reference_type, '') <> 'REWARD'
+		GROUP BY 1
+	`, userID)
+	if err != nil {
+		return wallet, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var source string
+		var credits, debits float64
+		if err := rows.Scan(&source, &credits, &debits); err != nil {
+			return wallet, err
+		}
+		wallet.CreditsINR += credits
+		wallet.DebitsINR += debits
+		wallet.BySource[source] = round2(credits - debits)
+	}
+	wallet.CreditsINR = round2(wallet.CreditsINR)
+	wallet.DebitsINR = round2(wallet.DebitsINR)
+	wallet.BalanceINR = round2(wallet.CreditsINR - wallet.DebitsINR)
+	return wallet, rows.Err()
+}
@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected; session revoked")
+)
+
+// AccessToken identifies an issued access token by its jti claim.
+type AccessToken struct {
+	JTI       string
+	ExpiresAt time.Time
+}
+
+// hashToken hashes a high-entropy random token for storage.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSession starts a new refresh token family for the user with
+// refreshToken, issued alongside access.
+func CreateSession(ctx context.Context, userID int64, refreshToken string, refreshExpiresAt time.Time, access AccessToken) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	familyID := uuid.New()
+	_, err = tx.Exec(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, access_jti, access_expires_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, userID, familyID, hashToken(refreshToken), access.JTI, access.ExpiresAt, refreshExpiresAt)
+	if err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, models.AuditLogin, "user", strconv.FormatInt(userID, 10), nil,
+		map[string]interface{}{"session_id": familyID, "expires_at": refreshExpiresAt}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RotateRefreshToken exchanges refreshToken for newRefreshToken in the same
+// family and returns the owner's ID and current role. Presenting a token
+// that was already used or revoked means it has leaked, so the whole family
+// is revoked, including access tokens issued from it, and
+// ErrRefreshTokenReused is returned.
+func RotateRefreshToken(ctx context.Context, refreshToken, newRefreshToken string, refreshExpiresAt time.Time, access AccessToken) (userID int64, role string, err error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var id, familyID uuid.UUID
+	var expiresAt time.Time
+	var usedAt, revokedAt *time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT id, user_id, family_id, expires_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, hashToken(refreshToken)).Scan(&id, &userID, &familyID, &expiresAt, &usedAt, &revokedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, "", ErrInvalidRefreshToken
+		}
+		return 0, "", err
+	}
+
+	switch err := checkRefreshToken(expiresAt, usedAt, revokedAt, time.Now()); err {
+	case nil:
+	case ErrRefreshTokenReused:
+		if err := revokeFamily(ctx, tx, familyID); err != nil {
+			return 0, "", err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return 0, "", err
+		}
+		return userID, "", err
+	default:
+		return 0, "", err
+	}
+
+	if err := tx.QueryRow(ctx, "SELECT role FROM users WHERE id = $1", userID).Scan(&role); err != nil {
+		return 0, "", err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE refresh_tokens SET used_at = now() WHERE id = $1`, id); err != nil {
+		return 0, "", err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, access_jti, access_expires_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, userID, familyID, hashToken(newRefreshToken), access.JTI, access.ExpiresAt, refreshExpiresAt)
+	if err != nil {
+		return 0, "", err
+	}
+	return userID, role, tx.Commit(ctx)
+}
+
+// checkRefreshToken reports why a stored refresh token cannot be rotated at
+// now. A token that was already used or revoked is ErrRefreshTokenReused even
+// after it expired, so a leaked token always revokes its family.
+func checkRefreshToken(expiresAt time.Time, usedAt, revokedAt *time.Time, now time.Time) error {
+	if usedAt != nil || revokedAt != nil {
+		return ErrRefreshTokenReused
+	}
+	if now.After(expiresAt) {
+		return ErrInvalidRefreshToken
+	}
+	return nil
+}
+
+// revokeFamily revokes every refresh token in the family and every access
+// token issued from it that has not yet expired.
+func revokeFamily(ctx context.Context, tx pgx.Tx, familyID uuid.UUID) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO revoked_tokens (jti, user_id, expires_at)
+		SELECT access_jti, user_id, access_expires_at
+		FROM refresh_tokens
+		WHERE family_id = $1 AND access_expires_at > now()
+		ON CONFLICT (jti) DO NOTHING
+	`, familyID)
+	return err
+}
+
+// RevokeSession logs out the session access belongs to: its refresh token
+// family and every access token issued from it. Access tokens that are not
+// part of a session are revoked on their own.
+func RevokeSession(ctx context.Context, userID int64, access AccessToken) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var familyID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		SELECT family_id FROM refresh_tokens WHERE access_jti = $1 AND user_id = $2
+	`, access.JTI, userID).Scan(&familyID)
+	switch {
+	case err == nil:
+		if err := revokeFamily(ctx, tx, familyID); err != nil {
+			return err
+		}
+	case err != pgx.ErrNoRows:
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO revoked_tokens (jti, user_id, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING
+	`, access.JTI, userID, access.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	after := map[string]interface{}{"scope": "session"}
+	if familyID != uuid.Nil {
+		after["session_id"] = familyID
+	}
+	if err := recordAudit(ctx, tx, models.AuditLogout, "user", strconv.FormatInt(userID, 10), nil, after); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RevokeUserSessions logs the user out everywhere.
+func RevokeUserSessions(ctx context.Context, userID int64) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := revokeUserSessions(ctx, tx, userID); err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, models.AuditLogout, "user", strconv.FormatInt(userID, 10), nil,
+		map[string]string{"scope": "all"}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func revokeUserSessions(ctx context.Context, tx pgx.Tx, userID int64) error {
+	rows, err := tx.Query(ctx, `
+		SELECT DISTINCT family_id FROM refresh_tokens WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		return err
+	}
+	var families []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		families = append(families, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range families {
+		if err := revokeFamily(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsTokenRevoked reports whether the access token with jti was revoked.
+func IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := db.Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	return revoked, err
+}
+
+// DeleteExpiredTokens removes refresh tokens, revocations and MFA login
+// challenges that can no longer be presented.
+func DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	refresh, err := db.Pool.Exec(ctx, `DELETE FROM refresh_tokens WHERE expires_at < now() AND access_expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	revoked, err := db.Pool.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	challenges, err := db.Pool.Exec(ctx, `DELETE FROM mfa_challenges WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return refresh.RowsAffected() + revoked.RowsAffected() + challenges.RowsAffected(), nil
+}
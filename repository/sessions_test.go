@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckRefreshToken(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Minute)
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		usedAt    *time.Time
+		revokedAt *time.Time
+		want      error
+	}{
+		{"unused and unexpired", later, nil, nil, nil},
+		{"expired", earlier, nil, nil, ErrInvalidRefreshToken},
+		{"expiring this instant", now, nil, nil, nil},
+		{"already used", later, &earlier, nil, ErrRefreshTokenReused},
+		{"revoked", later, nil, &earlier, ErrRefreshTokenReused},
+		{"used and expired", earlier, &earlier, nil, ErrRefreshTokenReused},
+		{"revoked and expired", earlier, nil, &earlier, ErrRefreshTokenReused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkRefreshToken(tt.expiresAt, tt.usedAt, tt.revokedAt, now); got != tt.want {
+				t.Errorf("checkRefreshToken = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"stock-reward-api/calendar"
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// GetLastSnapshotDate returns the most recent date any portfolio snapshot
+// was taken for, or ok=false when none exist yet.
+func GetLastSnapshotDate(ctx context.Context) (time.Time, bool, error) {
+	var last *time.Time
+	if err := db.Pool.QueryRow(ctx, "SELECT MAX(snapshot_date) FROM portfolio_snapshots").Scan(&last); err != nil {
+		return time.Time{}, false, err
+	}
+	if last == nil {
+		return time.Time{}, false, nil
+	}
+	return calendar.Default.OnDate(*last), true, nil
+}
+
+// GetFirstLedgerDate returns when the earliest ledger entry was written.
+func GetFirstLedgerDate(ctx context.Context) (time.Time, bool, error) {
+	var first *time.Time
+	if err := db.Pool.QueryRow(ctx, "SELECT MIN(created_at) FROM ledger_entries").Scan(&first); err != nil {
+		return time.Time{}, false, err
+	}
+	if first == nil {
+		return time.Time{}, false, nil
+	}
+	return *first, true, nil
+}
+
+// GetEODPrices returns each symbol's end-of-day price for day: the latest
+// recorded close on or before it, falling back to the latest price tick
+// before close and finally to the current price.
+func GetEODPrices(ctx context.Context, day, close time.Time) (map[string]float64, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT s.stock_symbol, COALESCE(c.close_price, h.price, s.price)
+		FROM stocks s
+		LEFT JOIN LATERAL (
+			SELECT close_price FROM stock_closes
+			WHERE stock_symbol = s.stock_symbol AND trade_date <= $1
+			ORDER BY trade_date DESC LIMIT 1
+		) c ON true
+		LEFT JOIN LATERAL (
+			SELECT price FROM stock_price_history
+			WHERE stock_symbol = s.stock_symbol AND recorded_at < $2
+			ORDER BY recorded_at DESC LIMIT 1
+		) h ON true
+	`, day, close)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := make(map[string]float64)
+	for rows.Next() {
+		var symbol string
+		var price float64
+		if err := rows.Scan(&symbol, &price); err != nil {
+			return nil, err
+		}
+		prices[symbol] = price
+	}
+	return prices, rows.Err()
+}
+
+// SnapshotPortfolios records every user's holdings value at the close of the
+// trading day day using end-of-day prices. The day's net flow is the value of
+// shares moved in or out of the portfolio by anything other than a corporate
+// action, less cash paid out of holdings (dividends and merger cash). It
+// returns the number of users snapshotted.
+func SnapshotPortfolios(ctx context.Context, day time.Time) (int, error) {
+	_, close, ok := calendar.Default.Session(day)
+	if !ok {
+		return 0, nil
+	}
+	prevClose := time.Time{}
+	if prev, ok := calendar.Default.LastCompletedSession(day.Add(-time.Nanosecond)); ok {
+		_, prevClose, _ = calendar.Default.Session(prev)
+	}
+
+	prices, err := GetEODPrices(ctx, day, close)
+	if err != nil {
+		return 0, err
+	}
+
+	type snapshot struct {
+		value, cost, flow float64
+	}
+	snapshots := make(map[int64]*snapshot)
+	get := func(userID int64) *snapshot {
+		s, ok := snapshots[userID]
+		if !ok {
+			s = &snapshot{}
+			snapshots[userID] = s
+		}
+		return s
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT l.user_id, l.stock_symbol, SUM(`+signedQuantity+`), `+averageCost+`
+		FROM ledger_entries l
+		WHERE l.entry_type = 'STOCK' AND l.created_at < $1
+		GROUP BY l.user_id, l.stock_symbol
+		HAVING SUM(`+signedQuantity+`) > 0
+	`, close)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var userID int64
+		var symbol string
+		var shares, cost float64
+		if err := rows.Scan(&userID, &symbol, &shares, &cost); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		s := get(userID)
+		s.value += shares * prices[symbol]
+		s.cost += cost
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	rows, err = db.Pool.Query(ctx, `
+		SELECT l.user_id, l.entry_type, COALESCE(l.stock_symbol, ''),
+			SUM(CASE WHEN l.entry_type = 'STOCK' THEN `+signedQuantity+` ELSE l.amount_inr END)
+		FROM ledger_entries l
+		WHERE l.created_at >= $1 AND l.created_at < $2
+			AND (
+				(l.entry_type = 'STOCK' AND l.reference_type <> 'CORPORATE_ACTION')
+				OR (l.entry_type = 'CASH' AND l.direction = 'DEBIT' AND l.reference_type IN ('DIVIDEND', 'CORPORATE_ACTION'))
+			)
+		GROUP BY l.user_id, l.entry_type, l.stock_symbol
+	`, prevClose, close)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var userID int64
+		var entryType, symbol string
+		var amount float64
+		if err := rows.Scan(&userID, &entryType, &symbol, &amount); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		s := get(userID)
+		if entryType == "STOCK" {
+			s.flow += amount * prices[symbol]
+		} else {
+			s.flow -= amount
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	batch := &pgx.Batch{}
+	for userID, s := range snapshots {
+		batch.Queue(`
+			INSERT INTO portfolio_snapshots (user_id, snapshot_date, holdings_value_inr, cost_basis_inr, net_flow_inr)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (user_id, snapshot_date) DO UPDATE SET
+				holdings_value_inr = EXCLUDED.holdings_value_inr,
+				cost_basis_inr = EXCLUDED.cost_basis_inr,
+				net_flow_inr = EXCLUDED.net_flow_inr,
+				created_at = now()
+		`, userID, day, s.value, s.cost, s.flow)
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := execBatch(ctx, tx, batch); err != nil {
+		return 0, err
+	}
+	return len(snapshots), tx.Commit(ctx)
+}
+
+// GetPortfolioSnapshots returns a user's daily snapshots between from and to
+// inclusive. A zero from or to leaves that end open.
+func GetPortfolioSnapshots(ctx context.Context, userID int64, from, to time.Time) ([]models.PortfolioSnapshot, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT snapshot_date, holdings_value_inr, cost_basis_inr, net_flow_inr
+		FROM portfolio_snapshots
+		WHERE user_id = $1
+			AND ($2::date IS NULL OR snapshot_date >= $2)
+			AND ($3::date IS NULL OR snapshot_date <= $3)
+		ORDER BY snapshot_date
+	`, userID, nullDate(from), nullDate(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.PortfolioSnapshot
+	for rows.Next() {
+		var s models.PortfolioSnapshot
+		if err := rows.Scan(&s.Date, &s.NAVINR, &s.CostBasisINR, &s.NetFlowINR); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func nullDate(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
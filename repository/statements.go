@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"stock-reward-api/calendar"
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+)
+
+// StatementColumn names a statement column. Width is the number of
+// characters fixed-width renderers reserve for it.
+type StatementColumn struct {
+	Name  string
+	Width int
+}
+
+// StatementWriter renders a statement as it is produced. Rows belong to the
+// most recently started section.
+type StatementWriter interface {
+	Section(title string, columns []StatementColumn) error
+	Row(values []string) error
+}
+
+var holdingColumns = []StatementColumn{
+	{"stock_symbol", 14}, {"shares", 14}, {"average_cost_inr", 18}, {"cost_basis_inr", 18},
+	{"price_inr", 14}, {"value_inr", 18}, {"unrealized_gain_inr", 20},
+}
+
+// WriteStatement writes the user's portfolio statement for the dates from
+// through to (inclusive, in the exchange timezone): opening holdings, every
+// reward and ledger movement in the period, and closing holdings with their
+// valuation. Rewards and movements are streamed row by row.
+func WriteStatement(ctx context.Context, userID int64, from, to time.Time, w StatementWriter) error {
+	start := calendar.Default.OnDate(from)
+	end := calendar.Default.OnDate(to).AddDate(0, 0, 1)
+	const day = "2006-01-02"
+
+	if err := w.Section("Portfolio statement", []StatementColumn{{"field", 16}, {"value", 40}}); err != nil {
+		return err
+	}
+	for _, r := range [][]string{
+		{"user_id", strconv.FormatInt(userID, 10)},
+		{"from", start.Format(day)},
+		{"to", calendar.Default.OnDate(to).Format(day)},
+		{"generated_at", time.Now().In(calendar.Default.Location).Format(time.RFC3339)},
+	} {
+		if err := w.Row(r); err != nil {
+			return err
+		}
+	}
+
+	opening, err := holdingsAt(ctx, userID, start)
+	if err != nil {
+		return err
+	}
+	if err := writeHoldings(w, "Opening holdings as of "+start.Format(day), opening); err != nil {
+		return err
+	}
+
+	if err := w.Section("Rewards", []StatementColumn{
+		{"rewarded_at", 26}, {"reward_id", 38}, {"stock_symbol", 14}, {"shares", 14}, {"grant_price_inr", 16}, {"value_inr", 16},
+	}); err != nil {
+		return err
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id::text, timestamp, stock_symbol, shares, COALESCE(grant_price, 0)
+		FROM rewards
+		WHERE user_id = $1 AND timestamp >= $2 AND timestamp < $3
+		ORDER BY timestamp, id
+	`, userID, start, end)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id, symbol string
+		var at time.Time
+		var shares, price float64
+		if err := rows.Scan(&id, &at, &symbol, &shares, &price); err != nil {
+			rows.Close()
+			return err
+		}
+		if err := w.Row([]string{
+			at.In(calendar.Default.Location).Format(time.RFC3339), id, symbol,
+			formatQuantity(shares), formatINR(price), formatINR(shares * price),
+		}); err != nil {
+			rows.Close()
+			return err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := w.Section("Ledger movements", []StatementColumn{
+		{"created_at", 26}, {"entry_type", 11}, {"stock_symbol", 14}, {"direction", 10}, {"quantity", 14},
+		{"amount_inr", 16}, {"reference_type", 17}, {"reference_id", 38},
+	}); err != nil {
+		return err
+	}
+	rows, err = db.Pool.Query(ctx, `
+		SELECT created_at, entry_type, COALESCE(stock_symbol, ''), direction, quantity, amount_inr,
+			COALESCE(reference_type, ''), COALESCE(reference_id::text, '')
+		FROM ledger_entries
+		WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at, id
+	`, userID, start, end)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var at time.Time
+		var entryType, symbol, direction, refType, refID string
+		var quantity, amount *float64
+		if err := rows.Scan(&at, &entryType, &symbol, &direction, &quantity, &amount, &refType, &refID); err != nil {
+			rows.Close()
+			return err
+		}
+		row := []string{at.In(calendar.Default.Location).Format(time.RFC3339), entryType, symbol, direction, "", "", refType, refID}
+		if quantity != nil {
+			row[4] = formatQuantity(*quantity)
+		}
+		if amount != nil {
+			row[5] = formatINR(*amount)
+		}
+		if err := w.Row(row); err != nil {
+			rows.Close()
+			return err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	closing, err := holdingsAt(ctx, userID, end)
+	if err != nil {
+		return err
+	}
+	if err := writeHoldings(w, "Closing holdings as of "+calendar.Default.OnDate(to).Format(day), closing); err != nil {
+		return err
+	}
+
+	var openingValue, closingValue, closingCost float64
+	for _, h := range opening {
+		openingValue += h.TotalValueINR
+	}
+	for _, h := range closing {
+		closingValue += h.TotalValueINR
+		closingCost += h.CostBasisINR
+	}
+	if err := w.Section("Totals", []StatementColumn{{"field", 24}, {"value_inr", 18}}); err != nil {
+		return err
+	}
+	for _, r := range [][]string{
+		{"opening_value_inr", formatINR(openingValue)},
+		{"closing_value_inr", formatINR(closingValue)},
+		{"closing_cost_basis_inr", formatINR(closingCost)},
+		{"unrealized_gain_inr", formatINR(closingValue - closingCost)},
+	} {
+		if err := w.Row(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// holdingsAt returns the user's holdings from ledger entries before at,
+// valued at the close of the last session completed by then. Holdings at or
+// after the present are valued like the live portfolio.
+func holdingsAt(ctx context.Context, userID int64, at time.Time) ([]models.Holding, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT l.stock_symbol, SUM(`+signedQuantity+`), `+averageCost+`
+		FROM ledger_entries l
+		WHERE l.user_id = $1 AND l.entry_type = 'STOCK' AND l.created_at < $2
+		GROUP BY l.stock_symbol
+		HAVING SUM(`+signedQuantity+`) > 0
+		ORDER BY l.stock_symbol
+	`, userID, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holdings []models.Holding
+	var symbols []string
+	for rows.Next() {
+		var h models.Holding
+		if err := rows.Scan(&h.StockSymbol, &h.Shares, &h.CostBasisINR); err != nil {
+			return nil, err
+		}
+		holdings = append(holdings, h)
+		symbols = append(symbols, h.StockSymbol)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var prices map[string]float64
+	now := time.Now()
+	day, ok := calendar.Default.LastCompletedSession(at)
+	if !at.Before(now) || !ok {
+		prices, err = GetValuationPrices(ctx, now, symbols)
+	} else {
+		_, close, _ := calendar.Default.Session(day)
+		prices, err = GetEODPrices(ctx, day, close)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range holdings {
+		h := &holdings[i]
+		h.StockPrice = prices[h.StockSymbol]
+		h.TotalValueINR = h.Shares * h.StockPrice
+		h.AverageCostINR = h.CostBasisINR / h.Shares
+		h.UnrealizedGainINR = h.TotalValueINR - h.CostBasisINR
+		h.UnrealizedGainPct = gainPct(h.UnrealizedGainINR, h.CostBasisINR)
+	}
+	return holdings, nil
+}
+
+func writeHoldings(w StatementWriter, title string, holdings []models.Holding) error {
+	if err := w.Section(title, holdingColumns); err != nil {
+		return err
+	}
+	for _, h := range holdings {
+		if err := w.Row([]string{
+			h.StockSymbol, formatQuantity(h.Shares), formatINR(h.AverageCostINR), formatINR(h.CostBasisINR),
+			formatINR(h.StockPrice), formatINR(h.TotalValueINR), formatINR(h.UnrealizedGainINR),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatINR(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func formatQuantity(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+
+	"stock-reward-api/calendar"
+	"stock-reward-api/db"
+	"stock-reward-api/tax"
+)
+
+// GetTaxEvents returns every movement of the user's shares as tax events:
+// reward grants and other STOCK debits as acquisitions, sales as disposals,
+// shares gifted to other users as gifts, and corporate actions as splits, bonus issues or conversions. Times are in
+// the exchange timezone.
+func GetTaxEvents(ctx context.Context, userID int64) ([]tax.Event, error) {
+	loc := calendar.Default.Location
+	var events []tax.Event
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT l.direction, l.stock_symbol, l.quantity, COALESCE(l.amount_inr, 0), l.created_at,
+			COALESCE(l.reference_type, ''), COALESCE(l.reference_id::text, '')
+		FROM ledger_entries l
+		WHERE l.user_id = $1
+			AND l.entry_type = 'STOCK'
+			AND COALESCE(l.reference_type, '') <> 'CORPORATE_ACTION'
+		ORDER BY l.created_at, l.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var direction, refType string
+		var e tax.Event
+		if err := rows.Scan(&direction, &e.Symbol, &e.Quantity, &e.AmountINR, &e.At, &refType, &e.Reference); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		e.At = e.At.In(loc)
+		switch {
+		case direction == "DEBIT":
+			e.Kind = tax.Acquisition
+		case refType == "SELL":
+			e.Kind = tax.Disposal
+		case refType == "TRANSFER":
+			e.Kind = tax.Gift
+		default:
+			// Other outflows are not transfers for consideration.
+			continue
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Pool.Query(ctx, `
+		SELECT a.id::text, a.action_type, a.stock_symbol, COALESCE(a.new_symbol, ''), MIN(l.created_at),
+			COALESCE(SUM(l.quantity) FILTER (WHERE l.entry_type = 'STOCK' AND l.direction = 'CREDIT'), 0),
+			COALESCE(SUM(l.quantity) FILTER (WHERE l.entry_type = 'STOCK' AND l.direction = 'DEBIT'), 0),
+			COALESCE(SUM(l.amount_inr) FILTER (WHERE l.entry_type = 'STOCK' AND l.direction = 'DEBIT'), 0),
+			COALESCE(SUM(l.amount_inr) FILTER (WHERE l.entry_type = 'CASH'), 0)
+		FROM ledger_entries l
+		JOIN corporate_actions a ON a.id = l.reference_id
+		WHERE l.user_id = $1 AND l.reference_type = 'CORPORATE_ACTION'
+		GROUP BY a.id, a.action_type, a.stock_symbol, a.new_symbol
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var actionType string
+		var credited, debited float64
+		var e tax.Event
+		if err := rows.Scan(&e.Reference, &actionType, &e.Symbol, &e.NewSymbol, &e.At, &credited, &debited, &e.CarriedINR, &e.CashINR); err != nil {
+			return nil, err
+		}
+		e.At = e.At.In(loc)
+		switch actionType {
+		case ActionSplit:
+			e.Kind, e.Quantity = tax.Split, debited
+		case ActionBonus:
+			e.Kind, e.Quantity = tax.Bonus, debited
+		case ActionSymbolChange, ActionMerger:
+			e.Kind, e.Quantity, e.NewQuantity = tax.Conversion, credited, debited
+		default:
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// GetPerquisites returns the grant-date value of every reward the user has
+// received.
+func GetPerquisites(ctx context.Context, userID int64) ([]tax.Perquisite, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id::text, stock_symbol, timestamp, shares, COALESCE(grant_price, 0)
+		FROM rewards
+		WHERE user_id = $1
+		ORDER BY timestamp, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []tax.Perquisite
+	for rows.Next() {
+		var p tax.Perquisite
+		if err := rows.Scan(&p.RewardID, &p.Symbol, &p.GrantedAt, &p.Quantity, &p.GrantPrice); err != nil {
+			return nil, err
+		}
+		p.GrantedAt = p.GrantedAt.In(calendar.Default.Location)
+		p.ValueINR = round2(p.Quantity * p.GrantPrice)
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// GetTaxStatement builds the user's tax statement for fy from their full
+// reward, ledger and dividend history.
+func GetTaxStatement(ctx context.Context, userID int64, fy tax.FinancialYear) (tax.Statement, error) {
+	perquisites, err := GetPerquisites(ctx, userID)
+	if err != nil {
+		return tax.Statement{}, err
+	}
+
+	events, err := GetTaxEvents(ctx, userID)
+	if err != nil {
+		return tax.Statement{}, err
+	}
+	realized, _ := tax.Realize(events)
+
+	entitlements, err := GetUserDividends(ctx, userID)
+	if err != nil {
+		return tax.Statement{}, err
+	}
+	var dividends []tax.DividendIncome
+	for _, e := range entitlements {
+		if e.Status != DividendStatusPaid {
+			continue
+		}
+		dividends = append(dividends, tax.DividendIncome{
+			Symbol:   e.StockSymbol,
+			PaidOn:   e.PayDate,
+			Shares:   e.Shares,
+			GrossINR: e.GrossINR,
+			TDSINR:   e.TDSINR,
+			NetINR:   e.NetINR,
+		})
+	}
+
+	return tax.BuildStatement(userID, fy, perquisites, realized, dividends), nil
+}
@@ -0,0 +1,337 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"stock-reward-api/calendar"
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+	"stock-reward-api/pricecache"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	TransferPendingAcceptance = "PENDING_ACCEPTANCE"
+	TransferCompleted         = "COMPLETED"
+	TransferDeclined          = "DECLINED"
+	TransferCancelled         = "CANCELLED"
+)
+
+var (
+	ErrTransferNotFound  = errors.New("transfer not found")
+	ErrTransferState     = errors.New("transfer is not pending acceptance")
+	ErrTransferSelf      = errors.New("cannot transfer shares to yourself")
+	ErrRecipientNotFound = errors.New("recipient not found")
+	ErrTransferLimit     = errors.New("daily transfer limit exceeded")
+)
+
+// TransferLimits bound what a user may send per calendar day in the exchange
+// timezone. Declined and cancelled transfers do not count.
+type TransferLimits struct {
+	DailyCount    int
+	DailyValueINR float64
+}
+
+// DefaultTransferLimits apply to transfers made through the API.
+var DefaultTransferLimits = TransferLimits{DailyCount: 10, DailyValueINR: 100000}
+
+// RequireTransferAcceptance is used when a transfer request does not say
+// whether the recipient must accept it.
+var RequireTransferAcceptance = true
+
+const shareTransferColumns = `id, sender_id, recipient_id, stock_symbol, quantity, value_inr, requires_acceptance, status,
+	COALESCE(note, ''), COALESCE(client_transfer_id, ''), created_at, completed_at`
+
+func scanShareTransfer(row pgx.Row) (models.ShareTransfer, error) {
+	var t models.ShareTransfer
+	err := row.Scan(&t.ID, &t.SenderID, &t.RecipientID, &t.StockSymbol, &t.Quantity, &t.ValueINR, &t.RequiresAcceptance,
+		&t.Status, &t.Note, &t.ClientTransferID, &t.CreatedAt, &t.CompletedAt)
+	return t, err
+}
+
+// CreateShareTransfer gifts quantity shares of symbol from sender to the
+// user registered with recipientEmail. The shares are checked against the
+// sender's available holding and the daily limits. Without acceptance the
+// transfer completes immediately; otherwise the shares stay committed until
+// the recipient accepts or declines, or the sender cancels. A repeated
+// clientTransferID returns the existing transfer with existing=true.
+func CreateShareTransfer(ctx context.Context, senderID int64, recipientEmail, symbol string, quantity float64, requiresAcceptance bool, note, clientTransferID string, limits TransferLimits) (t models.ShareTransfer, existing bool, err error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return t, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUserHoldings(ctx, tx, senderID); err != nil {
+		return t, false, err
+	}
+
+	if clientTransferID != "" {
+		t, err = scanShareTransfer(tx.QueryRow(ctx, `SELECT `+shareTransferColumns+` FROM share_transfers WHERE sender_id = $1 AND client_transfer_id = $2`, senderID, clientTransferID))
+		if err == nil {
+			return t, true, nil
+		}
+		if err != pgx.ErrNoRows {
+			return t, false, err
+		}
+	}
+
+	var recipientID int64
+	if err := tx.QueryRow(ctx, `SELECT id FROM users WHERE lower(email) = lower($1)`, recipientEmail).Scan(&recipientID); err != nil {
+		if err == pgx.ErrNoRows {
+			return t, false, ErrRecipientNotFound
+		}
+		return t, false, err
+	}
+	if recipientID == senderID {
+		return t, false, ErrTransferSelf
+	}
+	if err := requireKYCVerified(ctx, tx, senderID); err != nil {
+		return t, false, err
+	}
+	// A recipient who must accept can complete KYC before accepting.
+	if !requiresAcceptance {
+		if err := requireKYCVerified(ctx, tx, recipientID); err != nil {
+			return t, false, fmt.Errorf("recipient %w", err)
+		}
+	}
+
+	var status string
+	if err := tx.QueryRow(ctx, "SELECT status FROM stocks WHERE stock_symbol = $1", symbol).Scan(&status); err != nil {
+		if err == pgx.ErrNoRows {
+			return t, false, ErrStockNotTradable
+		}
+		return t, false, err
+	}
+	if status != StockStatusActive {
+		return t, false, ErrStockNotTradable
+	}
+
+	available, err := availableShares(ctx, tx, senderID, symbol, uuid.Nil)
+	if err != nil {
+		return t, false, err
+	}
+	if quantity > available+1e-9 {
+		return t, false, ErrInsufficientShares
+	}
+
+	price, err := pricecache.Default.Get(ctx, symbol)
+	if err != nil {
+		return t, false, err
+	}
+	value := round2(quantity * price)
+
+	var count int
+	var sent float64
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(value_inr), 0) FROM share_transfers
+		WHERE sender_id = $1 AND status IN ($2, $3) AND created_at >= $4
+	`, senderID, TransferPendingAcceptance, TransferCompleted, calendar.Default.Date(time.Now())).Scan(&count, &sent)
+	if err != nil {
+		return t, false, err
+	}
+	if limits.DailyCount > 0 && count >= limits.DailyCount {
+		return t, false, fmt.Errorf("%w: at most %d transfers per day", ErrTransferLimit, limits.DailyCount)
+	}
+	if limits.DailyValueINR > 0 && sent+value > limits.DailyValueINR {
+		return t, false, fmt.Errorf("%w: %.2f of the daily limit of %.2f remains", ErrTransferLimit, limits.DailyValueINR-sent, limits.DailyValueINR)
+	}
+
+	status = TransferPendingAcceptance
+	if !requiresAcceptance {
+		status = TransferCompleted
+	}
+	t, err = scanShareTransfer(tx.QueryRow(ctx, `
+		INSERT INTO share_transfers
+		(sender_id, recipient_id, stock_symbol, quantity, value_inr, requires_acceptance, status, note, client_transfer_id, completed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''), CASE WHEN $7 = '`+TransferCompleted+`' THEN now() END)
+		RETURNING `+shareTransferColumns,
+		senderID, recipientID, symbol, quantity, value, requiresAcceptance, status, note, clientTransferID))
+	if err != nil {
+		return t, false, err
+	}
+
+	if !requiresAcceptance {
+		if err := postTransfer(ctx, tx, t); err != nil {
+			return t, false, err
+		}
+	}
+	if err := recordAudit(ctx, tx, models.AuditTransferCreate, "share_transfer", t.ID.String(), nil, t); err != nil {
+		return t, false, err
+	}
+	return t, false, tx.Commit(ctx)
+}
+
+// postTransfer moves the shares: a STOCK CREDIT from the sender and a STOCK
+// DEBIT to the recipient, both carrying the sender's average cost of the
+// shares so the gift does not change their value as cost basis.
+func postTransfer(ctx context.Context, tx pgx.Tx, t models.ShareTransfer) error {
+	var shares, cost float64
+	err := tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(`+signedQuantity+`), 0), `+averageCost+`
+		FROM ledger_entries l
+		WHERE l.user_id = $1 AND l.stock_symbol = $2 AND l.entry_type = 'STOCK'
+	`, t.SenderID, t.StockSymbol).Scan(&shares, &cost)
+	if err != nil {
+		return err
+	}
+	if t.Quantity > shares+1e-9 {
+		return ErrInsufficientShares
+	}
+	carried := round2(cost * t.Quantity / shares)
+
+	batch := &pgx.Batch{}
+	for _, leg := range []struct {
+		userID    int64
+		direction string
+	}{{t.SenderID, "CREDIT"}, {t.RecipientID, "DEBIT"}} {
+		batch.Queue(`
+			INSERT INTO ledger_entries
+			(user_id, entry_type, stock_symbol, quantity, direction, reference_id, reference_type, amount_inr, created_at)
+			VALUES ($1,'STOCK',$2,$3,$4,$5,'TRANSFER',$6,now())
+		`, leg.userID, t.StockSymbol, t.Quantity, leg.direction, t.ID, carried)
+	}
+	return execBatch(ctx, tx, batch)
+}
+
+// lockPendingTransfer loads a transfer pending acceptance for update,
+// checking that userID is the given party to it.
+func lockPendingTransfer(ctx context.Context, tx pgx.Tx, id uuid.UUID, userID int64, asRecipient bool) (models.ShareTransfer, error) {
+	t, err := scanShareTransfer(tx.QueryRow(ctx, `SELECT `+shareTransferColumns+` FROM share_transfers WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return t, ErrTransferNotFound
+		}
+		return t, err
+	}
+	if (asRecipient && t.RecipientID != userID) || (!asRecipient && t.SenderID != userID) {
+		return t, ErrTransferNotFound
+	}
+	if t.Status != TransferPendingAcceptance {
+		return t, ErrTransferState
+	}
+	return t, nil
+}
+
+// AcceptShareTransfer completes a transfer awaiting the recipient's
+// acceptance, after checking the sender is still KYC-verified and holds the
+// shares, and the stock is still active.
+func AcceptShareTransfer(ctx context.Context, recipientID int64, id uuid.UUID) (models.ShareTransfer, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return models.ShareTransfer{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	t, err := lockPendingTransfer(ctx, tx, id, recipientID, true)
+	if err != nil {
+		return t, err
+	}
+	if err := requireKYCVerified(ctx, tx, recipientID); err != nil {
+		return t, err
+	}
+	// The sender and the stock were checked when the transfer was sent; both
+	// must still hold now.
+	if err := requireKYCVerified(ctx, tx, t.SenderID); err != nil {
+		return t, fmt.Errorf("sender %w", err)
+	}
+	var status string
+	if err := tx.QueryRow(ctx, "SELECT status FROM stocks WHERE stock_symbol = $1", t.StockSymbol).Scan(&status); err != nil {
+		return t, err
+	}
+	if status != StockStatusActive {
+		return t, ErrStockNotTradable
+	}
+	if err := lockUserHoldings(ctx, tx, t.SenderID); err != nil {
+		return t, err
+	}
+	available, err := availableShares(ctx, tx, t.SenderID, t.StockSymbol, t.ID)
+	if err != nil {
+		return t, err
+	}
+	if t.Quantity > available+1e-9 {
+		return t, ErrInsufficientShares
+	}
+
+	before := t
+	t, err = scanShareTransfer(tx.QueryRow(ctx, `
+		UPDATE share_transfers SET status = $2, completed_at = now() WHERE id = $1
+		RETURNING `+shareTransferColumns, id, TransferCompleted))
+	if err != nil {
+		return t, err
+	}
+	if err := postTransfer(ctx, tx, t); err != nil {
+		return t, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditTransferAccept, "share_transfer", id.String(), before, t); err != nil {
+		return t, err
+	}
+	return t, tx.Commit(ctx)
+}
+
+// DeclineShareTransfer lets the recipient refuse a pending transfer.
+func DeclineShareTransfer(ctx context.Context, recipientID int64, id uuid.UUID) (models.ShareTransfer, error) {
+	return closeShareTransfer(ctx, recipientID, id, true, TransferDeclined, models.AuditTransferDecline)
+}
+
+// CancelShareTransfer lets the sender withdraw a pending transfer.
+func CancelShareTransfer(ctx context.Context, senderID int64, id uuid.UUID) (models.ShareTransfer, error) {
+	return closeShareTransfer(ctx, senderID, id, false, TransferCancelled, models.AuditTransferCancel)
+}
+
+func closeShareTransfer(ctx context.Context, userID int64, id uuid.UUID, asRecipient bool, status, action string) (models.ShareTransfer, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return models.ShareTransfer{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockPendingTransfer(ctx, tx, id, userID, asRecipient)
+	if err != nil {
+		return before, err
+	}
+	t, err := scanShareTransfer(tx.QueryRow(ctx, `
+		UPDATE share_transfers SET status = $2, completed_at = now() WHERE id = $1
+		RETURNING `+shareTransferColumns, id, status))
+	if err != nil {
+		return t, err
+	}
+	if err := recordAudit(ctx, tx, action, "share_transfer", id.String(), before, t); err != nil {
+		return t, err
+	}
+	return t, tx.Commit(ctx)
+}
+
+// GetUserShareTransfers returns transfers the user sent or received, newest
+// first, with Direction set to SENT or RECEIVED.
+func GetUserShareTransfers(ctx context.Context, userID int64) ([]models.ShareTransfer, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+shareTransferColumns+`
+		FROM share_transfers
+		WHERE sender_id = $1 OR recipient_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.ShareTransfer
+	for rows.Next() {
+		t, err := scanShareTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		t.Direction = "RECEIVED"
+		if t.SenderID == userID {
+			t.Direction = "SENT"
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,319 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+var ErrInvalidRole = errors.New("role must be user, issuer, support or admin")
+
+// AdminEmails are made admins at startup and on registration.
+var AdminEmails []string
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	switch role {
+	case models.RoleUser, models.RoleIssuer, models.RoleSupport, models.RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// RoleForNewUser is the role a user registering with email starts with.
+func RoleForNewUser(email string) string {
+	for _, admin := range AdminEmails {
+		if strings.EqualFold(admin, email) {
+			return models.RoleAdmin
+		}
+	}
+	return models.RoleUser
+}
+
+// PromoteAdmins gives every registered user listed in AdminEmails the admin
+// role and returns how many changed.
+func PromoteAdmins(ctx context.Context) (int64, error) {
+	if len(AdminEmails) == 0 {
+		return 0, nil
+	}
+	lowered := make([]string, len(AdminEmails))
+	for i, email := range AdminEmails {
+		lowered[i] = strings.ToLower(email)
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, role FROM users WHERE lower(email) = ANY($2) AND role <> $1 FOR UPDATE
+	`, models.RoleAdmin, lowered)
+	if err != nil {
+		return 0, err
+	}
+	previous := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var role string
+		if err := rows.Scan(&id, &role); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		previous[id] = role
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id, role := range previous {
+		if _, err := tx.Exec(ctx, `UPDATE users SET role = $2 WHERE id = $1`, id, models.RoleAdmin); err != nil {
+			return 0, err
+		}
+		if err := recordAudit(ctx, tx, models.AuditRoleChange, "user", strconv.FormatInt(id, 10),
+			map[string]string{"role": role}, map[string]string{"role": models.RoleAdmin}); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(previous)), tx.Commit(ctx)
+}
+
+// SetUserRole changes a user's role. Tokens issued under the old role stop
+// being accepted.
+func SetUserRole(ctx context.Context, userID int64, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var previous string
+	err = tx.QueryRow(ctx, `SELECT role FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&previous)
+	if err == pgx.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE users SET role = $2 WHERE id = $1`, userID, role); err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, models.AuditRoleChange, "user", strconv.FormatInt(userID, 10),
+		map[string]string{"role": previous}, map[string]string{"role": role}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// CreatePasswordReset stores the hash of token as the only usable reset
+// token of the user registered with email, replacing any earlier one. It
+// returns found=false, and stores nothing, when no user has that email.
+func CreatePasswordReset(ctx context.Context, email, token string, expiresAt time.Time) (user models.User, found bool, err error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return user, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `SELECT id, name, email FROM users WHERE lower(email) = lower($1)`, email).Scan(&user.ID, &user.Name, &user.Email)
+	if err == pgx.ErrNoRows {
+		return user, false, nil
+	}
+	if err != nil {
+		return user, false, err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE password_resets SET used_at = now() WHERE user_id = $1 AND used_at IS NULL`, user.ID)
+	if err != nil {
+		return user, false, err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO password_resets (user_id, token_hash, expires_at) VALUES ($1, $2, $3)
+	`, user.ID, hashToken(token), expiresAt)
+	if err != nil {
+		return user, false, err
+	}
+	return user, true, tx.Commit(ctx)
+}
+
+// ResetPassword consumes a reset token, sets the user's password hash and
+// revokes all of their sessions, returning the user's ID.
+func ResetPassword(ctx context.Context, token, passwordHash string) (int64, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id uuid.UUID
+	var userID int64
+	err = tx.QueryRow(ctx, `
+		SELECT id, user_id FROM password_resets
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		FOR UPDATE
+	`, hashToken(token)).Scan(&id, &userID)
+	if err == pgx.ErrNoRows {
+		return 0, ErrInvalidResetToken
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE password_resets SET used_at = now() WHERE id = $1`, id); err != nil {
+		return 0, err
+	}
+	if err := setPassword(ctx, tx, userID, passwordHash); err != nil {
+		return 0, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditPasswordReset, "user", strconv.FormatInt(userID, 10), nil, nil); err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit(ctx)
+}
+
+// GetPasswordHash returns the user's bcrypt password hash.
+func GetPasswordHash(ctx context.Context, userID int64) (string, error) {
+	var hash string
+	err := db.Pool.QueryRow(ctx, `SELECT password FROM users WHERE id = $1`, userID).Scan(&hash)
+	if err == pgx.ErrNoRows {
+		return "", ErrUserNotFound
+	}
+	return hash, err
+}
+
+// ChangePassword sets the user's password hash and revokes all of their
+// sessions.
+func ChangePassword(ctx context.Context, userID int64, passwordHash string) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := setPassword(ctx, tx, userID, passwordHash); err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, models.AuditPasswordChange, "user", strconv.FormatInt(userID, 10), nil, nil); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func setPassword(ctx context.Context, tx pgx.Tx, userID int64, passwordHash string) error {
+	if _, err := tx.Exec(ctx, `UPDATE users SET password = $2 WHERE id = $1`, userID, passwordHash); err != nil {
+		return err
+	}
+	// Outstanding reset links would otherwise still work after a change.
+	if _, err := tx.Exec(ctx, `UPDATE password_resets SET used_at = now() WHERE user_id = $1 AND used_at IS NULL`, userID); err != nil {
+		return err
+	}
+	return revokeUserSessions(ctx, tx, userID)
+}
+
+var (
+	ErrEmailNotVerified    = errors.New("email is not verified")
+	ErrVerificationLimited = errors.New("too many verification emails requested")
+)
+
+// RequireVerifiedEmailForLogin stops users who have not verified their
+// email from logging in.
+var RequireVerifiedEmailForLogin = false
+
+// RequireVerifiedEmailForRewards stops rewards being granted to users who
+// have not verified their email.
+var RequireVerifiedEmailForRewards = true
+
+// VerificationResendLimits bound how often verification emails are sent to
+// one user.
+type VerificationResendLimits struct {
+	MinInterval time.Duration
+	MaxPerDay   int
+}
+
+// DefaultVerificationResendLimits apply to the resend endpoint.
+var DefaultVerificationResendLimits = VerificationResendLimits{MinInterval: time.Minute, MaxPerDay: 5}
+
+// GetUserByEmail returns the user registered with email and whether they
+// have verified it.
+func GetUserByEmail(ctx context.Context, email string) (user models.User, verified bool, err error) {
+	err = db.Pool.QueryRow(ctx, `
+		SELECT id, name, email, role, email_verified_at IS NOT NULL FROM users WHERE lower(email) = lower($1)
+	`, email).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &verified)
+	if err == pgx.ErrNoRows {
+		return user, false, ErrUserNotFound
+	}
+	return user, verified, err
+}
+
+// RecordVerificationSend records that a verification email is about to be
+// sent to the user, or returns ErrVerificationLimited with how long to wait
+// when limits do not allow another one yet.
+func RecordVerificationSend(ctx context.Context, userID int64, limits VerificationResendLimits) (retryAfter time.Duration, err error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('email_verification'), $1::int)`, userID); err != nil {
+		return 0, err
+	}
+
+	var count int
+	var last, oldest *time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*), MAX(sent_at), MIN(sent_at)
+		FROM email_verification_sends
+		WHERE user_id = $1 AND sent_at > now() - interval '1 day'
+	`, userID).Scan(&count, &last, &oldest)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	if last != nil && now.Sub(*last) < limits.MinInterval {
+		return limits.MinInterval - now.Sub(*last), ErrVerificationLimited
+	}
+	if limits.MaxPerDay > 0 && count >= limits.MaxPerDay && oldest != nil {
+		return oldest.Add(24 * time.Hour).Sub(now), ErrVerificationLimited
+	}
+
+	if _, err := tx.Exec(ctx, `INSERT INTO email_verification_sends (user_id) VALUES ($1)`, userID); err != nil {
+		return 0, err
+	}
+	return 0, tx.Commit(ctx)
+}
+
+// VerifyEmail marks email verified for the user, provided it is still their
+// address. It reports whether it had already been verified.
+func VerifyEmail(ctx context.Context, userID int64, email string) (alreadyVerified bool, err error) {
+	err = db.Pool.QueryRow(ctx, `
+		WITH u AS (
+			SELECT id, email_verified_at FROM users
+			WHERE id = $1 AND lower(email) = lower($2)
+			FOR UPDATE
+		)
+		UPDATE users SET email_verified_at = COALESCE(users.email_verified_at, now())
+		FROM u WHERE users.id = u.id
+		RETURNING u.email_verified_at IS NOT NULL
+	`, userID, email).Scan(&alreadyVerified)
+	if err == pgx.ErrNoRows {
+		return false, ErrUserNotFound
+	}
+	return alreadyVerified, err
+}
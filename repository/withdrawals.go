@@ -0,0 +1,419 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"stock-reward-api/calendar"
+	"stock-reward-api/db"
+	"stock-reward-api/models"
+	"stock-reward-api/payments"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	WithdrawalRequested  = "REQUESTED"
+	WithdrawalProcessing = "PROCESSING"
+	WithdrawalPaid       = "PAID"
+	WithdrawalFailed     = "FAILED"
+)
+
+var (
+	ErrBankAccountExists   = errors.New("bank account already registered")
+	ErrBankAccountNotFound = errors.New("bank account not found")
+	ErrWithdrawalNotFound  = errors.New("withdrawal not found")
+	ErrWithdrawalState     = errors.New("withdrawal is not in the expected state")
+	ErrInsufficientFunds   = errors.New("insufficient wallet balance")
+	ErrWithdrawalLimit     = errors.New("withdrawal limit exceeded")
+)
+
+// WithdrawalLimits bound withdrawal amounts. Daily is the total a user may
+// withdraw per calendar day in the exchange timezone, excluding failed
+// withdrawals.
+type WithdrawalLimits struct {
+	MinINR   float64
+	MaxINR   float64
+	DailyINR float64
+}
+
+// DefaultWithdrawalLimits apply to withdrawal requests made through the API.
+var DefaultWithdrawalLimits = WithdrawalLimits{MinINR: 100, MaxINR: 200000, DailyINR: 500000}
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// walletAmount is a CASH entry l's effect on the user's wallet. Like STOCK
+// entries, CASH entries add to the user's account on DEBIT and take from it
+// on CREDIT.
+const walletAmount = `CASE WHEN l.direction = 'DEBIT' THEN l.amount_inr ELSE -l.amount_inr END`
+
+// walletBalance is the user's cash balance, as reported by GetWallet.
+func walletBalance(ctx context.Context, q queryRower, userID int64) (float64, error) {
+	var balance float64
+	err := q.QueryRow(ctx, `
+		SELECT COALESCE(SUM(`+walletAmount+`), 0)
+		FROM ledger_entries l
+		WHERE l.user_id = $1 AND l.entry_type = 'CASH' AND COALESCE(l.reference_type, '') <> 'REWARD'
+	`, userID).Scan(&balance)
+	return round2(balance), err
+}
+
+// lockUserWallet serialises debits from a user's wallet for the rest of the
+// transaction.
+func lockUserWallet(ctx context.Context, tx pgx.Tx, userID int64) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('wallet'), $1::int)`, userID)
+	return err
+}
+
+const bankAccountColumns = `id, user_id, holder_name, ifsc, account_number_masked, created_at`
+
+func scanBankAccount(row pgx.Row) (models.BankAccount, error) {
+	var a models.BankAccount
+	err := row.Scan(&a.ID, &a.UserID, &a.HolderName, &a.IFSC, &a.AccountNumberMasked, &a.CreatedAt)
+	return a, err
+}
+
+// BankAccountExists reports whether the user already registered the account
+// with the given hash.
+func BankAccountExists(ctx context.Context, userID int64, hash string) (bool, error) {
+	var exists bool
+	err := db.Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM bank_accounts WHERE user_id = $1 AND account_number_hash = $2)`, userID, hash).Scan(&exists)
+	return exists, err
+}
+
+// CreateBankAccount stores a bank account registered with the gateway as
+// beneficiaryID. Only the masked number and a keyed hash of the full number
+// are kept.
+func CreateBankAccount(ctx context.Context, a models.BankAccount, hash, beneficiaryID string) (models.BankAccount, error) {
+	account, err := scanBankAccount(db.Pool.QueryRow(ctx, `
+		INSERT INTO bank_accounts (user_id, holder_name, ifsc, account_number_masked, account_number_hash, beneficiary_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, account_number_hash) DO NOTHING
+		RETURNING `+bankAccountColumns,
+		a.UserID, a.HolderName, a.IFSC, a.AccountNumberMasked, hash, beneficiaryID))
+	if err == pgx.ErrNoRows {
+		return account, ErrBankAccountExists
+	}
+	return account, err
+}
+
+func GetBankAccounts(ctx context.Context, userID int64) ([]models.BankAccount, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT `+bankAccountColumns+` FROM bank_accounts WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.BankAccount
+	for rows.Next() {
+		a, err := scanBankAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+const withdrawalColumns = `id, user_id, bank_account_id, amount_inr, COALESCE(client_request_id, ''), status,
+	COALESCE(payout_ref, ''), COALESCE(failure_reason, ''), created_at, processing_at, completed_at`
+
+func scanWithdrawal(row pgx.Row) (models.Withdrawal, error) {
+	var w models.Withdrawal
+	err := row.Scan(&w.ID, &w.UserID, &w.BankAccountID, &w.AmountINR, &w.ClientRequestID, &w.Status,
+		&w.PayoutRef, &w.FailureReason, &w.CreatedAt, &w.ProcessingAt, &w.CompletedAt)
+	return w, err
+}
+
+// queueWithdrawalEntry posts a ledger entry for a withdrawal transition.
+func queueWithdrawalEntry(batch *pgx.Batch, w models.Withdrawal, entryType, direction string) {
+	batch.Queue(`
+		INSERT INTO ledger_entries
+		(user_id, entry_type, amount_inr, direction, reference_id, reference_type, created_at)
+		VALUES ($1,$2,$3,$4,$5,'WITHDRAWAL',now())
+	`, w.UserID, entryType, w.AmountINR, direction, w.ID)
+}
+
+// CreateWithdrawal requests a payout of amount from the user's wallet to one
+// of their bank accounts. The amount is debited from the wallet straight
+// away. A repeated clientRequestID returns the existing withdrawal with
+// existing=true.
+func CreateWithdrawal(ctx context.Context, userID int64, bankAccountID uuid.UUID, amount float64, clientRequestID string, limits WithdrawalLimits) (w models.Withdrawal, existing bool, err error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return w, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUserWallet(ctx, tx, userID); err != nil {
+		return w, false, err
+	}
+	if err := requireKYCVerified(ctx, tx, userID); err != nil {
+		return w, false, err
+	}
+
+	if clientRequestID != "" {
+		w, err = scanWithdrawal(tx.QueryRow(ctx, `SELECT `+withdrawalColumns+` FROM withdrawals WHERE user_id = $1 AND client_request_id = $2`, userID, clientRequestID))
+		if err == nil {
+			return w, true, nil
+		}
+		if err != pgx.ErrNoRows {
+			return w, false, err
+		}
+	}
+
+	var owned bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM bank_accounts WHERE id = $1 AND user_id = $2)`, bankAccountID, userID).Scan(&owned); err != nil {
+		return w, false, err
+	}
+	if !owned {
+		return w, false, ErrBankAccountNotFound
+	}
+
+	amount = round2(amount)
+	if amount < limits.MinINR {
+		return w, false, fmt.Errorf("%w: minimum withdrawal is %.2f", ErrWithdrawalLimit, limits.MinINR)
+	}
+	if limits.MaxINR > 0 && amount > limits.MaxINR {
+		return w, false, fmt.Errorf("%w: maximum withdrawal is %.2f", ErrWithdrawalLimit, limits.MaxINR)
+	}
+	if limits.DailyINR > 0 {
+		var today float64
+		err := tx.QueryRow(ctx, `
+			SELECT COALESCE(SUM(amount_inr), 0) FROM withdrawals
+			WHERE user_id = $1 AND status <> $2 AND created_at >= $3
+		`, userID, WithdrawalFailed, calendar.Default.Date(time.Now())).Scan(&today)
+		if err != nil {
+			return w, false, err
+		}
+		if today+amount > limits.DailyINR {
+			return w, false, fmt.Errorf("%w: %.2f of the daily limit of %.2f remains", ErrWithdrawalLimit, limits.DailyINR-today, limits.DailyINR)
+		}
+	}
+
+	balance, err := walletBalance(ctx, tx, userID)
+	if err != nil {
+		return w, false, err
+	}
+	if amount > balance {
+		return w, false, ErrInsufficientFunds
+	}
+
+	w, err = scanWithdrawal(tx.QueryRow(ctx, `
+		INSERT INTO withdrawals (user_id, bank_account_id, amount_inr, client_request_id)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING `+withdrawalColumns,
+		userID, bankAccountID, amount, clientRequestID))
+	if err != nil {
+		return w, false, err
+	}
+
+	batch := &pgx.Batch{}
+	queueWithdrawalEntry(batch, w, "CASH", "CREDIT")
+	if err := execBatch(ctx, tx, batch); err != nil {
+		return w, false, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditWithdrawalCreate, "withdrawal", w.ID.String(), nil, w); err != nil {
+		return w, false, err
+	}
+	return w, false, tx.Commit(ctx)
+}
+
+// ProcessWithdrawal moves a requested withdrawal to PROCESSING, parking the
+// amount in PAYOUT_CLEARING, and hands it to the gateway. If the gateway
+// refuses the payout the withdrawal fails and the amount is refunded.
+func ProcessWithdrawal(ctx context.Context, id uuid.UUID, gateway payments.Gateway) (models.Withdrawal, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return models.Withdrawal{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	w, err := scanWithdrawal(tx.QueryRow(ctx, `SELECT `+withdrawalColumns+` FROM withdrawals WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return w, ErrWithdrawalNotFound
+		}
+		return w, err
+	}
+	if w.Status != WithdrawalRequested {
+		return w, ErrWithdrawalState
+	}
+
+	var beneficiaryID string
+	if err := tx.QueryRow(ctx, `SELECT beneficiary_id FROM bank_accounts WHERE id = $1`, w.BankAccountID).Scan(&beneficiaryID); err != nil {
+		return w, err
+	}
+
+	before := w
+	w, err = scanWithdrawal(tx.QueryRow(ctx, `
+		UPDATE withdrawals SET status = $2, processing_at = now() WHERE id = $1
+		RETURNING `+withdrawalColumns, id, WithdrawalProcessing))
+	if err != nil {
+		return w, err
+	}
+	batch := &pgx.Batch{}
+	queueWithdrawalEntry(batch, w, "PAYOUT_CLEARING", "DEBIT")
+	if err := execBatch(ctx, tx, batch); err != nil {
+		return w, err
+	}
+	if err := recordAudit(ctx, tx, models.AuditWithdrawalProcess, "withdrawal", id.String(), before, w); err != nil {
+		return w, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return w, err
+	}
+
+	// The withdrawal ID is the payout reference, so retrying after a lost
+	// response cannot pay twice.
+	ref, err := gateway.InitiatePayout(ctx, payments.Payout{
+		Reference:     w.ID.String(),
+		BeneficiaryID: beneficiaryID,
+		AmountINR:     w.AmountINR,
+	})
+	if err != nil {
+		return completeWithdrawal(ctx, w.ID, WithdrawalFailed, err.Error())
+	}
+
+	return scanWithdrawal(db.Pool.QueryRow(ctx, `
+		UPDATE withdrawals SET payout_ref = $2 WHERE id = $1
+		RETURNING `+withdrawalColumns, w.ID, ref))
+}
+
+// HandlePayoutCallback applies a gateway callback to the withdrawal it
+// refers to. Callbacks are recorded by event ID, and a redelivered event
+// returns the withdrawal unchanged with duplicate=true.
+func HandlePayoutCallback(ctx context.Context, cb payments.Callback) (w models.Withdrawal, duplicate bool, err error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return w, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO payment_callbacks (event_id, payout_ref, status, reason)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (event_id) DO NOTHING
+	`, cb.EventID, cb.PayoutRef, cb.Status, cb.Reason)
+	if err != nil {
+		return w, false, err
+	}
+
+	w, err = scanWithdrawal(tx.QueryRow(ctx, `SELECT `+withdrawalColumns+` FROM withdrawals WHERE payout_ref = $1 FOR UPDATE`, cb.PayoutRef))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return w, false, ErrWithdrawalNotFound
+		}
+		return w, false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return w, true, nil
+	}
+
+	status := WithdrawalPaid
+	if cb.Status == payments.CallbackFailed {
+		status = WithdrawalFailed
+	}
+	if w.Status == status {
+		// A different event for an outcome already applied.
+		return w, true, tx.Commit(ctx)
+	}
+	if w.Status != WithdrawalProcessing {
+		return w, false, ErrWithdrawalState
+	}
+
+	w, err = transitionWithdrawal(ctx, tx, w, status, cb.Reason)
+	if err != nil {
+		return w, false, err
+	}
+	return w, false, tx.Commit(ctx)
+}
+
+// completeWithdrawal moves a processing withdrawal to its final status in a
+// transaction of its own.
+func completeWithdrawal(ctx context.Context, id uuid.UUID, status, reason string) (models.Withdrawal, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return models.Withdrawal{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	w, err := scanWithdrawal(tx.QueryRow(ctx, `SELECT `+withdrawalColumns+` FROM withdrawals WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return w, err
+	}
+	if w.Status != WithdrawalProcessing {
+		return w, ErrWithdrawalState
+	}
+	w, err = transitionWithdrawal(ctx, tx, w, status, reason)
+	if err != nil {
+		return w, err
+	}
+	return w, tx.Commit(ctx)
+}
+
+// transitionWithdrawal settles a processing withdrawal. Paid withdrawals
+// clear PAYOUT_CLEARING; failed ones clear it and refund the wallet.
+func transitionWithdrawal(ctx context.Context, tx pgx.Tx, w models.Withdrawal, status, reason string) (models.Withdrawal, error) {
+	updated, err := scanWithdrawal(tx.QueryRow(ctx, `
+		UPDATE withdrawals SET status = $2, failure_reason = NULLIF($3, ''), completed_at = now() WHERE id = $1
+		RETURNING `+withdrawalColumns, w.ID, status, reason))
+	if err != nil {
+		return w, err
+	}
+
+	batch := &pgx.Batch{}
+	queueWithdrawalEntry(batch, updated, "PAYOUT_CLEARING", "CREDIT")
+	action := models.AuditWithdrawalPaid
+	if status == WithdrawalFailed {
+		queueWithdrawalEntry(batch, updated, "CASH", "DEBIT")
+		action = models.AuditWithdrawalFail
+	}
+	if err := execBatch(ctx, tx, batch); err != nil {
+		return updated, err
+	}
+	return updated, recordAudit(ctx, tx, action, "withdrawal", w.ID.String(), w, updated)
+}
+
+// GetRequestedWithdrawalIDs returns withdrawals still waiting to be sent to
+// the gateway, oldest first.
+func GetRequestedWithdrawalIDs(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT id FROM withdrawals WHERE status = $1 ORDER BY created_at`, WithdrawalRequested)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+func GetUserWithdrawals(ctx context.Context, userID int64) ([]models.Withdrawal, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT `+withdrawalColumns+` FROM withdrawals WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Withdrawal
+	for rows.Next() {
+		w, err := scanWithdrawal(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
@@ -1,12 +1,32 @@
 INSERT INTO stocks (stock_symbol, price) VALUES
-    ('AAPL', 1523.45),
-    ('GOOGL', 1688.20),
-    ('MSFT', 1754.90),
-    ('AMZN', 1812.75),
-    ('TSLA', 1956.30),
-    ('META', 1639.40),
-    ('NFLX', 1877.60),
-    ('NVDA', 1992.15),
-    ('INTC', 1564.80),
-    ('AMD', 1721.55)
-ON CONFLICT (stock_symbol) DO NOTHING;
\ No newline at end of file
+    ('AAPL', 19450.00),
+    ('GOOGL', 14320.00),
+    ('MSFT', 35860.00),
+    ('AMZN', 18940.00),
+    ('TSLA', 21530.00),
+    ('META', 49280.00),
+    ('NFLX', 10240.00),
+    ('NVDA', 15310.00),
+    ('INTC', 2050.00),
+    ('AMD', 13620.00)
+ON CONFLICT (stock_symbol) DO NOTHING;
+
+UPDATE stocks s SET
+    name = m.name,
+    sector = m.sector,
+    industry = m.industry,
+    market_cap_bucket = m.market_cap_bucket,
+    exchange = m.exchange
+FROM (VALUES
+    ('AAPL', 'Apple Inc.', 'Information Technology', 'Technology Hardware', 'LARGE', 'NASDAQ'),
+    ('GOOGL', 'Alphabet Inc.', 'Communication Services', 'Interactive Media', 'LARGE', 'NASDAQ'),
+    ('MSFT', 'Microsoft Corporation', 'Information Technology', 'Software', 'LARGE', 'NASDAQ'),
+    ('AMZN', 'Amazon.com Inc.', 'Consumer Discretionary', 'Internet Retail', 'LARGE', 'NASDAQ'),
+    ('TSLA', 'Tesla Inc.', 'Consumer Discretionary', 'Automobiles', 'LARGE', 'NASDAQ'),
+    ('META', 'Meta Platforms Inc.', 'Communication Services', 'Interactive Media', 'LARGE', 'NASDAQ'),
+    ('NFLX', 'Netflix Inc.', 'Communication Services', 'Entertainment', 'LARGE', 'NASDAQ'),
+    ('NVDA', 'NVIDIA Corporation', 'Information Technology', 'Semiconductors', 'LARGE', 'NASDAQ'),
+    ('INTC', 'Intel Corporation', 'Information Technology', 'Semiconductors', 'MID', 'NASDAQ'),
+    ('AMD', 'Advanced Micro Devices Inc.', 'Information Technology', 'Semiconductors', 'LARGE', 'NASDAQ')
+) AS m (stock_symbol, name, sector, industry, market_cap_bucket, exchange)
+WHERE s.stock_symbol = m.stock_symbol AND s.sector IS NULL;
@@ -1,12 +1,12 @@
-INSERT INTO users (name, email, password) VALUES
-('Aarav Sharma',     'aarav.sharma@example.com',     '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq'),
-('Rohan Mehta',      'rohan.mehta@example.com',      '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq'),
-('Kunal Verma',      'kunal.verma@example.com',      '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq'),
-('Aditya Kulkarni',  'aditya.kulkarni@example.com',  '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq'),
-('Siddharth Patil',  'siddharth.patil@example.com',  '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq'),
-('Neha Iyer',        'neha.iyer@example.com',        '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq'),
-('Priya Nair',       'priya.nair@example.com',       '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq'),
-('Ankit Gupta',      'ankit.gupta@example.com',      '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq'),
-('Rahul Choudhary',  'rahul.choudhary@example.com',  '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq'),
-('Vikram Deshpande', 'vikram.deshpande@example.com', '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq');
+INSERT INTO users (name, email, password, email_verified_at) VALUES
+('Aarav Sharma',     'aarav.sharma@example.com',     '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq', now()),
+('Rohan Mehta',      'rohan.mehta@example.com',      '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq', now()),
+('Kunal Verma',      'kunal.verma@example.com',      '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq', now()),
+('Aditya Kulkarni',  'aditya.kulkarni@example.com',  '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq', now()),
+('Siddharth Patil',  'siddharth.patil@example.com',  '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq', now()),
+('Neha Iyer',        'neha.iyer@example.com',        '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq', now()),
+('Priya Nair',       'priya.nair@example.com',       '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq', now()),
+('Ankit Gupta',      'ankit.gupta@example.com',      '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq', now()),
+('Rahul Choudhary',  'rahul.choudhary@example.com',  '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq', now()),
+('Vikram Deshpande', 'vikram.deshpande@example.com', '$2a$10$jRO72aBApehEXdaM999Rp.E9aMWBW2erSY5MxZ/zh2/jsOdMi/Emq', now());
 -- Password for all users is '12345678'
\ No newline at end of file
@@ -5,23 +5,83 @@ import (
 
 	"stock-reward-api/controllers"
 	"stock-reward-api/middleware"
+	"stock-reward-api/models"
 )
 
 func RegisterRoutes(router *gin.Engine) {
 
+	// Users may only read their own data; support staff and admins may read
+	// anyone's.
+	self := middleware.RequireSelfOrRoles("userId", models.RoleSupport, models.RoleAdmin)
+
+	// Routes backend services may also call with an API key holding the
+	// route's scope.
+	services := router.Group("/api/stocks")
+	{
+		services.Use(middleware.AuthOrAPIKey(), middleware.RequireMFA())
+
+		portfolioRead := middleware.RequireScopeOr(models.ScopePortfolioRead, self)
+
+		services.POST("/reward", middleware.RequireScopeOr(models.ScopeRewardsWrite, middleware.RequireRoles(models.RoleIssuer, models.RoleAdmin)), controllers.CreateReward)
+
+		services.GET("/today-stocks/:userId", portfolioRead, controllers.GetTodayStocks)
+
+		services.GET("/historical-inr/:userId", portfolioRead, controllers.GetHistoricalINR)
+
+		services.GET("/stats/:userId", portfolioRead, controllers.GetUserStats)
+
+		services.GET("/portfolio/:userId", portfolioRead, controllers.GetPortfolio)
+
+		services.GET("/portfolio/:userId/performance", portfolioRead, controllers.GetPortfolioPerformance)
+
+		services.GET("/portfolio/:userId/allocation", portfolioRead, controllers.GetPortfolioAllocation)
+
+		services.GET("/portfolio/:userId/statement", portfolioRead, controllers.GetPortfolioStatement)
+	}
+
 	api := router.Group("/api/stocks")
 	{
-		api.Use(middleware.AuthMiddleware())
+		api.Use(middleware.AuthMiddleware(), middleware.RequireMFA())
+
+		api.POST("/sell", controllers.CreateSellOrder)
+
+		api.GET("/orders/:userId", self, controllers.ListSellOrders)
+
+		api.POST("/sell/:id/cancel", controllers.CancelSellOrder)
 
-		api.POST("/reward", controllers.CreateReward)
+		api.GET("/wallet/:userId", self, controllers.GetWallet)
 
-		api.GET("/today-stocks/:userId", controllers.GetTodayStocks)
+		api.POST("/bank-accounts", controllers.AddBankAccount)
 
-		api.GET("/historical-inr/:userId", controllers.GetHistoricalINR)
+		api.GET("/bank-accounts/:userId", self, controllers.ListBankAccounts)
 
-		api.GET("/stats/:userId", controllers.GetUserStats)
+		api.POST("/withdrawals", controllers.CreateWithdrawal)
 
-		api.GET("/portfolio/:userId", controllers.GetPortfolio) 
+		api.GET("/withdrawals/:userId", self, controllers.ListWithdrawals)
+
+		api.POST("/transfers", controllers.CreateShareTransfer)
+
+		api.GET("/transfers/:userId", self, controllers.ListShareTransfers)
+
+		api.POST("/transfers/:id/accept", controllers.AcceptShareTransfer)
+
+		api.POST("/transfers/:id/decline", controllers.DeclineShareTransfer)
+
+		api.POST("/transfers/:id/cancel", controllers.CancelShareTransfer)
+
+		api.POST("/kyc", controllers.SubmitKYC)
+
+		api.GET("/kyc/:userId", self, controllers.GetKYCProfile)
+
+		api.GET("/dividends/:userId", self, controllers.GetUserDividends)
+
+		api.GET("/tax-statement/:userId", self, controllers.GetTaxStatement)
+
+		api.GET("/ledger/:userId", self, controllers.GetUserLedger)
+
+		api.GET("/symbols/:symbol", controllers.GetSymbolLineage)
+
+		api.GET("/price-cache/stats", controllers.GetPriceCacheStats)
 	}
 }
 
@@ -30,5 +90,88 @@ func RegisterUserRoutes(router *gin.Engine) {
 	{
 		userRoutes.POST("/register", controllers.RegisterUser)
 		userRoutes.POST("/login", controllers.LoginUser)
+		userRoutes.POST("/login/mfa", controllers.CompleteMFALogin)
+		userRoutes.POST("/refresh", controllers.RefreshToken)
+		userRoutes.POST("/logout", middleware.AuthMiddleware(), controllers.Logout)
+		userRoutes.POST("/forgot-password", controllers.ForgotPassword)
+		userRoutes.POST("/reset-password", controllers.ResetPassword)
+		userRoutes.POST("/change-password", middleware.AuthMiddleware(), controllers.ChangePassword)
+		userRoutes.GET("/verify-email", controllers.VerifyEmail)
+		userRoutes.POST("/resend-verification", controllers.ResendVerification)
+
+		// Reachable before two-factor authentication is set up, so users
+		// whose role requires it can enroll.
+		mfa := userRoutes.Group("/mfa", middleware.AuthMiddleware())
+		mfa.GET("", controllers.GetMFAStatus)
+		mfa.POST("/enroll", controllers.EnrollMFA)
+		mfa.POST("/confirm", controllers.ConfirmMFA)
+		mfa.POST("/disable", controllers.DisableMFA)
+		mfa.POST("/recovery-codes", controllers.RegenerateRecoveryCodes)
 	}
-}
\ No newline at end of file
+}
+
+func RegisterAdminRoutes(router *gin.Engine) {
+	admin := router.Group("/api/admin")
+	{
+		// Support staff may read; only admins may change anything.
+		admin.Use(middleware.AuthMiddleware(), middleware.RequireMFA(), middleware.RequireRoles(models.RoleAdmin, models.RoleSupport))
+		adminOnly := middleware.RequireRoles(models.RoleAdmin)
+
+		admin.POST("/corporate-actions", adminOnly, controllers.CreateCorporateAction)
+
+		admin.GET("/corporate-actions", controllers.ListCorporateActions)
+
+		admin.POST("/corporate-actions/:id/apply", adminOnly, controllers.ApplyCorporateAction)
+
+		admin.POST("/dividends", adminOnly, controllers.DeclareDividend)
+
+		admin.GET("/dividends", controllers.ListDividends)
+
+		admin.GET("/reward-liabilities", controllers.GetRewardLiabilities)
+
+		admin.GET("/kyc", controllers.ListKYCSubmissions)
+
+		admin.POST("/kyc/:userId/approve", adminOnly, controllers.ApproveKYC)
+
+		admin.POST("/kyc/:userId/reject", adminOnly, controllers.RejectKYC)
+
+		admin.POST("/kyc/:userId/release", adminOnly, controllers.ReleaseHeldRewards)
+
+		admin.PUT("/users/:userId/role", adminOnly, controllers.SetUserRole)
+
+		admin.POST("/users/:userId/unlock", adminOnly, controllers.UnlockUser)
+
+		admin.GET("/security-events", controllers.ListSecurityEvents)
+
+		admin.GET("/audit-log", controllers.SearchAuditLog)
+
+		admin.GET("/audit-log/export", controllers.ExportAuditLog)
+
+		admin.GET("/mfa-policy", controllers.GetMFAPolicy)
+
+		admin.PUT("/mfa-policy", adminOnly, controllers.SetMFAPolicy)
+
+		admin.POST("/api-keys", adminOnly, controllers.CreateAPIKey)
+
+		admin.GET("/api-keys", controllers.ListAPIKeys)
+
+		admin.POST("/api-keys/:id/revoke", adminOnly, controllers.RevokeAPIKey)
+
+		admin.GET("/api-keys/:id/usage", controllers.GetAPIKeyUsage)
+	}
+}
+
+// RegisterPaymentRoutes registers gateway callbacks, which authenticate with
+// a request signature instead of a user token.
+func RegisterPaymentRoutes(router *gin.Engine) {
+	payments := router.Group("/api/payments")
+	{
+		payments.POST("/callback", controllers.PaymentCallback)
+	}
+}
+
+// RegisterWellKnownRoutes publishes the keys other services verify our
+// tokens with.
+func RegisterWellKnownRoutes(router *gin.Engine) {
+	router.GET("/.well-known/jwks.json", controllers.GetJWKS)
+}
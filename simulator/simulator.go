@@ -0,0 +1,231 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SymbolConfig describes the price process of one symbol. Drift, volatility
+// and jump intensity are annualised.
+type SymbolConfig struct {
+	StartPrice    float64 `json:"start_price"`
+	Drift         float64 `json:"drift"`
+	Volatility    float64 `json:"volatility"`
+	JumpIntensity float64 `json:"jump_intensity"`
+	JumpMean      float64 `json:"jump_mean"`
+	JumpStdDev    float64 `json:"jump_stddev"`
+}
+
+type Config struct {
+	Seed               int64                   `json:"seed"`
+	TradingDaysPerYear float64                 `json:"trading_days_per_year"`
+	SessionMinutes     float64                 `json:"session_minutes"`
+	TimeScale          float64                 `json:"time_scale"`
+	Defaults           SymbolConfig            `json:"defaults"`
+	Symbols            map[string]SymbolConfig `json:"symbols"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Seed:               1,
+		TradingDaysPerYear: 252,
+		SessionMinutes:     375,
+		TimeScale:          1,
+		Defaults: SymbolConfig{
+			Drift:      0.08,
+			Volatility: 0.25,
+		},
+	}
+}
+
+func Load(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("read simulator config %s: %w", path, err)
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse simulator config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+type symbolState struct {
+	cfg   SymbolConfig
+	price float64
+	seed  int64
+	steps int64
+}
+
+// Simulator evolves prices with geometric Brownian motion plus an optional
+// Merton jump component. Every step of a symbol draws from its own generator
+// derived from the seed, the symbol name and the number of steps taken, so a
+// run is reproducible regardless of which other symbols are simulated
+// alongside it, and a restarted run carries on along the same path.
+type Simulator struct {
+	mu      sync.Mutex
+	cfg     Config
+	symbols map[string]*symbolState
+}
+
+func New(cfg Config) *Simulator {
+	if cfg.TradingDaysPerYear <= 0 {
+		cfg.TradingDaysPerYear = 252
+	}
+	if cfg.SessionMinutes <= 0 {
+		cfg.SessionMinutes = 375
+	}
+	if cfg.TimeScale <= 0 {
+		cfg.TimeScale = 1
+	}
+	return &Simulator{cfg: cfg, symbols: make(map[string]*symbolState)}
+}
+
+// Add registers symbol with the simulator after steps earlier steps. A symbol
+// that has not been stepped yet starts from its configured start price; one
+// that has continues from lastPrice, the stored price, which already reflects
+// corporate actions. Without a start price, lastPrice is used either way. It
+// returns the price the symbol starts from.
+func (s *Simulator) Add(symbol string, lastPrice float64, steps int64) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg := s.symbolConfig(symbol)
+	price := lastPrice
+	if cfg.StartPrice > 0 && (steps == 0 || price <= 0) {
+		price = cfg.StartPrice
+	}
+
+	s.symbols[symbol] = &symbolState{
+		cfg:   cfg,
+		price: price,
+		seed:  s.symbolSeed(symbol),
+		steps: steps,
+	}
+	return price
+}
+
+func (s *Simulator) symbolConfig(symbol string) SymbolConfig {
+	if cfg, ok := s.cfg.Symbols[symbol]; ok {
+		return cfg
+	}
+	return s.cfg.Defaults
+}
+
+func (s *Simulator) symbolSeed(symbol string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(symbol))
+	return s.cfg.Seed ^ int64(h.Sum64())
+}
+
+// stepSeed mixes the step number into a symbol's seed with the SplitMix64
+// finaliser, so neighbouring steps get unrelated generators.
+func stepSeed(seed, step int64) int64 {
+	z := uint64(seed) + uint64(step+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
+
+// Step advances every symbol by one interval of trading time and returns the
+// new prices.
+func (s *Simulator) Step(interval time.Duration) map[string]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	yearSeconds := s.cfg.TradingDaysPerYear * s.cfg.SessionMinutes * 60
+	dt := interval.Seconds() * s.cfg.TimeScale / yearSeconds
+
+	names := make([]string, 0, len(s.symbols))
+	for name := range s.symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make(map[string]float64, len(names))
+	for _, name := range names {
+		st := s.symbols[name]
+		st.price = st.next(dt)
+		out[name] = st.price
+	}
+	return out
+}
+
+func (st *symbolState) next(dt float64) float64 {
+	rng := rand.New(rand.NewSource(stepSeed(st.seed, st.steps)))
+	st.steps++
+
+	c := st.cfg
+	sigma := c.Volatility
+
+	// Compensate the drift for the expected jump so Drift stays the
+	// expected annual return.
+	k := 0.0
+	if c.JumpIntensity > 0 {
+		k = math.Exp(c.JumpMean+c.JumpStdDev*c.JumpStdDev/2) - 1
+	}
+
+	logReturn := (c.Drift-sigma*sigma/2-c.JumpIntensity*k)*dt + sigma*math.Sqrt(dt)*rng.NormFloat64()
+
+	if c.JumpIntensity > 0 {
+		for n := poisson(rng, c.JumpIntensity*dt); n > 0; n-- {
+			logReturn += c.JumpMean + c.JumpStdDev*rng.NormFloat64()
+		}
+	}
+
+	price := st.price * math.Exp(logReturn)
+	return math.Round(price*100) / 100
+}
+
+func poisson(rng *rand.Rand, lambda float64) int {
+	l := math.Exp(-lambda)
+	n := 0
+	for p := rng.Float64(); p > l; p *= rng.Float64() {
+		n++
+	}
+	return n
+}
+
+// Scale multiplies the current price of symbol by factor, e.g. to apply a
+// stock split to the simulated series.
+func (s *Simulator) Scale(symbol string, factor float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st, ok := s.symbols[symbol]; ok {
+		st.price = math.Round(st.price*factor*100) / 100
+	}
+}
+
+// Rename moves the state of symbol to newSymbol so the series continues
+// under the new name, with the new name's parameters and generators as it
+// would have after a restart.
+func (s *Simulator) Rename(symbol, newSymbol string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st, ok := s.symbols[symbol]; ok {
+		delete(s.symbols, symbol)
+		if _, exists := s.symbols[newSymbol]; !exists {
+			st.cfg = s.symbolConfig(newSymbol)
+			st.seed = s.symbolSeed(newSymbol)
+			s.symbols[newSymbol] = st
+		}
+	}
+}
+
+// Remove stops simulating symbol.
+func (s *Simulator) Remove(symbol string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.symbols, symbol)
+}
@@ -0,0 +1,104 @@
+package simulator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testConfig(seed int64) Config {
+	cfg := DefaultConfig()
+	cfg.Seed = seed
+	cfg.Symbols = map[string]SymbolConfig{
+		"AAA": {StartPrice: 100, Drift: 0.1, Volatility: 0.3},
+		"BBB": {StartPrice: 250, Drift: 0.05, Volatility: 0.5, JumpIntensity: 20, JumpStdDev: 0.05},
+	}
+	return cfg
+}
+
+// path steps sim n times and returns the prices of every step.
+func path(sim *Simulator, n int) []map[string]float64 {
+	out := make([]map[string]float64, n)
+	for i := range out {
+		out[i] = sim.Step(time.Minute)
+	}
+	return out
+}
+
+func newSim(seed int64, steps int64, last map[string]float64, symbols ...string) *Simulator {
+	sim := New(testConfig(seed))
+	for _, symbol := range symbols {
+		sim.Add(symbol, last[symbol], steps)
+	}
+	return sim
+}
+
+func TestSameSeedSamePath(t *testing.T) {
+	a := path(newSim(7, 0, nil, "AAA", "BBB"), 50)
+	b := path(newSim(7, 0, nil, "AAA", "BBB"), 50)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("same seed gave different price paths")
+	}
+
+	c := path(newSim(8, 0, nil, "AAA", "BBB"), 50)
+	if reflect.DeepEqual(a, c) {
+		t.Fatal("different seeds gave the same price path")
+	}
+
+	// A symbol's path does not depend on the other symbols simulated.
+	alone := path(newSim(7, 0, nil, "AAA"), 50)
+	for i := range alone {
+		if alone[i]["AAA"] != a[i]["AAA"] {
+			t.Fatalf("step %d: AAA = %v alone, %v alongside BBB", i, alone[i]["AAA"], a[i]["AAA"])
+		}
+	}
+}
+
+func TestRestartContinuesPath(t *testing.T) {
+	full := path(newSim(7, 0, nil, "AAA", "BBB"), 40)
+
+	first := path(newSim(7, 0, nil, "AAA", "BBB"), 25)
+	resumed := path(newSim(7, 25, first[24], "AAA", "BBB"), 15)
+
+	if !reflect.DeepEqual(append(first, resumed...), full) {
+		t.Fatal("restarted run left the uninterrupted price path")
+	}
+}
+
+func TestAddStartPrice(t *testing.T) {
+	tests := []struct {
+		name      string
+		symbol    string
+		lastPrice float64
+		steps     int64
+		want      float64
+	}{
+		{"fresh symbol uses start price", "AAA", 42, 0, 100},
+		{"stepped symbol keeps stored price", "AAA", 42, 3, 42},
+		{"stepped symbol without stored price", "AAA", 0, 3, 100},
+		{"no start price configured", "CCC", 42, 0, 42},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := New(testConfig(1))
+			if got := sim.Add(tt.symbol, tt.lastPrice, tt.steps); got != tt.want {
+				t.Errorf("Add = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameMatchesRestart(t *testing.T) {
+	sim := newSim(7, 0, nil, "AAA")
+	before := path(sim, 10)
+	sim.Rename("AAA", "ZZZ")
+	renamed := path(sim, 10)
+
+	// After a restart ZZZ resumes from its stored price, counting the ticks
+	// it had as AAA.
+	restarted := New(testConfig(7))
+	restarted.Add("ZZZ", before[9]["AAA"], 10)
+	if got := path(restarted, 10); !reflect.DeepEqual(got, renamed) {
+		t.Fatal("renamed symbol left the path it follows after a restart")
+	}
+}
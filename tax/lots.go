@@ -0,0 +1,233 @@
+package tax
+
+import (
+	"sort"
+	"time"
+)
+
+// EventKind identifies how an Event changes a user's lots.
+type EventKind int
+
+const (
+	// Acquisition adds a lot of Quantity shares costing AmountINR.
+	Acquisition EventKind = iota
+	// Disposal transfers Quantity shares for AmountINR of proceeds.
+	Disposal
+	// Split adds Quantity shares spread over the existing lots, keeping their
+	// acquisition dates and cost.
+	Split
+	// Bonus adds a new lot of Quantity shares with zero cost, acquired on the
+	// allotment date.
+	Bonus
+	// Conversion moves every lot of Symbol to NewSymbol in exchange for
+	// NewQuantity shares. CarriedINR of the cost moves with them and CashINR
+	// is paid for the remainder, which is realized as a disposal.
+	Conversion
+	// Gift removes Quantity shares from the oldest lots without realizing a
+	// gain, as a gift is not a transfer for consideration.
+	Gift
+)
+
+// Event is one movement of a user's shares, in the order it happened.
+type Event struct {
+	Kind        EventKind
+	At          time.Time
+	Symbol      string
+	Quantity    float64
+	AmountINR   float64
+	NewSymbol   string
+	NewQuantity float64
+	CarriedINR  float64
+	CashINR     float64
+	Reference   string
+}
+
+// Lot is a block of shares acquired together.
+type Lot struct {
+	Symbol     string    `json:"stock_symbol"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	Quantity   float64   `json:"quantity"`
+	CostINR    float64   `json:"cost_inr"`
+}
+
+// Realization is the gain on the part of one lot transferred by a disposal.
+type Realization struct {
+	Symbol      string    `json:"stock_symbol"`
+	AcquiredAt  time.Time `json:"acquired_at"`
+	DisposedAt  time.Time `json:"disposed_at"`
+	Quantity    float64   `json:"quantity"`
+	CostINR     float64   `json:"cost_inr"`
+	ProceedsINR float64   `json:"proceeds_inr"`
+	GainINR     float64   `json:"gain_inr"`
+	HoldingDays int       `json:"holding_days"`
+	LongTerm    bool      `json:"long_term"`
+	Rate        float64   `json:"rate"`
+	Reference   string    `json:"reference,omitempty"`
+}
+
+const epsilon = 1e-9
+
+// Realize replays events in time order against FIFO lots and returns every
+// realized gain along with the lots still held. Disposals of more shares
+// than are held are matched against a zero-cost lot acquired on the day of
+// disposal.
+func Realize(events []Event) ([]Realization, []Lot) {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+
+	lots := make(map[string][]Lot)
+	var realized []Realization
+
+	for _, e := range events {
+		switch e.Kind {
+		case Acquisition:
+			lots[e.Symbol] = append(lots[e.Symbol], Lot{Symbol: e.Symbol, AcquiredAt: e.At, Quantity: e.Quantity, CostINR: e.AmountINR})
+
+		case Bonus:
+			lots[e.Symbol] = append(lots[e.Symbol], Lot{Symbol: e.Symbol, AcquiredAt: e.At, Quantity: e.Quantity})
+
+		case Split:
+			held := quantityOf(lots[e.Symbol])
+			if held <= epsilon {
+				continue
+			}
+			factor := 1 + e.Quantity/held
+			for i := range lots[e.Symbol] {
+				lots[e.Symbol][i].Quantity *= factor
+			}
+
+		case Disposal:
+			var taken []Lot
+			lots[e.Symbol], taken = take(lots[e.Symbol], e.Quantity)
+			if short := e.Quantity - quantityOf(taken); short > epsilon {
+				taken = append(taken, Lot{Symbol: e.Symbol, AcquiredAt: e.At, Quantity: short})
+			}
+			realized = append(realized, realize(taken, e.At, e.AmountINR, e.Quantity, e.Reference)...)
+
+		case Gift:
+			lots[e.Symbol], _ = take(lots[e.Symbol], e.Quantity)
+
+		case Conversion:
+			held := lots[e.Symbol]
+			delete(lots, e.Symbol)
+			qty, cost := quantityOf(held), costOf(held)
+			if qty <= epsilon {
+				continue
+			}
+
+			carried := 1.0
+			if cost > epsilon {
+				carried = e.CarriedINR / cost
+			}
+			if e.CashINR > 0 {
+				var paidOut []Lot
+				for _, l := range held {
+					l.Quantity *= 1 - carried
+					l.CostINR *= 1 - carried
+					paidOut = append(paidOut, l)
+				}
+				realized = append(realized, realize(paidOut, e.At, e.CashINR, quantityOf(paidOut), e.Reference)...)
+			}
+
+			ratio := e.NewQuantity / qty
+			for _, l := range held {
+				l.Symbol = e.NewSymbol
+				l.Quantity *= ratio
+				l.CostINR *= carried
+				lots[e.NewSymbol] = append(lots[e.NewSymbol], l)
+			}
+			// Lots already held in the new symbol keep FIFO order by date.
+			sort.SliceStable(lots[e.NewSymbol], func(i, j int) bool {
+				return lots[e.NewSymbol][i].AcquiredAt.Before(lots[e.NewSymbol][j].AcquiredAt)
+			})
+		}
+	}
+
+	var open []Lot
+	for _, held := range lots {
+		for _, l := range held {
+			if l.Quantity > epsilon {
+				open = append(open, l)
+			}
+		}
+	}
+	sort.Slice(open, func(i, j int) bool {
+		if open[i].Symbol != open[j].Symbol {
+			return open[i].Symbol < open[j].Symbol
+		}
+		return open[i].AcquiredAt.Before(open[j].AcquiredAt)
+	})
+	return realized, open
+}
+
+// take removes quantity shares from the front of lots, splitting the last
+// lot touched, and returns the remaining and removed lots.
+func take(lots []Lot, quantity float64) (remaining, taken []Lot) {
+	for len(lots) > 0 && quantity > epsilon {
+		l := lots[0]
+		if l.Quantity <= quantity+epsilon {
+			taken = append(taken, l)
+			quantity -= l.Quantity
+			lots = lots[1:]
+			continue
+		}
+		part := l
+		part.Quantity = quantity
+		part.CostINR = l.CostINR * quantity / l.Quantity
+		taken = append(taken, part)
+
+		lots[0].Quantity -= quantity
+		lots[0].CostINR -= part.CostINR
+		quantity = 0
+	}
+	return lots, taken
+}
+
+// realize splits proceeds for quantity shares over the lots they came from.
+func realize(taken []Lot, at time.Time, proceeds, quantity float64, ref string) []Realization {
+	var out []Realization
+	for _, l := range taken {
+		if l.Quantity <= epsilon {
+			continue
+		}
+		share := proceeds
+		if quantity > epsilon {
+			share = proceeds * l.Quantity / quantity
+		}
+		long := IsLongTerm(l.AcquiredAt, at)
+		rules := RulesAt(at)
+		rate := rules.STCGRate
+		if long {
+			rate = rules.LTCGRate
+		}
+		out = append(out, Realization{
+			Symbol:      l.Symbol,
+			AcquiredAt:  l.AcquiredAt,
+			DisposedAt:  at,
+			Quantity:    l.Quantity,
+			CostINR:     l.CostINR,
+			ProceedsINR: share,
+			GainINR:     share - l.CostINR,
+			HoldingDays: int(at.Sub(l.AcquiredAt).Hours() / 24),
+			LongTerm:    long,
+			Rate:        rate,
+			Reference:   ref,
+		})
+	}
+	return out
+}
+
+func quantityOf(lots []Lot) float64 {
+	var q float64
+	for _, l := range lots {
+		q += l.Quantity
+	}
+	return q
+}
+
+func costOf(lots []Lot) float64 {
+	var c float64
+	for _, l := range lots {
+		c += l.CostINR
+	}
+	return c
+}
@@ -0,0 +1,137 @@
+package tax
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestRealizeFIFOAcrossSplits(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []Event
+		realized []Realization
+		open     []Lot
+	}{
+		{
+			name: "split scales every lot before FIFO matching",
+			events: []Event{
+				{Kind: Acquisition, At: day("2023-01-10"), Symbol: "TCS", Quantity: 10, AmountINR: 1000},
+				{Kind: Acquisition, At: day("2023-06-01"), Symbol: "TCS", Quantity: 10, AmountINR: 2000},
+				{Kind: Split, At: day("2025-01-01"), Symbol: "TCS", Quantity: 20},
+				{Kind: Disposal, At: day("2025-02-01"), Symbol: "TCS", Quantity: 30, AmountINR: 3000},
+			},
+			realized: []Realization{
+				{Symbol: "TCS", AcquiredAt: day("2023-01-10"), Quantity: 20, CostINR: 1000, ProceedsINR: 2000, GainINR: 1000, LongTerm: true, Rate: 0.125},
+				{Symbol: "TCS", AcquiredAt: day("2023-06-01"), Quantity: 10, CostINR: 1000, ProceedsINR: 1000, GainINR: 0, LongTerm: true, Rate: 0.125},
+			},
+			open: []Lot{
+				{Symbol: "TCS", AcquiredAt: day("2023-06-01"), Quantity: 10, CostINR: 1000},
+			},
+		},
+		{
+			name: "split keeps acquisition date for the holding period",
+			events: []Event{
+				{Kind: Acquisition, At: day("2024-10-01"), Symbol: "INFY", Quantity: 10, AmountINR: 500},
+				{Kind: Split, At: day("2024-12-01"), Symbol: "INFY", Quantity: 10},
+				{Kind: Disposal, At: day("2025-01-15"), Symbol: "INFY", Quantity: 5, AmountINR: 400},
+			},
+			realized: []Realization{
+				{Symbol: "INFY", AcquiredAt: day("2024-10-01"), Quantity: 5, CostINR: 125, ProceedsINR: 400, GainINR: 275, LongTerm: false, Rate: 0.20},
+			},
+			open: []Lot{
+				{Symbol: "INFY", AcquiredAt: day("2024-10-01"), Quantity: 15, CostINR: 375},
+			},
+		},
+		{
+			name: "events are replayed in time order",
+			events: []Event{
+				{Kind: Disposal, At: day("2025-03-01"), Symbol: "TCS", Quantity: 4, AmountINR: 800},
+				{Kind: Split, At: day("2025-02-01"), Symbol: "TCS", Quantity: 2},
+				{Kind: Acquisition, At: day("2025-01-01"), Symbol: "TCS", Quantity: 2, AmountINR: 400},
+			},
+			realized: []Realization{
+				{Symbol: "TCS", AcquiredAt: day("2025-01-01"), Quantity: 4, CostINR: 400, ProceedsINR: 800, GainINR: 400, Rate: 0.20},
+			},
+		},
+		{
+			name: "split with nothing held is ignored",
+			events: []Event{
+				{Kind: Split, At: day("2025-01-01"), Symbol: "TCS", Quantity: 10},
+				{Kind: Acquisition, At: day("2025-02-01"), Symbol: "TCS", Quantity: 5, AmountINR: 500},
+			},
+			open: []Lot{
+				{Symbol: "TCS", AcquiredAt: day("2025-02-01"), Quantity: 5, CostINR: 500},
+			},
+		},
+		{
+			name: "bonus lot after a split is matched last at zero cost",
+			events: []Event{
+				{Kind: Acquisition, At: day("2024-01-01"), Symbol: "TCS", Quantity: 5, AmountINR: 1000},
+				{Kind: Split, At: day("2024-06-01"), Symbol: "TCS", Quantity: 5},
+				{Kind: Bonus, At: day("2024-09-01"), Symbol: "TCS", Quantity: 10},
+				{Kind: Disposal, At: day("2024-10-01"), Symbol: "TCS", Quantity: 15, AmountINR: 1500},
+			},
+			realized: []Realization{
+				{Symbol: "TCS", AcquiredAt: day("2024-01-01"), Quantity: 10, CostINR: 1000, ProceedsINR: 1000, GainINR: 0, Rate: 0.20},
+				{Symbol: "TCS", AcquiredAt: day("2024-09-01"), Quantity: 5, CostINR: 0, ProceedsINR: 500, GainINR: 500, Rate: 0.20},
+			},
+			open: []Lot{
+				{Symbol: "TCS", AcquiredAt: day("2024-09-01"), Quantity: 5},
+			},
+		},
+		{
+			name: "disposal beyond the split-adjusted holding uses a zero-cost lot",
+			events: []Event{
+				{Kind: Acquisition, At: day("2025-01-01"), Symbol: "TCS", Quantity: 1, AmountINR: 100},
+				{Kind: Split, At: day("2025-02-01"), Symbol: "TCS", Quantity: 1},
+				{Kind: Disposal, At: day("2025-03-01"), Symbol: "TCS", Quantity: 3, AmountINR: 300},
+			},
+			realized: []Realization{
+				{Symbol: "TCS", AcquiredAt: day("2025-01-01"), Quantity: 2, CostINR: 100, ProceedsINR: 200, GainINR: 100, Rate: 0.20},
+				{Symbol: "TCS", AcquiredAt: day("2025-03-01"), Quantity: 1, CostINR: 0, ProceedsINR: 100, GainINR: 100, Rate: 0.20},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			realized, open := Realize(tt.events)
+
+			if len(realized) != len(tt.realized) {
+				t.Fatalf("got %d realizations, want %d: %+v", len(realized), len(tt.realized), realized)
+			}
+			for i, want := range tt.realized {
+				got := realized[i]
+				if got.Symbol != want.Symbol || !got.AcquiredAt.Equal(want.AcquiredAt) || got.LongTerm != want.LongTerm ||
+					!closeTo(got.Quantity, want.Quantity) || !closeTo(got.CostINR, want.CostINR) ||
+					!closeTo(got.ProceedsINR, want.ProceedsINR) || !closeTo(got.GainINR, want.GainINR) || !closeTo(got.Rate, want.Rate) {
+					t.Errorf("realization %d = %+v, want %+v", i, got, want)
+				}
+			}
+
+			if len(open) != len(tt.open) {
+				t.Fatalf("got %d open lots, want %d: %+v", len(open), len(tt.open), open)
+			}
+			for i, want := range tt.open {
+				got := open[i]
+				if got.Symbol != want.Symbol || !got.AcquiredAt.Equal(want.AcquiredAt) ||
+					!closeTo(got.Quantity, want.Quantity) || !closeTo(got.CostINR, want.CostINR) {
+					t.Errorf("open lot %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package tax
+
+import "time"
+
+// Rules are the capital gains rules for listed equity shares in force from
+// EffectiveFrom until the next entry in Schedule. Rates apply by the date of
+// transfer; the long-term exemption is an annual allowance and is taken from
+// the rules in force at the end of the financial year.
+type Rules struct {
+	EffectiveFrom time.Time `json:"effective_from"`
+	// STCGRate applies to gains on shares held for LongTermMonths or less
+	// (section 111A).
+	STCGRate float64 `json:"stcg_rate"`
+	// LTCGRate applies to long-term gains above LTCGExemption (section 112A).
+	LTCGRate       float64 `json:"ltcg_rate"`
+	LTCGExemption  float64 `json:"ltcg_exemption"`
+	LongTermMonths int     `json:"long_term_months"`
+}
+
+// Schedule lists every rule change in effective-date order. Add an entry
+// when the Finance Act changes rates; earlier entries must stay so that
+// statements for past years keep their original treatment.
+var Schedule = []Rules{
+	{
+		EffectiveFrom:  time.Date(2018, time.April, 1, 0, 0, 0, 0, time.UTC),
+		STCGRate:       0.15,
+		LTCGRate:       0.10,
+		LTCGExemption:  100000,
+		LongTermMonths: 12,
+	},
+	{
+		// Finance (No. 2) Act, 2024.
+		EffectiveFrom:  time.Date(2024, time.July, 23, 0, 0, 0, 0, time.UTC),
+		STCGRate:       0.20,
+		LTCGRate:       0.125,
+		LTCGExemption:  125000,
+		LongTermMonths: 12,
+	},
+}
+
+// RulesAt returns the rules in force on the calendar date of t.
+func RulesAt(t time.Time) Rules {
+	d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	rules := Schedule[0]
+	for _, r := range Schedule {
+		if d.Before(r.EffectiveFrom) {
+			break
+		}
+		rules = r
+	}
+	return rules
+}
+
+// IsLongTerm reports whether shares acquired at acquired and transferred at
+// disposed were held for more than the long-term period.
+func IsLongTerm(acquired, disposed time.Time) bool {
+	return disposed.After(acquired.AddDate(0, RulesAt(disposed).LongTermMonths, 0))
+}
@@ -0,0 +1,245 @@
+package tax
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Perquisite is the income value of a reward at grant: the shares granted
+// valued at the grant price.
+type Perquisite struct {
+	RewardID   string    `json:"reward_id"`
+	Symbol     string    `json:"stock_symbol"`
+	GrantedAt  time.Time `json:"granted_at"`
+	Quantity   float64   `json:"quantity"`
+	GrantPrice float64   `json:"grant_price"`
+	ValueINR   float64   `json:"value_inr"`
+}
+
+// DividendIncome is one dividend paid to the user.
+type DividendIncome struct {
+	Symbol   string    `json:"stock_symbol"`
+	PaidOn   time.Time `json:"paid_on"`
+	Shares   float64   `json:"shares"`
+	GrossINR float64   `json:"gross_inr"`
+	TDSINR   float64   `json:"tds_inr"`
+	NetINR   float64   `json:"net_inr"`
+}
+
+// Summary totals a statement and estimates the tax on capital gains after
+// set-off of losses and the long-term exemption. It does not account for
+// losses carried forward from earlier years or the user's slab rate, which
+// applies to perquisite and dividend income.
+type Summary struct {
+	PerquisiteINR     float64 `json:"perquisite_inr"`
+	ShortTermGainINR  float64 `json:"short_term_gain_inr"`
+	LongTermGainINR   float64 `json:"long_term_gain_inr"`
+	LongTermExemptINR float64 `json:"long_term_exempt_inr"`
+	EstimatedTaxINR   float64 `json:"estimated_capital_gains_tax_inr"`
+	DividendGrossINR  float64 `json:"dividend_gross_inr"`
+	DividendTDSINR    float64 `json:"dividend_tds_inr"`
+	DividendNetINR    float64 `json:"dividend_net_inr"`
+}
+
+// Statement is a user's tax statement for one financial year.
+type Statement struct {
+	UserID       int64            `json:"user_id"`
+	Year         FinancialYear    `json:"financial_year"`
+	Rules        []Rules          `json:"rules"`
+	Perquisites  []Perquisite     `json:"perquisites"`
+	CapitalGains []Realization    `json:"capital_gains"`
+	Dividends    []DividendIncome `json:"dividends"`
+	Summary      Summary          `json:"summary"`
+}
+
+// BuildStatement assembles the statement for fy from a user's full history,
+// keeping only items that fall within the year.
+func BuildStatement(userID int64, fy FinancialYear, perquisites []Perquisite, realized []Realization, dividends []DividendIncome) Statement {
+	s := Statement{
+		UserID:       userID,
+		Year:         fy,
+		Perquisites:  []Perquisite{},
+		CapitalGains: []Realization{},
+		Dividends:    []DividendIncome{},
+	}
+
+	for _, r := range Schedule {
+		if r.EffectiveFrom.Before(fy.End) {
+			s.Rules = append(s.Rules, r)
+		}
+	}
+	for i := len(s.Rules) - 1; i > 0; i-- {
+		if !s.Rules[i].EffectiveFrom.After(fy.Start) {
+			s.Rules = s.Rules[i:]
+			break
+		}
+	}
+
+	for _, p := range perquisites {
+		if fy.Contains(p.GrantedAt) {
+			s.Perquisites = append(s.Perquisites, p)
+			s.Summary.PerquisiteINR += p.ValueINR
+		}
+	}
+	for _, r := range realized {
+		if fy.Contains(r.DisposedAt) {
+			s.CapitalGains = append(s.CapitalGains, r)
+		}
+	}
+	for _, d := range dividends {
+		if fy.Contains(d.PaidOn) {
+			s.Dividends = append(s.Dividends, d)
+			s.Summary.DividendGrossINR += d.GrossINR
+			s.Summary.DividendTDSINR += d.TDSINR
+			s.Summary.DividendNetINR += d.NetINR
+		}
+	}
+
+	s.Summary.ShortTermGainINR, s.Summary.LongTermGainINR, s.Summary.LongTermExemptINR, s.Summary.EstimatedTaxINR =
+		capitalGainsTax(s.CapitalGains, RulesAt(fy.End.AddDate(0, 0, -1)).LTCGExemption)
+
+	s.Summary.PerquisiteINR = round2(s.Summary.PerquisiteINR)
+	s.Summary.DividendGrossINR = round2(s.Summary.DividendGrossINR)
+	s.Summary.DividendTDSINR = round2(s.Summary.DividendTDSINR)
+	s.Summary.DividendNetINR = round2(s.Summary.DividendNetINR)
+	return s
+}
+
+type bucket struct {
+	long bool
+	rate float64
+	gain float64
+}
+
+// capitalGainsTax nets gains per term and rate, sets short-term losses off
+// against any gains and long-term losses against long-term gains, applies
+// the exemption to the most heavily taxed long-term gains, and estimates the
+// tax on what remains.
+func capitalGainsTax(realized []Realization, exemption float64) (short, long, exempt, tax float64) {
+	byKey := make(map[[2]float64]*bucket)
+	var buckets []*bucket
+	for _, r := range realized {
+		term := 0.0
+		if r.LongTerm {
+			term = 1
+			long += r.GainINR
+		} else {
+			short += r.GainINR
+		}
+		key := [2]float64{term, r.Rate}
+		b, ok := byKey[key]
+		if !ok {
+			b = &bucket{long: r.LongTerm, rate: r.Rate}
+			byKey[key] = b
+			buckets = append(buckets, b)
+		}
+		b.gain += r.GainINR
+	}
+	// Highest rate first, so losses and the exemption reduce the most tax.
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].rate > buckets[j].rate })
+
+	setOff := func(loss *bucket, longOnly bool) {
+		for _, b := range buckets {
+			if loss.gain >= 0 {
+				return
+			}
+			if b.gain <= 0 || (longOnly && !b.long) {
+				continue
+			}
+			used := math.Min(b.gain, -loss.gain)
+			b.gain -= used
+			loss.gain += used
+		}
+	}
+	for _, b := range buckets {
+		if b.gain < 0 && !b.long {
+			setOff(b, false)
+		}
+	}
+	for _, b := range buckets {
+		if b.gain < 0 && b.long {
+			setOff(b, true)
+		}
+	}
+
+	remaining := exemption
+	for _, b := range buckets {
+		if !b.long || b.gain <= 0 || remaining <= 0 {
+			continue
+		}
+		used := math.Min(b.gain, remaining)
+		b.gain -= used
+		remaining -= used
+		exempt += used
+	}
+
+	for _, b := range buckets {
+		if b.gain > 0 {
+			tax += b.gain * b.rate
+		}
+	}
+	return round2(short), round2(long), round2(exempt), round2(tax)
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// WriteCSV writes the statement as CSV with one section per schedule,
+// separated by blank lines.
+func WriteCSV(w io.Writer, s Statement) error {
+	cw := csv.NewWriter(w)
+	const date = "2006-01-02"
+	money := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	qty := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+	rows := [][]string{
+		{"Tax statement", s.Year.Label},
+		{"User ID", strconv.FormatInt(s.UserID, 10)},
+		{},
+		{"Perquisites"},
+		{"reward_id", "stock_symbol", "granted_at", "quantity", "grant_price", "value_inr"},
+	}
+	for _, p := range s.Perquisites {
+		rows = append(rows, []string{p.RewardID, p.Symbol, p.GrantedAt.Format(date), qty(p.Quantity), money(p.GrantPrice), money(p.ValueINR)})
+	}
+
+	rows = append(rows, []string{}, []string{"Capital gains"},
+		[]string{"stock_symbol", "acquired_at", "disposed_at", "quantity", "cost_inr", "proceeds_inr", "gain_inr", "holding_days", "term", "rate", "reference"})
+	for _, r := range s.CapitalGains {
+		term := "SHORT"
+		if r.LongTerm {
+			term = "LONG"
+		}
+		rows = append(rows, []string{r.Symbol, r.AcquiredAt.Format(date), r.DisposedAt.Format(date), qty(r.Quantity),
+			money(r.CostINR), money(r.ProceedsINR), money(r.GainINR), strconv.Itoa(r.HoldingDays), term,
+			strconv.FormatFloat(r.Rate, 'f', -1, 64), r.Reference})
+	}
+
+	rows = append(rows, []string{}, []string{"Dividends"},
+		[]string{"stock_symbol", "paid_on", "shares", "gross_inr", "tds_inr", "net_inr"})
+	for _, d := range s.Dividends {
+		rows = append(rows, []string{d.Symbol, d.PaidOn.Format(date), qty(d.Shares), money(d.GrossINR), money(d.TDSINR), money(d.NetINR)})
+	}
+
+	sum := s.Summary
+	rows = append(rows, []string{}, []string{"Summary"},
+		[]string{"perquisite_inr", money(sum.PerquisiteINR)},
+		[]string{"short_term_gain_inr", money(sum.ShortTermGainINR)},
+		[]string{"long_term_gain_inr", money(sum.LongTermGainINR)},
+		[]string{"long_term_exempt_inr", money(sum.LongTermExemptINR)},
+		[]string{"estimated_capital_gains_tax_inr", money(sum.EstimatedTaxINR)},
+		[]string{"dividend_gross_inr", money(sum.DividendGrossINR)},
+		[]string{"dividend_tds_inr", money(sum.DividendTDSINR)},
+		[]string{"dividend_net_inr", money(sum.DividendNetINR)},
+	)
+
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
@@ -0,0 +1,49 @@
+package tax
+
+import (
+	"fmt"
+	"time"
+)
+
+// FinancialYear is an Indian financial year, April to March. Start and End
+// are UTC midnight dates bounding the half-open range [Start, End).
+type FinancialYear struct {
+	Label string    `json:"label"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func financialYear(startYear int) FinancialYear {
+	start := time.Date(startYear, time.April, 1, 0, 0, 0, 0, time.UTC)
+	return FinancialYear{
+		Label: fmt.Sprintf("%d-%02d", startYear, (startYear+1)%100),
+		Start: start,
+		End:   start.AddDate(1, 0, 0),
+	}
+}
+
+// FinancialYearOf returns the financial year containing the calendar date
+// of d in d's location.
+func FinancialYearOf(d time.Time) FinancialYear {
+	year := d.Year()
+	if d.Month() < time.April {
+		year--
+	}
+	return financialYear(year)
+}
+
+// ParseFinancialYear parses a label such as "2025-26".
+func ParseFinancialYear(label string) (FinancialYear, error) {
+	var start, end int
+	if _, err := fmt.Sscanf(label, "%4d-%2d", &start, &end); err != nil || (start+1)%100 != end {
+		return FinancialYear{}, fmt.Errorf("invalid financial year %q, expected e.g. 2025-26", label)
+	}
+	return financialYear(start), nil
+}
+
+// Contains reports whether the calendar date of t, in t's location, falls
+// within the year.
+func (fy FinancialYear) Contains(t time.Time) bool {
+	d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return !d.Before(fy.Start) && d.Before(fy.End)
+}
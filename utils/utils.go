@@ -2,15 +2,29 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
+
+	"stock-reward-api/broker"
+	"stock-reward-api/calendar"
 	"stock-reward-api/db"
+	"stock-reward-api/jwtkeys"
 	"stock-reward-api/logger"
+	"stock-reward-api/mailer"
+	"stock-reward-api/models"
+	"stock-reward-api/payments"
+	"stock-reward-api/pricecache"
+	"stock-reward-api/repository"
+	"stock-reward-api/simulator"
 )
 
 func ExecuteSQLFile(path string) error {
@@ -37,14 +51,12 @@ func ExecuteSQLFile(path string) error {
 	return nil
 }
 
-func LoadDummyUsers() error {
-	rel := filepath.Join("resources", "dummy_users.sql")
-
+func resourcePath(rel string) string {
 	wd, err := os.Getwd()
 	if err == nil {
 		p := filepath.Join(wd, rel)
 		if _, err := os.Stat(p); err == nil {
-			return ExecuteSQLFile(p)
+			return p
 		}
 	}
 
@@ -53,61 +65,764 @@ func LoadDummyUsers() error {
 		dir := filepath.Dir(exe)
 		p := filepath.Join(dir, rel)
 		if _, err := os.Stat(p); err == nil {
-			return ExecuteSQLFile(p)
+			return p
 		}
 	}
 
-	return ExecuteSQLFile(rel)
+	return rel
 }
 
+func LoadDummyUsers() error {
+	return ExecuteSQLFile(resourcePath(filepath.Join("resources", "dummy_users.sql")))
+}
+
+// BootstrapAdmins gives the users listed in the comma separated ADMIN_EMAILS
+// environment variable the admin role, now and when they register.
+func BootstrapAdmins() {
+	repository.AdminEmails = nil
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			repository.AdminEmails = append(repository.AdminEmails, email)
+		}
+	}
+
+	n, err := repository.PromoteAdmins(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Failed to promote admins: %v", err)
+		return
+	}
+	if n > 0 {
+		logger.Log.Infof("Promoted %d users from ADMIN_EMAILS to admin", n)
+	}
+}
 
 func LoadDummyStocks() error {
+	return ExecuteSQLFile(resourcePath(filepath.Join("resources", "dummy_stocks.sql")))
+}
 
-	rel := filepath.Join("resources", "dummy_stocks.sql")
+// LoadMarketCalendar replaces calendar.Default with the calendar from
+// MARKET_CALENDAR_FILE, or resources/market_calendar.json when unset.
+func LoadMarketCalendar() error {
+	path := os.Getenv("MARKET_CALENDAR_FILE")
+	if path == "" {
+		path = resourcePath(filepath.Join("resources", "market_calendar.json"))
+	}
 
-	wd, err := os.Getwd()
-	if err == nil {
-		p := filepath.Join(wd, rel)
-		if _, err := os.Stat(p); err == nil {
-			return ExecuteSQLFile(p)
+	cal, err := calendar.Load(path)
+	if err != nil {
+		return err
+	}
+
+	calendar.Default = cal
+	logger.Log.Infof("Loaded %s market calendar from %s", cal.Exchange, path)
+	return nil
+}
+
+var priceSimulator *simulator.Simulator
+
+// priceMu is held across a price tick, from stepping the simulator to saving
+// its prices, and across applying a corporate action and adjusting the
+// simulator for it. A tick therefore never writes prices stepped before a
+// split over the rescaled ones.
+var priceMu sync.Mutex
+
+// loadPriceSimulator builds the simulator from PRICE_SIMULATOR_FILE, or
+// resources/price_simulator.json when unset, and registers every stock in
+// the stocks table with it. PRICE_SIMULATOR_SEED overrides the file's seed.
+// Each symbol resumes after as many steps as it has ticks in the price
+// history, counting the ticks of symbols it was renamed from, so a restart
+// continues the run's price path. Nothing is written back: stored prices
+// stay as they are until the next tick while the market is open.
+func loadPriceSimulator(ctx context.Context) (*simulator.Simulator, error) {
+	path := os.Getenv("PRICE_SIMULATOR_FILE")
+	if path == "" {
+		path = resourcePath(filepath.Join("resources", "price_simulator.json"))
+	}
+
+	cfg, err := simulator.Load(path)
+	if err != nil {
+		logger.Log.Warnf("Using default price simulator config: %v", err)
+	}
+	if v := os.Getenv("PRICE_SIMULATOR_SEED"); v != "" {
+		seed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PRICE_SIMULATOR_SEED: %w", err)
 		}
+		cfg.Seed = seed
 	}
 
-	exe, err := os.Executable()
-	if err == nil {
-		dir := filepath.Dir(exe)
-		p := filepath.Join(dir, rel)
-		if _, err := os.Stat(p); err == nil {
-			return ExecuteSQLFile(p)
+	rows, err := db.Pool.Query(ctx, `
+		WITH RECURSIVE names(stock_symbol, name) AS (
+			SELECT stock_symbol, stock_symbol FROM stocks WHERE status = $1
+			UNION
+			SELECT n.stock_symbol, p.stock_symbol
+			FROM stocks p JOIN names n ON p.successor_symbol = n.name
+			WHERE p.status = $2
+		)
+		SELECT s.stock_symbol, s.price, COUNT(h.stock_symbol)
+		FROM stocks s
+		JOIN names n ON n.stock_symbol = s.stock_symbol
+		LEFT JOIN stock_price_history h ON h.stock_symbol = n.name
+		GROUP BY s.stock_symbol, s.price
+	`, repository.StockStatusActive, repository.StockStatusRenamed)
+	if err != nil {
+		return nil, err
+	}
+	lastPrices := make(map[string]float64)
+	steps := make(map[string]int64)
+	for rows.Next() {
+		var symbol string
+		var price float64
+		var n int64
+		if err := rows.Scan(&symbol, &price, &n); err != nil {
+			rows.Close()
+			return nil, err
 		}
+		lastPrices[symbol] = price
+		steps[symbol] = n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sim := simulator.New(cfg)
+	startPrices := make(map[string]float64, len(lastPrices))
+	for symbol, price := range lastPrices {
+		startPrices[symbol] = sim.Add(symbol, price, steps[symbol])
 	}
 
-	return ExecuteSQLFile(rel)
+	pricecache.Default.SetMany(startPrices)
+
+	logger.Log.Infof("Price simulator loaded from %s with seed %d for %d symbols", path, cfg.Seed, len(startPrices))
+	return sim, nil
 }
 
 func StartStockPriceUpdater(interval time.Duration) {
+	sim, err := loadPriceSimulator(context.Background())
+	if err != nil {
+		logger.Log.Errorf("Failed to start stock price updater: %v", err)
+		return
+	}
+	priceSimulator = sim
+
 	ticker := time.NewTicker(interval)
 
 	go func() {
-		for range ticker.C {
-			updateStockPrices()
+		for now := range ticker.C {
+			if !calendar.Default.IsOpen(now) {
+				recordClosingPrices(now)
+				continue
+			}
+			updateStockPrices(interval)
 		}
 	}()
 }
 
-func updateStockPrices() {
+func updateStockPrices(interval time.Duration) {
+	priceMu.Lock()
+	defer priceMu.Unlock()
+
+	prices := priceSimulator.Step(interval)
+
+	if err := repository.SaveStockPrices(context.Background(), prices); err != nil {
+		logger.Log.Errorf("Failed to update stock prices: %v", err)
+		return
+	}
+	pricecache.Default.SetMany(prices)
+
+	logger.Log.Println("Stock prices updated successfully")
+}
+
+var lastRecordedClose time.Time
+
+// recordClosingPrices snapshots the current prices as the close of the most
+// recent completed session. Prices stop moving once the market closes, so the
+// first tick after the close captures the final values.
+func recordClosingPrices(now time.Time) {
+	day, ok := calendar.Default.LastCompletedSession(now)
+	if !ok || day.Equal(lastRecordedClose) {
+		return
+	}
+
 	query := `
-		UPDATE stocks
-		SET
-			price = 1500 + random() * 500,
-			updated_at = now();
+		INSERT INTO stock_closes (stock_symbol, trade_date, close_price)
+		SELECT stock_symbol, $1, price FROM stocks
+		ON CONFLICT (stock_symbol, trade_date) DO NOTHING;
 	`
 
-	_, err := db.Pool.Exec(context.Background(), query)
+	if _, err := db.Pool.Exec(context.Background(), query, day); err != nil {
+		logger.Log.Errorf("Failed to record closing prices for %s: %v", day.Format("2006-01-02"), err)
+		return
+	}
+
+	lastRecordedClose = day
+	logger.Log.Infof("Recorded closing prices for %s", day.Format("2006-01-02"))
+}
+
+// InitPriceCache replaces pricecache.Default with a database-backed cache.
+// PRICE_CACHE_TTL sets how long an entry is served before it is reloaded.
+func InitPriceCache() {
+	ttl := 30 * time.Second
+	if v := os.Getenv("PRICE_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			logger.Log.Warnf("invalid PRICE_CACHE_TTL %q, using %s", v, ttl)
+		} else {
+			ttl = d
+		}
+	}
+
+	pricecache.Default = pricecache.New(ttl, repository.GetStockPrice)
+
+	updates, _ := pricecache.Default.Subscribe(64)
+	go func() {
+		for u := range updates {
+			logger.Log.Debugf("Price of %s changed %.2f -> %.2f", u.Symbol, u.OldPrice, u.NewPrice)
+		}
+	}()
+
+	logger.Log.Infof("Price cache initialised with TTL %s", ttl)
+}
+
+// ApplyCorporateAction applies a corporate action and, unless dryRun is set,
+// brings the price simulator and cache in line with it before the next price
+// tick can run.
+func ApplyCorporateAction(ctx context.Context, id uuid.UUID, dryRun bool) (*models.CorporateActionResult, error) {
+	priceMu.Lock()
+	defer priceMu.Unlock()
+
+	result, err := repository.ApplyCorporateAction(ctx, id, dryRun)
+	if err != nil || dryRun {
+		return result, err
+	}
+	afterCorporateAction(result)
+	return result, nil
+}
+
+// afterCorporateAction brings the price simulator and cache in line with an
+// applied corporate action. The caller holds priceMu.
+func afterCorporateAction(result *models.CorporateActionResult) {
+	a := result.Action
+
+	switch a.ActionType {
+	case repository.ActionSplit, repository.ActionBonus:
+		if priceSimulator != nil {
+			priceSimulator.Scale(a.StockSymbol, result.PriceFactor)
+		}
+	case repository.ActionSymbolChange:
+		if priceSimulator != nil {
+			priceSimulator.Rename(a.StockSymbol, a.NewSymbol)
+		}
+		pricecache.Default.Invalidate(a.NewSymbol)
+	case repository.ActionMerger, repository.ActionDelisting:
+		if priceSimulator != nil {
+			priceSimulator.Remove(a.StockSymbol)
+		}
+	}
+	pricecache.Default.Invalidate(a.StockSymbol)
+}
+
+// StartCorporateActionProcessor periodically applies pending corporate
+// actions once their record date has passed and their ex-date is reached.
+func StartCorporateActionProcessor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for now := range ticker.C {
+			processCorporateActions(now)
+		}
+	}()
+}
+
+func processCorporateActions(now time.Time) {
+	ctx := context.Background()
+
+	ids, err := repository.GetDueCorporateActionIDs(ctx, now)
 	if err != nil {
-		logger.Log.Errorf("Failed to update stock prices: %v", err)
+		logger.Log.Errorf("Failed to load due corporate actions: %v", err)
 		return
 	}
 
-	logger.Log.Println("Stock prices updated successfully")
-}
\ No newline at end of file
+	for _, id := range ids {
+		result, err := ApplyCorporateAction(ctx, id, false)
+		if err != nil {
+			logger.Log.Errorf("Failed to apply corporate action %s: %v", id, err)
+			continue
+		}
+		logger.Log.Infof("Applied %s for %s to %d holders", result.Action.ActionType, result.Action.StockSymbol, len(result.Holders))
+	}
+}
+
+func envFloat(name string, def float64) float64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		logger.Log.Warnf("invalid %s %q, using %v", name, v, def)
+		return def
+	}
+	return f
+}
+
+// StartDividendProcessor periodically computes entitlements for dividends
+// whose record date has passed and pays those whose pay date has arrived.
+// DIVIDEND_TDS_RATE and DIVIDEND_TDS_THRESHOLD configure tax withholding.
+func StartDividendProcessor(interval time.Duration) {
+	policy := repository.TDSPolicy{
+		Rate:      envFloat("DIVIDEND_TDS_RATE", 0.10),
+		Threshold: envFloat("DIVIDEND_TDS_THRESHOLD", 5000),
+	}
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for now := range ticker.C {
+			processDividends(now, policy)
+		}
+	}()
+}
+
+func processDividends(now time.Time, policy repository.TDSPolicy) {
+	ctx := context.Background()
+
+	dividends, err := repository.GetDueDividends(ctx, now)
+	if err != nil {
+		logger.Log.Errorf("Failed to load due dividends: %v", err)
+		return
+	}
+
+	for _, d := range dividends {
+		switch d.Status {
+		case repository.DividendStatusDeclared:
+			n, err := repository.ComputeDividendEntitlements(ctx, d.ID, policy)
+			if err != nil {
+				logger.Log.Errorf("Failed to compute entitlements for dividend %s: %v", d.ID, err)
+				continue
+			}
+			logger.Log.Infof("Computed %d entitlements for %s dividend %s", n, d.StockSymbol, d.ID)
+		case repository.DividendStatusEntitled:
+			n, err := repository.PayDividend(ctx, d.ID)
+			if err != nil {
+				logger.Log.Errorf("Failed to pay dividend %s: %v", d.ID, err)
+				continue
+			}
+			logger.Log.Infof("Paid %s dividend %s to %d users", d.StockSymbol, d.ID, n)
+		}
+	}
+}
+
+// StartPortfolioSnapshotter snapshots every user's portfolio after each
+// session closes, catching up on any trading days missed while the server
+// was down.
+func StartPortfolioSnapshotter(interval time.Duration) {
+	snapshotPortfolios(time.Now())
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		for now := range ticker.C {
+			snapshotPortfolios(now)
+		}
+	}()
+}
+
+func snapshotPortfolios(now time.Time) {
+	ctx := context.Background()
+
+	latest, ok := calendar.Default.LastCompletedSession(now)
+	if !ok {
+		return
+	}
+
+	var day time.Time
+	last, ok, err := repository.GetLastSnapshotDate(ctx)
+	if err != nil {
+		logger.Log.Errorf("Failed to load last snapshot date: %v", err)
+		return
+	}
+	if ok {
+		day = calendar.Default.NextTradingDay(last)
+	} else {
+		first, ok, err := repository.GetFirstLedgerDate(ctx)
+		if err != nil {
+			logger.Log.Errorf("Failed to load first ledger date: %v", err)
+			return
+		}
+		if !ok {
+			return
+		}
+		day = calendar.Default.TradeDate(first)
+	}
+
+	for !day.After(latest) {
+		n, err := repository.SnapshotPortfolios(ctx, day)
+		if err != nil {
+			logger.Log.Errorf("Failed to snapshot portfolios for %s: %v", day.Format("2006-01-02"), err)
+			return
+		}
+		logger.Log.Infof("Snapshotted %d portfolios for %s", n, day.Format("2006-01-02"))
+		day = calendar.Default.NextTradingDay(day)
+	}
+}
+
+// InitBroker sets up the simulated broker that sell orders execute through,
+// filling at the cached live price. SELL_SLIPPAGE_BPS sets its slippage and
+// SELL_BROKERAGE_RATE, SELL_BROKERAGE_MAX, SELL_STT_RATE,
+// SELL_EXCHANGE_RATE and SELL_GST_RATE the fees charged on each sale.
+func InitBroker() {
+	broker.Default = &broker.Simulated{
+		Price:       pricecache.Default.Get,
+		SlippageBps: envFloat("SELL_SLIPPAGE_BPS", 5),
+	}
+	broker.DefaultFees = broker.FeeSchedule{
+		BrokerageRate: envFloat("SELL_BROKERAGE_RATE", 0.0003),
+		BrokerageMax:  envFloat("SELL_BROKERAGE_MAX", 20),
+		STTRate:       envFloat("SELL_STT_RATE", 0.001),
+		ExchangeRate:  envFloat("SELL_EXCHANGE_RATE", 0.0000297),
+		GSTRate:       envFloat("SELL_GST_RATE", 0.18),
+	}
+}
+
+// StartSellOrderProcessor periodically executes sell orders left pending
+// while the market was closed.
+func StartSellOrderProcessor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for now := range ticker.C {
+			if !calendar.Default.IsOpen(now) {
+				continue
+			}
+			processSellOrders()
+		}
+	}()
+}
+
+func processSellOrders() {
+	ctx := context.Background()
+
+	ids, err := repository.GetPendingSellOrderIDs(ctx)
+	if err != nil {
+		logger.Log.Errorf("Failed to load pending sell orders: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		order, err := repository.ExecuteSellOrder(ctx, id, broker.Default, broker.DefaultFees)
+		if err != nil {
+			if errors.Is(err, broker.ErrMarketClosed) {
+				return
+			}
+			logger.Log.Errorf("Failed to execute sell order %s: %v", id, err)
+			continue
+		}
+		logger.Log.Infof("Sell order %s for %s is %s", id, order.StockSymbol, order.Status)
+	}
+}
+
+// InitPaymentGateway sets up the local stub gateway withdrawals are paid
+// through. It delivers callbacks in-process after PAYMENT_STUB_DELAY
+// (default 2s) and fails a PAYMENT_STUB_FAILURE_RATE share of payouts.
+// WITHDRAWAL_MIN_INR, WITHDRAWAL_MAX_INR and WITHDRAWAL_DAILY_LIMIT_INR
+// override the withdrawal limits. The server does not start without
+// BANK_ACCOUNT_HASH_KEY, which keys the bank account hashes.
+func InitPaymentGateway() {
+	key := os.Getenv("BANK_ACCOUNT_HASH_KEY")
+	if key == "" {
+		logger.Log.Error("BANK_ACCOUNT_HASH_KEY is not set")
+		os.Exit(1)
+	}
+	payments.AccountHashKey = []byte(key)
+
+	delay := 2 * time.Second
+	if v := os.Getenv("PAYMENT_STUB_DELAY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			logger.Log.Warnf("invalid PAYMENT_STUB_DELAY %q, using %s", v, delay)
+		} else {
+			delay = d
+		}
+	}
+
+	payments.Default = &payments.Stub{
+		Delay:       delay,
+		FailureRate: envFloat("PAYMENT_STUB_FAILURE_RATE", 0),
+		Notify: func(ctx context.Context, cb payments.Callback) error {
+			w, duplicate, err := repository.HandlePayoutCallback(ctx, cb)
+			if err != nil {
+				logger.Log.Errorf("Failed to apply payout callback %s: %v", cb.EventID, err)
+				return err
+			}
+			if !duplicate {
+				logger.Log.Infof("Withdrawal %s is %s", w.ID, w.Status)
+			}
+			return nil
+		},
+	}
+
+	limits := &repository.DefaultWithdrawalLimits
+	limits.MinINR = envFloat("WITHDRAWAL_MIN_INR", limits.MinINR)
+	limits.MaxINR = envFloat("WITHDRAWAL_MAX_INR", limits.MaxINR)
+	limits.DailyINR = envFloat("WITHDRAWAL_DAILY_LIMIT_INR", limits.DailyINR)
+}
+
+// InitSigningKeys loads the access token signing keys from the manifest at
+// JWT_KEYS_FILE, keeping replaced keys published for JWT_KEY_OVERLAP
+// (default 1h). The server does not start without valid keys.
+func InitSigningKeys() {
+	path := os.Getenv("JWT_KEYS_FILE")
+	if path == "" {
+		logger.Log.Error("JWT_KEYS_FILE is not set")
+		os.Exit(1)
+	}
+	ks, err := jwtkeys.Load(path, envDuration("JWT_KEY_OVERLAP", time.Hour))
+	if err != nil {
+		logger.Log.Errorf("Unable to load signing keys from %s: %v", path, err)
+		os.Exit(1)
+	}
+	jwtkeys.Use(ks)
+
+	key, _ := ks.Signing(time.Now())
+	logger.Log.Infof("Loaded %d signing keys; signing with %s (%s)", len(ks.Keys), key.ID, key.Algorithm)
+}
+
+// StartSigningKeyReload periodically reloads the signing key manifest, so
+// keys added for upcoming rotations are published without a restart. A
+// manifest that fails to load leaves the current keys in place.
+func StartSigningKeyReload(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	path := os.Getenv("JWT_KEYS_FILE")
+	signing, _ := jwtkeys.Current().Signing(time.Now())
+
+	go func() {
+		for range ticker.C {
+			ks, err := jwtkeys.Load(path, jwtkeys.Current().Overlap)
+			if err != nil {
+				logger.Log.Errorf("Failed to reload signing keys, keeping current ones: %v", err)
+				ks = jwtkeys.Current()
+			} else {
+				jwtkeys.Use(ks)
+			}
+
+			// Rotations happen on schedule, not only on reload.
+			key, err := ks.Signing(time.Now())
+			if err == nil && key.ID != signing.ID {
+				logger.Log.Infof("Signing key rotated from %s to %s (%s)", signing.ID, key.ID, key.Algorithm)
+				signing = key
+			}
+		}
+	}()
+}
+
+// InitMailer configures mailer.Default from MAILER: "smtp" sends through
+// SMTP_HOST, SMTP_PORT (default 587), SMTP_USERNAME and SMTP_PASSWORD;
+// "file" appends messages to MAILER_FILE (default mail.log); anything else
+// prints them to stdout. MAIL_FROM sets the sender.
+func InitMailer() {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Stocky <no-reply@stocky.local>"
+	}
+
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		port := 587
+		if v := os.Getenv("SMTP_PORT"); v != "" {
+			p, err := strconv.Atoi(v)
+			if err != nil {
+				logger.Log.Warnf("invalid SMTP_PORT %q, using %d", v, port)
+			} else {
+				port = p
+			}
+		}
+		mailer.Default = &mailer.SMTP{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+		logger.Log.Infof("Sending mail through %s:%d", os.Getenv("SMTP_HOST"), port)
+	case "file":
+		path := os.Getenv("MAILER_FILE")
+		if path == "" {
+			path = "mail.log"
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			logger.Log.Errorf("Failed to open %s for mail, printing to stdout: %v", path, err)
+			mailer.Default = &mailer.Writer{Out: os.Stdout, From: from}
+			return
+		}
+		mailer.Default = &mailer.Writer{Out: f, From: from}
+		logger.Log.Infof("Writing mail to %s", path)
+	default:
+		mailer.Default = &mailer.Writer{Out: os.Stdout, From: from}
+	}
+}
+
+// InitTransferLimits applies TRANSFER_DAILY_COUNT, TRANSFER_DAILY_LIMIT_INR
+// and TRANSFER_REQUIRE_ACCEPTANCE to share transfers.
+func InitTransferLimits() {
+	limits := &repository.DefaultTransferLimits
+	if v := os.Getenv("TRANSFER_DAILY_COUNT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			logger.Log.Warnf("invalid TRANSFER_DAILY_COUNT %q, using %d", v, limits.DailyCount)
+		} else {
+			limits.DailyCount = n
+		}
+	}
+	limits.DailyValueINR = envFloat("TRANSFER_DAILY_LIMIT_INR", limits.DailyValueINR)
+	if v := os.Getenv("TRANSFER_REQUIRE_ACCEPTANCE"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			logger.Log.Warnf("invalid TRANSFER_REQUIRE_ACCEPTANCE %q, using %v", v, repository.RequireTransferAcceptance)
+		} else {
+			repository.RequireTransferAcceptance = b
+		}
+	}
+}
+
+// InitEmailVerification checks EMAIL_VERIFICATION_SECRET and applies
+// REQUIRE_VERIFIED_EMAIL_LOGIN, REQUIRE_VERIFIED_EMAIL_REWARDS,
+// EMAIL_VERIFICATION_RESEND_INTERVAL and EMAIL_VERIFICATION_DAILY_LIMIT.
+func InitEmailVerification() {
+	if os.Getenv("EMAIL_VERIFICATION_SECRET") == "" {
+		logger.Log.Warn("EMAIL_VERIFICATION_SECRET not set; verification emails cannot be sent")
+	}
+	repository.RequireVerifiedEmailForLogin = envBool("REQUIRE_VERIFIED_EMAIL_LOGIN", repository.RequireVerifiedEmailForLogin)
+	repository.RequireVerifiedEmailForRewards = envBool("REQUIRE_VERIFIED_EMAIL_REWARDS", repository.RequireVerifiedEmailForRewards)
+
+	limits := &repository.DefaultVerificationResendLimits
+	if v := os.Getenv("EMAIL_VERIFICATION_RESEND_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			logger.Log.Warnf("invalid EMAIL_VERIFICATION_RESEND_INTERVAL %q, using %s", v, limits.MinInterval)
+		} else {
+			limits.MinInterval = d
+		}
+	}
+	if v := os.Getenv("EMAIL_VERIFICATION_DAILY_LIMIT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			logger.Log.Warnf("invalid EMAIL_VERIFICATION_DAILY_LIMIT %q, using %d", v, limits.MaxPerDay)
+		} else {
+			limits.MaxPerDay = n
+		}
+	}
+}
+
+// InitLoginThrottle applies LOGIN_MAX_FAILURES, LOGIN_IP_MAX_FAILURES,
+// LOGIN_FAILURE_WINDOW, LOGIN_BACKOFF_BASE, LOGIN_BACKOFF_MAX and
+// LOGIN_LOCKOUT_DURATION to failed logins.
+func InitLoginThrottle() {
+	t := &repository.DefaultLoginThrottle
+	t.MaxFailures = envInt("LOGIN_MAX_FAILURES", t.MaxFailures)
+	t.IPMaxFailures = envInt("LOGIN_IP_MAX_FAILURES", t.IPMaxFailures)
+	t.Window = envDuration("LOGIN_FAILURE_WINDOW", t.Window)
+	t.BaseDelay = envDuration("LOGIN_BACKOFF_BASE", t.BaseDelay)
+	t.MaxDelay = envDuration("LOGIN_BACKOFF_MAX", t.MaxDelay)
+	t.LockoutDuration = envDuration("LOGIN_LOCKOUT_DURATION", t.LockoutDuration)
+}
+
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		logger.Log.Warnf("invalid %s %q, using %d", name, v, def)
+		return def
+	}
+	return n
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		logger.Log.Warnf("invalid %s %q, using %s", name, v, def)
+		return def
+	}
+	return d
+}
+
+func envBool(name string, def bool) bool {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		logger.Log.Warnf("invalid %s %q, using %v", name, v, def)
+		return def
+	}
+	return b
+}
+
+// StartWithdrawalProcessor periodically sends requested withdrawals to the
+// payment gateway, picking up any that were not sent when requested.
+func StartWithdrawalProcessor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for range ticker.C {
+			processWithdrawals()
+		}
+	}()
+}
+
+func processWithdrawals() {
+	ctx := context.Background()
+
+	ids, err := repository.GetRequestedWithdrawalIDs(ctx)
+	if err != nil {
+		logger.Log.Errorf("Failed to load requested withdrawals: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		w, err := repository.ProcessWithdrawal(ctx, id, payments.Default)
+		if err != nil {
+			if !errors.Is(err, repository.ErrWithdrawalState) {
+				logger.Log.Errorf("Failed to process withdrawal %s: %v", id, err)
+			}
+			continue
+		}
+		logger.Log.Infof("Withdrawal %s is %s", id, w.Status)
+	}
+}
+
+// StartTokenCleanup periodically deletes expired refresh tokens and
+// revocations, and failed login counts that have lapsed.
+func StartTokenCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for range ticker.C {
+			n, err := repository.DeleteExpiredTokens(context.Background())
+			if err != nil {
+				logger.Log.Errorf("Failed to delete expired tokens: %v", err)
+				continue
+			}
+			if n > 0 {
+				logger.Log.Infof("Deleted %d expired tokens", n)
+			}
+
+			n, err = repository.DeleteStaleLoginFailures(context.Background(), repository.DefaultLoginThrottle)
+			if err != nil {
+				logger.Log.Errorf("Failed to delete stale login failures: %v", err)
+				continue
+			}
+			if n > 0 {
+				logger.Log.Infof("Deleted %d stale login failure counts", n)
+			}
+		}
+	}()
+}